@@ -0,0 +1,33 @@
+// doc.go - package documentation.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+/*
+Package cliptest provides utilities for testing [clip] based programs.
+
+The [*Env] type is an in-memory [clip.ExtendedExecEnv] with captured standard
+output and standard error, injectable standard input, a map-backed
+environment, a map-backed file system, a fixed clock and host name, and
+simulated signal delivery. It also implements [clip.TerminalExecEnv], and
+its standard output is not a terminal unless you set the TTY field. Its Exit
+method records the exit status and terminates the calling goroutine, which
+causes [*Env.Run] to return.
+Set the Dir field to use a sandbox directory as the working directory
+and the file system, instead of the in-memory one.
+
+Use [Run] to run a [*clip.RootCommand] with the given command line and
+obtain a [*Result] containing the exit status and the output. When
+the command needs customizing the environment before running, or
+the command construction depends on the [*Env], create the [*Env]
+using [NewEnv] and then call [*Env.Run]. For example:
+
+	env := cliptest.NewEnv("minirbmk", "git", "clone", "--help")
+	env.EnvVars["HOME"] = "/home/user"
+	result := env.Run(newRootCommand(env))
+
+To use the [*Env], the command tree must be generic over the [clip.ExecEnv]
+type, or use [*Env] as the type parameter.
+
+The [github.com/bassosimone/clip/cliptest/testscript] package builds on
+top of the [*Env] to run end-to-end tests written as scripts.
+*/
+package cliptest
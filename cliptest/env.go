@@ -0,0 +1,363 @@
+// env.go - in-memory execution environment.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cliptest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bassosimone/clip"
+)
+
+// Env is an in-memory [clip.ExtendedExecEnv] and [clip.TerminalExecEnv] for testing.
+//
+// The zero value is not ready to use. Use [NewEnv] to create
+// a new instance. Customize fields as needed.
+type Env struct {
+	// Argv contains the program name followed by the arguments.
+	//
+	// [NewEnv] initializes this field to the given argv.
+	Argv []string
+
+	// EnvVars contains the environment variables.
+	//
+	// [NewEnv] initializes this field to an empty map.
+	EnvVars map[string]string
+
+	// Files contains the files of the in-memory file system returned by
+	// FS when Dir is empty, indexed by name, where relative names are
+	// relative to the root directory.
+	//
+	// [NewEnv] initializes this field to an empty map.
+	Files map[string][]byte
+
+	// Dir is the optional sandbox directory, which is also the working
+	// directory. When not empty, FS returns a file system using this
+	// directory to resolve relative names instead of the in-memory one.
+	//
+	// [NewEnv] initializes this field to "", meaning no directory.
+	Dir string
+
+	// Host is the host name returned by Hostname.
+	//
+	// [NewEnv] initializes this field to "localhost".
+	Host string
+
+	// Time is the time returned by Now.
+	//
+	// [NewEnv] initializes this field to 2025-01-01 00:00:00 UTC.
+	Time time.Time
+
+	// TTY indicates whether StdoutTerminal claims that the standard output
+	// is a terminal, which allows testing how commands format their output
+	// for terminals (see [clip.DetectTerminal]).
+	//
+	// [NewEnv] initializes this field to false.
+	TTY bool
+
+	// TTYWidth is the terminal width returned by StdoutTerminal.
+	//
+	// [NewEnv] initializes this field to 0, meaning unknown.
+	TTYWidth int
+
+	// StdinReader is the standard input.
+	//
+	// [NewEnv] initializes this field to an empty reader.
+	StdinReader io.Reader
+
+	// StdoutBuffer captures the standard output.
+	//
+	// [NewEnv] initializes this field to an empty buffer.
+	StdoutBuffer *bytes.Buffer
+
+	// StderrBuffer captures the standard error.
+	//
+	// [NewEnv] initializes this field to an empty buffer.
+	StderrBuffer *bytes.Buffer
+
+	// mu protects signals, exitcode, the buffers, and the in-memory file system.
+	mu sync.Mutex
+
+	// dirs contains the directories created using the in-memory file system.
+	dirs map[string]struct{}
+
+	// exited is closed by the first Exit call.
+	exited chan struct{}
+
+	// exitcode is the status passed to the first Exit call.
+	exitcode int
+
+	// notified is closed by the first SignalNotify call.
+	notified chan struct{}
+
+	// signals contains the channels registered using SignalNotify.
+	signals []signalRegistration
+}
+
+// signalRegistration is a channel registered using SignalNotify.
+type signalRegistration struct {
+	ch      chan<- clip.Signal
+	signals []clip.Signal
+}
+
+var _ clip.ExtendedExecEnv = &Env{}
+
+var _ clip.TerminalExecEnv = &Env{}
+
+// NewEnv creates a new [*Env] using the given program name and arguments.
+func NewEnv(argv ...string) *Env {
+	return &Env{
+		Argv:         argv,
+		EnvVars:      map[string]string{},
+		Files:        map[string][]byte{},
+		Dir:          "",
+		Host:         "localhost",
+		Time:         time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		TTY:          false,
+		TTYWidth:     0,
+		StdinReader:  strings.NewReader(""),
+		StdoutBuffer: &bytes.Buffer{},
+		StderrBuffer: &bytes.Buffer{},
+		mu:           sync.Mutex{},
+		dirs:         map[string]struct{}{},
+		exited:       make(chan struct{}),
+		exitcode:     0,
+		notified:     make(chan struct{}),
+		signals:      []signalRegistration{},
+	}
+}
+
+// Args implements [clip.ExecEnv].
+func (env *Env) Args() []string {
+	return env.Argv
+}
+
+// Environ implements [clip.ExtendedExecEnv].
+//
+// We return the EnvVars sorted by key using the `KEY=value` form.
+func (env *Env) Environ() []string {
+	var output []string
+	for _, key := range slices.Sorted(maps.Keys(env.EnvVars)) {
+		output = append(output, key+"="+env.EnvVars[key])
+	}
+	return output
+}
+
+// Exit implements [clip.ExecEnv].
+//
+// Like [os.Exit], this method does not return: we record the exit status
+// passed to the first call and terminate the calling goroutine using
+// [runtime.Goexit]. Because [*Env.Run] returns as soon as the program
+// calls Exit, you may call this method from any goroutine (e.g., when
+// handling signals) but only while [*Env.Run] is running.
+func (env *Env) Exit(exitcode int) {
+	env.mu.Lock()
+	select {
+	case <-env.exited:
+	default:
+		env.exitcode = exitcode
+		close(env.exited)
+	}
+	env.mu.Unlock()
+	runtime.Goexit()
+}
+
+// FS implements [clip.ExtendedExecEnv].
+//
+// When Dir is empty, we return an in-memory file system containing the Files
+// where the names are relative to the root directory. Otherwise, we return a
+// file system using Dir to resolve the relative names (see [*Env.Path]).
+func (env *Env) FS() clip.FS {
+	if env.Dir != "" {
+		return dirFS{env}
+	}
+	return memFS{env}
+}
+
+// Getwd implements [clip.ExtendedExecEnv].
+//
+// We return the Dir, or the root directory when Dir is empty.
+func (env *Env) Getwd() (string, error) {
+	if env.Dir != "" {
+		return env.Dir, nil
+	}
+	return string(filepath.Separator), nil
+}
+
+// Hostname implements [clip.ExtendedExecEnv].
+func (env *Env) Hostname() (string, error) {
+	return env.Host, nil
+}
+
+// LookupEnv implements [clip.ExecEnv].
+func (env *Env) LookupEnv(key string) (string, bool) {
+	value, found := env.EnvVars[key]
+	return value, found
+}
+
+// Now implements [clip.ExtendedExecEnv].
+func (env *Env) Now() time.Time {
+	return env.Time
+}
+
+// Path returns the path of the file with the given name inside the sandbox
+// directory, i.e., the name itself if absolute, or the name joined with the Dir
+// field otherwise. Commands should not need this method, which helps with
+// implementing testing utilities (e.g., comparing the files written by
+// the command with the expected ones).
+func (env *Env) Path(name string) string {
+	if filepath.IsAbs(name) || env.Dir == "" {
+		return name
+	}
+	return filepath.Join(env.Dir, name)
+}
+
+// SignalNotify implements [clip.ExecEnv].
+//
+// Like [signal.Notify], when no signal is given, we deliver all the signals
+// to the channel. Use [*Env.SendSignal] to deliver signals.
+func (env *Env) SignalNotify(c chan<- clip.Signal, sig ...clip.Signal) {
+	env.mu.Lock()
+	if len(env.signals) <= 0 {
+		close(env.notified)
+	}
+	env.signals = append(env.signals, signalRegistration{ch: c, signals: slices.Clone(sig)})
+	env.mu.Unlock()
+}
+
+// SignalNotified returns a channel closed when the program first calls
+// SignalNotify. Wait for this channel before calling [*Env.SendSignal]
+// from another goroutine to ensure the program does not miss the signal.
+func (env *Env) SignalNotified() <-chan struct{} {
+	return env.notified
+}
+
+// SendSignal simulates delivering the given signal to the program. Like
+// [signal.Notify], we do not block sending to the registered channels,
+// so the signal is lost if a channel is not ready to receive it.
+func (env *Env) SendSignal(sig clip.Signal) {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	for _, reg := range env.signals {
+		if len(reg.signals) > 0 && !slices.Contains(reg.signals, sig) {
+			continue
+		}
+		select {
+		case reg.ch <- sig:
+		default:
+		}
+	}
+}
+
+// Stdin implements [clip.ExecEnv].
+func (env *Env) Stdin() io.Reader {
+	return env.StdinReader
+}
+
+// Stdout implements [clip.ExecEnv].
+//
+// The returned writer is safe to use from multiple goroutines.
+func (env *Env) Stdout() io.Writer {
+	return &lockedWriter{mu: &env.mu, w: env.StdoutBuffer}
+}
+
+// StdoutTerminal implements [clip.TerminalExecEnv].
+//
+// We return the TTY and the TTYWidth.
+func (env *Env) StdoutTerminal() (isatty bool, width int) {
+	return env.TTY, env.TTYWidth
+}
+
+// Stderr implements [clip.ExecEnv].
+//
+// The returned writer is safe to use from multiple goroutines.
+func (env *Env) Stderr() io.Writer {
+	return &lockedWriter{mu: &env.mu, w: env.StderrBuffer}
+}
+
+// lockedWriter is an [io.Writer] protected by a mutex.
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+// Write implements [io.Writer].
+func (lw *lockedWriter) Write(data []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w.Write(data)
+}
+
+// Result is the result of running a [*clip.RootCommand] using [*Env.Run].
+type Result struct {
+	// ExitCode is the exit status, which is zero when
+	// the command returns without calling Exit.
+	ExitCode int
+
+	// Stdout is the standard output.
+	Stdout string
+
+	// Stderr is the standard error.
+	Stderr string
+}
+
+// String returns a string representation of the result, which
+// is useful to print the result when a test fails.
+func (r *Result) String() string {
+	return fmt.Sprintf("exit status %d\n--- stdout ---\n%s--- stderr ---\n%s", r.ExitCode, r.Stdout, r.Stderr)
+}
+
+// Run runs the given root command using this [*Env] and returns the [*Result].
+//
+// We run the command in a background goroutine and we return when either the
+// command returns or the program calls Exit. In the latter case, goroutines
+// other than the one calling Exit may still be running (e.g., a command that
+// ignores the context cancellation when the program exits because of a signal),
+// and you should make sure they eventually terminate. We propagate
+// panics occurring in the goroutine running the command.
+//
+// Call this method at most once for each [*Env].
+func (env *Env) Run(root *clip.RootCommand[*Env]) *Result {
+	var (
+		done       = make(chan struct{})
+		panicValue any
+	)
+	go func() {
+		defer close(done)
+		defer func() {
+			panicValue = recover()
+		}()
+		root.Main(env)
+	}()
+
+	select {
+	case <-done:
+		if panicValue != nil {
+			panic(panicValue)
+		}
+	case <-env.exited:
+	}
+
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	return &Result{
+		ExitCode: env.exitcode,
+		Stdout:   env.StdoutBuffer.String(),
+		Stderr:   env.StderrBuffer.String(),
+	}
+}
+
+// Run is a convenience function that creates a new [*Env] using
+// [NewEnv] with the given argv and calls [*Env.Run].
+func Run(root *clip.RootCommand[*Env], argv ...string) *Result {
+	return NewEnv(argv...).Run(root)
+}
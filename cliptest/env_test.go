@@ -0,0 +1,234 @@
+// env_test.go - in-memory execution environment tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cliptest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bassosimone/clip"
+	"github.com/google/go-cmp/cmp"
+)
+
+// newTestRootCommand returns a root command running the given function.
+func newTestRootCommand(fn func(ctx context.Context, args *clip.CommandArgs[*Env]) error) *clip.RootCommand[*Env] {
+	return &clip.RootCommand[*Env]{
+		AutoCancel: true,
+		Command: &clip.DispatcherCommand[*Env]{
+			BriefDescriptionText: "Test dispatcher.",
+			Commands: map[string]clip.Command[*Env]{
+				"run": &clip.LeafCommand[*Env]{
+					BriefDescriptionText: "Run the test function.",
+					RunFunc:              fn,
+				},
+			},
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+	type testcase struct {
+		name   string
+		argv   []string
+		fn     func(ctx context.Context, args *clip.CommandArgs[*Env]) error
+		expect *Result
+	}
+
+	cases := []testcase{
+		{
+			name: "we capture the standard output",
+			argv: []string{"test", "run", "a", "b"},
+			fn: func(ctx context.Context, args *clip.CommandArgs[*Env]) error {
+				fmt.Fprintf(args.Env.Stdout(), "%v\n", args.Args)
+				return nil
+			},
+			expect: &Result{ExitCode: 0, Stdout: "[a b]\n"},
+		},
+
+		{
+			name: "we capture the standard error and the exit status",
+			argv: []string{"test", "run"},
+			fn: func(ctx context.Context, args *clip.CommandArgs[*Env]) error {
+				return errors.New("mocked error")
+			},
+			expect: &Result{ExitCode: 1, Stderr: "test: mocked error\n"},
+		},
+
+		{
+			name: "exit stops the command",
+			argv: []string{"test", "run"},
+			fn: func(ctx context.Context, args *clip.CommandArgs[*Env]) error {
+				args.Env.Exit(3)
+				fmt.Fprintf(args.Env.Stdout(), "not reached\n")
+				return nil
+			},
+			expect: &Result{ExitCode: 3},
+		},
+
+		{
+			name: "we capture the output of the dispatcher",
+			argv: []string{"test", "nonexistent"},
+			expect: &Result{
+				ExitCode: 2,
+				Stderr:   "test: no such command: nonexistent\nTry 'test --help' for more information.\n",
+			},
+		},
+	}
+
+	cases = append(cases, testcase{
+		name: "exit from another goroutine stops the command",
+		argv: []string{"test", "run"},
+		fn: func(ctx context.Context, args *clip.CommandArgs[*Env]) error {
+			go args.Env.Exit(130)
+			select {} // simulate a command ignoring the cancellation
+		},
+		expect: &Result{ExitCode: 130},
+	})
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := Run(newTestRootCommand(tc.fn), tc.argv...)
+			if diff := cmp.Diff(tc.expect, result); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	t.Run("we propagate other panics", func(t *testing.T) {
+		root := newTestRootCommand(func(ctx context.Context, args *clip.CommandArgs[*Env]) error {
+			panic("mocked panic")
+		})
+		defer func() {
+			if r := recover(); r != "mocked panic" {
+				t.Fatal("unexpected panic value", r)
+			}
+		}()
+		Run(root, "test", "run")
+		t.Fatal("should not be reached")
+	})
+}
+
+func TestEnv(t *testing.T) {
+	t.Run("we read the standard input, the environment, and the files", func(t *testing.T) {
+		env := NewEnv("test", "run")
+		env.StdinReader = strings.NewReader("hello\n")
+		env.EnvVars["NAME"] = "world"
+		env.Files["/etc/greeting"] = []byte("hi")
+		root := newTestRootCommand(func(ctx context.Context, args *clip.CommandArgs[*Env]) error {
+			stdin, err := io.ReadAll(args.Env.Stdin())
+			if err != nil {
+				return err
+			}
+			name, _ := args.Env.LookupEnv("NAME")
+			_, found := args.Env.LookupEnv("HOME")
+			greeting, err := args.Env.FS().ReadFile("/etc/greeting")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(args.Env.Stdout(), "%s%s %s %v\n", stdin, greeting, name, found)
+			_, err = args.Env.FS().ReadFile("/etc/nonexistent")
+			return err
+		})
+		result := env.Run(root)
+		if !strings.HasSuffix(result.Stderr, "file does not exist\n") || result.ExitCode != 1 {
+			t.Fatal(result)
+		}
+		if diff := cmp.Diff("hello\nhi world false\n", result.Stdout); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("FS returns an error wrapping fs.ErrNotExist", func(t *testing.T) {
+		_, err := NewEnv().FS().ReadFile("/nonexistent")
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("we provide a hermetic working directory, clock, environment, and host name", func(t *testing.T) {
+		env := NewEnv()
+		env.EnvVars["B"] = "2"
+		env.EnvVars["A"] = "1"
+		if diff := cmp.Diff([]string{"A=1", "B=2"}, env.Environ()); diff != "" {
+			t.Fatal(diff)
+		}
+		if wd, err := env.Getwd(); err != nil || wd != string(filepath.Separator) {
+			t.Fatal("unexpected working directory", wd, err)
+		}
+		env.Dir = t.TempDir()
+		if wd, err := env.Getwd(); err != nil || wd != env.Dir {
+			t.Fatal("unexpected working directory", wd, err)
+		}
+		if host, err := env.Hostname(); err != nil || host != "localhost" {
+			t.Fatal("unexpected host name", host, err)
+		}
+		if now := env.Now(); !now.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Fatal("unexpected time", now)
+		}
+		if xenv := clip.Extend(env); xenv != env {
+			t.Fatal("expected the same env")
+		}
+	})
+
+	t.Run("StdoutTerminal returns the TTY and the TTYWidth", func(t *testing.T) {
+		env := NewEnv()
+		if term := clip.DetectTerminal(env); term != (clip.Terminal{}) {
+			t.Fatal("unexpected terminal", term)
+		}
+		env.TTY, env.TTYWidth = true, 100
+		expect := clip.Terminal{Colors: true, IsTTY: true, Width: 100}
+		if term := clip.DetectTerminal(env); term != expect {
+			t.Fatal("expected", expect, "got", term)
+		}
+	})
+
+	t.Run("SignalNotified is closed by the first SignalNotify", func(t *testing.T) {
+		env := NewEnv()
+		select {
+		case <-env.SignalNotified():
+			t.Fatal("should not be closed yet")
+		default:
+		}
+		env.SignalNotify(make(chan clip.Signal, 1), os.Interrupt)
+		env.SignalNotify(make(chan clip.Signal, 1), os.Kill)
+		<-env.SignalNotified()
+	})
+
+	t.Run("we deliver signals", func(t *testing.T) {
+		env := NewEnv("test", "run")
+		root := newTestRootCommand(func(ctx context.Context, args *clip.CommandArgs[*Env]) error {
+			args.Env.SendSignal(os.Interrupt)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		result := env.Run(root)
+		expect := &Result{ExitCode: 130, Stderr: "test: context canceled\n"}
+		if diff := cmp.Diff(expect, result); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("we only deliver the registered signals", func(t *testing.T) {
+		env := NewEnv()
+		interrupt, all := make(chan clip.Signal, 1), make(chan clip.Signal, 2)
+		env.SignalNotify(interrupt, os.Interrupt)
+		env.SignalNotify(all)
+		env.SendSignal(os.Kill)
+		env.SendSignal(os.Interrupt)
+		if sig := <-interrupt; sig != os.Interrupt {
+			t.Fatal("expected", os.Interrupt, "got", sig)
+		}
+		if diff := cmp.Diff([]clip.Signal{os.Kill, os.Interrupt}, []clip.Signal{<-all, <-all}); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+}
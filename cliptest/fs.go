@@ -0,0 +1,208 @@
+// fs.go - file systems.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cliptest
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing/fstest"
+
+	"github.com/bassosimone/clip"
+)
+
+// dirFS is the [clip.FS] using the Dir of the [*Env] to resolve relative names.
+type dirFS struct {
+	env *Env
+}
+
+var _ clip.FS = dirFS{}
+
+// MkdirAll implements [clip.FS].
+func (d dirFS) MkdirAll(name string, perm fs.FileMode) error {
+	return os.MkdirAll(d.env.Path(name), perm)
+}
+
+// Open implements [clip.FS].
+func (d dirFS) Open(name string) (fs.File, error) {
+	return os.Open(d.env.Path(name))
+}
+
+// ReadDir implements [clip.FS].
+func (d dirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(d.env.Path(name))
+}
+
+// ReadFile implements [clip.FS].
+func (d dirFS) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(d.env.Path(name))
+}
+
+// Remove implements [clip.FS].
+func (d dirFS) Remove(name string) error {
+	return os.Remove(d.env.Path(name))
+}
+
+// Stat implements [clip.FS].
+func (d dirFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(d.env.Path(name))
+}
+
+// WriteFile implements [clip.FS].
+func (d dirFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(d.env.Path(name), data, perm)
+}
+
+// errIsDir indicates that a file operation requires a regular file.
+var errIsDir = errors.New("is a directory")
+
+// errNotDir indicates that a file operation requires a directory.
+var errNotDir = errors.New("not a directory")
+
+// errNotEmpty indicates that a directory is not empty.
+var errNotEmpty = errors.New("directory not empty")
+
+// memFS is the in-memory [clip.FS] containing the Files of the [*Env].
+//
+// We implement reading by creating an [fstest.MapFS] containing the Files
+// and the directories created by MkdirAll and by delegating to it.
+type memFS struct {
+	env *Env
+}
+
+var _ clip.FS = memFS{}
+
+// MkdirAll implements [clip.FS].
+func (m memFS) MkdirAll(name string, perm fs.FileMode) error {
+	m.env.mu.Lock()
+	defer m.env.mu.Unlock()
+	mapfs, mname := m.mapFS(), mapName(name)
+	if mname == "." {
+		return nil
+	}
+	var current string
+	for _, elem := range strings.Split(mname, "/") {
+		current = path.Join(current, elem)
+		if finfo, err := mapfs.Stat(current); err == nil && !finfo.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: name, Err: errNotDir}
+		}
+		m.env.dirs[current] = struct{}{}
+	}
+	return nil
+}
+
+// Open implements [clip.FS].
+func (m memFS) Open(name string) (fs.File, error) {
+	m.env.mu.Lock()
+	defer m.env.mu.Unlock()
+	file, err := m.mapFS().Open(mapName(name))
+	return file, withPath(err, name)
+}
+
+// ReadDir implements [clip.FS].
+func (m memFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.env.mu.Lock()
+	defer m.env.mu.Unlock()
+	entries, err := m.mapFS().ReadDir(mapName(name))
+	return entries, withPath(err, name)
+}
+
+// ReadFile implements [clip.FS].
+func (m memFS) ReadFile(name string) ([]byte, error) {
+	m.env.mu.Lock()
+	defer m.env.mu.Unlock()
+	data, err := m.mapFS().ReadFile(mapName(name))
+	return data, withPath(err, name)
+}
+
+// Remove implements [clip.FS].
+func (m memFS) Remove(name string) error {
+	m.env.mu.Lock()
+	defer m.env.mu.Unlock()
+	mapfs, mname := m.mapFS(), mapName(name)
+	finfo, err := mapfs.Stat(mname)
+	if err != nil {
+		return withPath(err, name)
+	}
+	if finfo.IsDir() {
+		if entries, _ := mapfs.ReadDir(mname); len(entries) > 0 || mname == "." {
+			return &fs.PathError{Op: "remove", Path: name, Err: errNotEmpty}
+		}
+		delete(m.env.dirs, mname)
+		return nil
+	}
+	m.deleteFile(mname)
+	return nil
+}
+
+// Stat implements [clip.FS].
+func (m memFS) Stat(name string) (fs.FileInfo, error) {
+	m.env.mu.Lock()
+	defer m.env.mu.Unlock()
+	finfo, err := m.mapFS().Stat(mapName(name))
+	return finfo, withPath(err, name)
+}
+
+// WriteFile implements [clip.FS].
+//
+// We ignore the permissions because the Files do not store them.
+func (m memFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.env.mu.Lock()
+	defer m.env.mu.Unlock()
+	mapfs, mname := m.mapFS(), mapName(name)
+	if finfo, err := mapfs.Stat(mname); err == nil && finfo.IsDir() {
+		return &fs.PathError{Op: "open", Path: name, Err: errIsDir}
+	}
+	if finfo, err := mapfs.Stat(path.Dir(mname)); err != nil || !finfo.IsDir() {
+		return &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	m.deleteFile(mname)
+	m.env.Files["/"+mname] = bytes.Clone(data)
+	return nil
+}
+
+// deleteFile deletes the Files whose [mapName] is equal to the given name.
+func (m memFS) deleteFile(mname string) {
+	for name := range m.env.Files {
+		if mapName(name) == mname {
+			delete(m.env.Files, name)
+		}
+	}
+}
+
+// mapFS returns an [fstest.MapFS] containing the Files and the directories.
+func (m memFS) mapFS() fstest.MapFS {
+	mapfs := fstest.MapFS{}
+	for dir := range m.env.dirs {
+		mapfs[dir] = &fstest.MapFile{Mode: fs.ModeDir | 0755}
+	}
+	for name, data := range m.env.Files {
+		mapfs[mapName(name)] = &fstest.MapFile{Data: bytes.Clone(data), Mode: 0644}
+	}
+	return mapfs
+}
+
+// mapName returns the [fstest.MapFS] name corresponding to the given name,
+// which is relative to the root directory when not absolute.
+func mapName(name string) string {
+	mname := path.Clean("/" + filepath.ToSlash(name))
+	if mname == "/" {
+		return "."
+	}
+	return mname[1:]
+}
+
+// withPath replaces the path of a [*fs.PathError] with the given name,
+// such that errors mention the name used by the caller.
+func withPath(err error, name string) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		pathErr.Path = name
+	}
+	return err
+}
@@ -0,0 +1,177 @@
+// fs_test.go - file systems tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cliptest
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bassosimone/clip"
+	"github.com/google/go-cmp/cmp"
+)
+
+// readDirNames returns the names of the entries of the given directory.
+func readDirNames(t *testing.T, fsys clip.FS, name string) []string {
+	entries, err := fsys.ReadDir(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestMemFS(t *testing.T) {
+	t.Run("we read the Files using absolute and relative names", func(t *testing.T) {
+		env := NewEnv()
+		env.Files["/etc/hosts"] = []byte("127.0.0.1 localhost\n")
+		env.Files["greeting"] = []byte("hello")
+		fsys := env.FS()
+
+		for name, expect := range map[string]string{
+			"/etc/hosts":       "127.0.0.1 localhost\n",
+			"etc/../etc/hosts": "127.0.0.1 localhost\n",
+			"/greeting":        "hello",
+			"./greeting":       "hello",
+		} {
+			data, err := fsys.ReadFile(name)
+			if err != nil {
+				t.Fatal(name, err)
+			}
+			if diff := cmp.Diff(expect, string(data)); diff != "" {
+				t.Fatal(name, diff)
+			}
+		}
+
+		if diff := cmp.Diff([]string{"etc", "greeting"}, readDirNames(t, fsys, "/")); diff != "" {
+			t.Fatal(diff)
+		}
+		finfo, err := fsys.Stat("/etc")
+		if err != nil || !finfo.IsDir() {
+			t.Fatal("expected a directory", finfo, err)
+		}
+		file, err := fsys.Open("/greeting")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		if data, err := io.ReadAll(file); err != nil || string(data) != "hello" {
+			t.Fatal("unexpected result", string(data), err)
+		}
+	})
+
+	t.Run("errors mention the name used by the caller", func(t *testing.T) {
+		_, err := NewEnv().FS().ReadFile("./nonexistent")
+		var pathErr *fs.PathError
+		if !errors.As(err, &pathErr) || !errors.Is(err, fs.ErrNotExist) || pathErr.Path != "./nonexistent" {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("we write the Files", func(t *testing.T) {
+		env := NewEnv()
+		env.Files["/etc/hosts"] = []byte("old")
+		fsys := env.FS()
+
+		if err := fsys.WriteFile("/home/user/.config/tool/config.toml", nil, 0600); !errors.Is(err, fs.ErrNotExist) {
+			t.Fatal("expected", fs.ErrNotExist, "got", err)
+		}
+		if err := fsys.MkdirAll("/home/user/.config/tool", 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := fsys.WriteFile("/home/user/.config/tool/config.toml", []byte("x = 1\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := fsys.WriteFile("etc/hosts", []byte("new"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := fsys.WriteFile("/home", nil, 0600); !errors.Is(err, errIsDir) {
+			t.Fatal("expected", errIsDir, "got", err)
+		}
+		if err := fsys.MkdirAll("/etc/hosts/x", 0700); !errors.Is(err, errNotDir) {
+			t.Fatal("expected", errNotDir, "got", err)
+		}
+
+		expect := map[string][]byte{
+			"/etc/hosts":                          []byte("new"),
+			"/home/user/.config/tool/config.toml": []byte("x = 1\n"),
+		}
+		if diff := cmp.Diff(expect, env.Files); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("we remove files and empty directories", func(t *testing.T) {
+		env := NewEnv()
+		env.Files["/dir/file"] = []byte("x")
+		fsys := env.FS()
+		if err := fsys.MkdirAll("/empty", 0700); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := fsys.Remove("/dir"); !errors.Is(err, errNotEmpty) {
+			t.Fatal("expected", errNotEmpty, "got", err)
+		}
+		if err := fsys.Remove("/"); !errors.Is(err, errNotEmpty) {
+			t.Fatal("expected", errNotEmpty, "got", err)
+		}
+		for _, name := range []string{"/dir/file", "/empty"} {
+			if err := fsys.Remove(name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := fsys.Remove("/dir"); !errors.Is(err, fs.ErrNotExist) {
+			t.Fatal("expected", fs.ErrNotExist, "got", err)
+		}
+		if len(env.Files) != 0 || len(readDirNames(t, fsys, "/")) != 0 {
+			t.Fatal("expected an empty file system", env.Files)
+		}
+	})
+}
+
+func TestDirFS(t *testing.T) {
+	env := NewEnv()
+	env.Dir = t.TempDir()
+	fsys := env.FS()
+
+	if err := fsys.MkdirAll("a/b", 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.WriteFile("a/b/file.txt", []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(env.Dir, "a", "b", "file.txt"))
+	if err != nil || string(data) != "hello" {
+		t.Fatal("unexpected result", string(data), err)
+	}
+
+	data, err = fsys.ReadFile(filepath.Join(env.Dir, "a", "b", "file.txt"))
+	if err != nil || string(data) != "hello" {
+		t.Fatal("unexpected result", string(data), err)
+	}
+	if diff := cmp.Diff([]string{"file.txt"}, readDirNames(t, fsys, "a/b")); diff != "" {
+		t.Fatal(diff)
+	}
+	if finfo, err := fsys.Stat("a"); err != nil || !finfo.IsDir() {
+		t.Fatal("expected a directory", finfo, err)
+	}
+	file, err := fsys.Open("a/b/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	if err := fsys.Remove("a/b/file.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fsys.Stat("a/b/file.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected", fs.ErrNotExist, "got", err)
+	}
+}
@@ -0,0 +1,104 @@
+// doc.go - package documentation.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+/*
+Package testscript runs end-to-end tests of [clip] based programs
+written as scripts inside [txtar] archives.
+
+Each script runs the [*clip.RootCommand] in process using a [*cliptest.Env],
+whose files and working directory refer to a sandbox directory containing
+the files inside the archive. The archive comment contains the script:
+
+	# the help flag prints the usage
+	exec minirbmk git clone --help
+	stdout '^Usage: minirbmk git clone'
+	! stderr .
+
+	# a missing argument is a usage error
+	! exec minirbmk git clone
+	exit 2
+	stderr 'too few positional arguments'
+
+	# the configuration file provides the defaults
+	exec minirbmk git clone REPO
+	stdout 'branch: main'
+
+	-- .config/minirbmk/config.toml --
+	[git.clone]
+	branch = "main"
+
+Use [Run] inside a test function to run all the scripts inside a directory:
+
+	func TestScripts(t *testing.T) {
+		testscript.Run(t, testscript.Params{
+			Dir: "testdata",
+			Commands: map[string]testscript.CommandFunc{
+				"minirbmk": func(env *cliptest.Env) *clip.RootCommand[*cliptest.Env] {
+					return newRootCommand(env)
+				},
+			},
+		})
+	}
+
+# Syntax
+
+We split each line into words separated by spaces. Single quotes prevent
+splitting and expansion, and two consecutive single quotes inside a quoted
+string represent a single quote. Outside of quotes, we expand $NAME and ${NAME}
+with the value of the script environment variables, and a word starting
+with `#` starts a comment extending until the end of the line.
+
+A command may start with a `!` word, meaning that it must fail, or with
+a `?` word, meaning that it may either succeed or fail.
+
+# Environment
+
+Initially, the environment contains WORK and HOME, which both refer to
+the sandbox directory. Therefore, the archive may contain configuration
+files (see [clip.LoadConfig]). Each command started by exec receives a copy
+of the environment and the standard input configured by stdin, if any. The
+sandbox directory is also the working directory and the root of the relative
+names used by the [clip.FS] of the [*cliptest.Env].
+
+# Commands
+
+The following commands are available:
+
+  - cmp FILE1 FILE2 checks whether the files have the same content, where
+    the names `stdout` and `stderr` refer to the output of the most
+    recent command. With `!`, checks whether the content differs.
+
+  - env [KEY=VALUE...] sets the given environment variables or, without
+    arguments, logs all the environment variables.
+
+  - exec PROGRAM [ARGS...] [&] runs the [*clip.RootCommand] registered for
+    PROGRAM using the given arguments and checks whether the exit status is
+    zero. With `!`, checks whether the exit status is nonzero. With `?`,
+    accepts any exit status. With a trailing `&`, runs the command in
+    the background, and `wait` checks the exit status. At most one command
+    at a time may run in the background.
+
+  - exists FILE... checks whether the files exist. With `!`, checks
+    whether the files do not exist.
+
+  - exit STATUS checks whether the exit status of the most recent command
+    is equal to STATUS (e.g., 2 for usage errors, see [clip.ExitCode]).
+
+  - kill [-SIGNAL] waits for the background command to register for receiving
+    signals (e.g., using the AutoCancel field of [clip.RootCommand]) and then
+    delivers the given signal (HUP, INT, KILL, QUIT, or TERM, optionally
+    prefixed by SIG), which is INT by default.
+
+  - stderr REGEXP checks whether the standard error of the most recent
+    command matches the regular expression, where `^` and `$` match at the
+    beginning and at the end of each line. With `!`, checks whether it
+    does not match.
+
+  - stdin FILE uses the content of the given file as the standard input
+    of the next command.
+
+  - stdout REGEXP is like stderr but checks the standard output.
+
+  - wait waits for the background command to terminate.
+*/
+package testscript
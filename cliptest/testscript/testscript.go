@@ -0,0 +1,518 @@
+// testscript.go - test scripts runner.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package testscript
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/cliptest"
+	"github.com/bassosimone/clip/pkg/txtar"
+	"github.com/google/go-cmp/cmp"
+)
+
+// CommandFunc creates the [*clip.RootCommand] that `exec` runs using the given [*cliptest.Env].
+type CommandFunc func(env *cliptest.Env) *clip.RootCommand[*cliptest.Env]
+
+// Params contains the parameters for [Run].
+type Params struct {
+	// --- mandatory fields ---
+
+	// Dir is the directory containing the `*.txtar` scripts.
+	Dir string
+
+	// Commands maps each program name that `exec` may run to
+	// the [CommandFunc] creating its [*clip.RootCommand].
+	Commands map[string]CommandFunc
+}
+
+// Run runs each `*.txtar` script inside the directory specified by the
+// Dir field of [Params] as a subtest named after the script file.
+//
+// Each script runs inside a new temporary directory, which the script may
+// refer to as $WORK, containing the files inside the archive. When a script
+// fails, we log the commands executed so far and their output.
+func Run(t *testing.T, p Params) {
+	filenames, err := filepath.Glob(filepath.Join(p.Dir, "*.txtar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filenames) <= 0 {
+		t.Fatal("no scripts inside", p.Dir)
+	}
+	for _, filename := range filenames {
+		name := strings.TrimSuffix(filepath.Base(filename), ".txtar")
+		t.Run(name, func(t *testing.T) {
+			var log strings.Builder
+			if err := runFile(&p, filename, t.TempDir(), &log); err != nil {
+				t.Log("\n" + log.String())
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// runFile runs the script inside the given file using the given
+// working directory and writing the log to the given writer.
+func runFile(p *Params, filename, workdir string, log io.Writer) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return runArchive(p, filepath.Base(filename), txtar.Parse(data), workdir, log)
+}
+
+// runArchive runs the script inside the given archive using the given working
+// directory and writing the log to the given writer. We use the name to
+// report the location of errors, which we return using the form `name:line: command: error`.
+func runArchive(p *Params, name string, archive *txtar.Archive, workdir string, log io.Writer) error {
+	s := &script{
+		background: nil,
+		environ:    map[string]string{"HOME": workdir, "WORK": workdir},
+		exitcode:   0,
+		log:        log,
+		params:     p,
+		stderr:     "",
+		stdin:      nil,
+		stdout:     "",
+		workdir:    workdir,
+	}
+	for _, file := range archive.Files {
+		if err := s.writeFile(file); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	for idx, line := range strings.Split(string(archive.Comment), "\n") {
+		if err := s.runLine(line); err != nil {
+			return fmt.Errorf("%s:%d: %s: %w", name, idx+1, strings.TrimSpace(line), err)
+		}
+	}
+	if s.background != nil {
+		return fmt.Errorf("%s: background command still running: use wait", name)
+	}
+	return nil
+}
+
+// expectation is the expected result of a script command.
+type expectation int
+
+const (
+	// expectSuccess means that the command must succeed.
+	expectSuccess = expectation(iota)
+
+	// expectFailure means that the command must fail (i.e., `!`).
+	expectFailure
+
+	// expectAny means that the command may either succeed or fail (i.e., `?`).
+	expectAny
+)
+
+// script is the state of a running script.
+type script struct {
+	// background is the command running in the background, if any.
+	background *execution
+
+	// environ contains the environment variables.
+	environ map[string]string
+
+	// exitcode is the exit status of the most recent command.
+	exitcode int
+
+	// log is where we write the log.
+	log io.Writer
+
+	// params contains the parameters.
+	params *Params
+
+	// stderr is the standard error of the most recent command.
+	stderr string
+
+	// stdin is the standard input for the next command.
+	stdin []byte
+
+	// stdout is the standard output of the most recent command.
+	stdout string
+
+	// workdir is the working directory.
+	workdir string
+}
+
+// scriptCommand is a command available to scripts.
+type scriptCommand struct {
+	// prefixes contains the prefixes (i.e., `!` and `?`) the command accepts.
+	prefixes []string
+
+	// run runs the command with the given expectation and arguments.
+	run func(s *script, expect expectation, args []string) error
+}
+
+// scriptCommands contains the commands available to scripts.
+var scriptCommands = map[string]scriptCommand{
+	"cmp":    {prefixes: []string{"!"}, run: (*script).cmdCmp},
+	"env":    {prefixes: nil, run: (*script).cmdEnv},
+	"exec":   {prefixes: []string{"!", "?"}, run: (*script).cmdExec},
+	"exists": {prefixes: []string{"!"}, run: (*script).cmdExists},
+	"exit":   {prefixes: nil, run: (*script).cmdExit},
+	"kill":   {prefixes: nil, run: (*script).cmdKill},
+	"stderr": {prefixes: []string{"!"}, run: (*script).cmdStderr},
+	"stdin":  {prefixes: nil, run: (*script).cmdStdin},
+	"stdout": {prefixes: []string{"!"}, run: (*script).cmdStdout},
+	"wait":   {prefixes: nil, run: (*script).cmdWait},
+}
+
+// runLine runs the given script line.
+func (s *script) runLine(line string) error {
+	words, err := splitWords(line, s.lookupEnv)
+	if err != nil {
+		return err
+	}
+	if len(words) <= 0 {
+		return nil
+	}
+	fmt.Fprintf(s.log, "> %s\n", strings.TrimSpace(line))
+
+	var prefix string
+	expect := expectSuccess
+	switch words[0] {
+	case "!":
+		prefix, expect, words = words[0], expectFailure, words[1:]
+	case "?":
+		prefix, expect, words = words[0], expectAny, words[1:]
+	}
+	if len(words) <= 0 {
+		return errors.New("missing command")
+	}
+
+	command, found := scriptCommands[words[0]]
+	if !found {
+		return fmt.Errorf("unknown command: %s", words[0])
+	}
+	if prefix != "" && !slices.Contains(command.prefixes, prefix) {
+		return fmt.Errorf("%s does not support the %s prefix", words[0], prefix)
+	}
+	return command.run(s, expect, words[1:])
+}
+
+// lookupEnv returns the value of the given environment variable.
+func (s *script) lookupEnv(name string) string {
+	return s.environ[name]
+}
+
+// path returns the path of the given file name relative to the working directory.
+func (s *script) path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(s.workdir, name)
+}
+
+// readFile reads the given file, where `stdout` and `stderr` refer to
+// the standard output and standard error of the most recent command.
+func (s *script) readFile(name string) ([]byte, error) {
+	switch name {
+	case "stdout":
+		return []byte(s.stdout), nil
+	case "stderr":
+		return []byte(s.stderr), nil
+	default:
+		return os.ReadFile(s.path(name))
+	}
+}
+
+// writeFile writes the given archive file inside the working directory.
+func (s *script) writeFile(file txtar.File) error {
+	name := filepath.FromSlash(file.Name)
+	if !filepath.IsLocal(name) {
+		return fmt.Errorf("invalid file name: %s", file.Name)
+	}
+	path := s.path(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, file.Data, 0600)
+}
+
+// cmdCmp implements `cmp FILE1 FILE2`.
+func (s *script) cmdCmp(expect expectation, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: cmp FILE1 FILE2")
+	}
+	data1, err := s.readFile(args[0])
+	if err != nil {
+		return err
+	}
+	data2, err := s.readFile(args[1])
+	if err != nil {
+		return err
+	}
+	diff := cmp.Diff(string(data2), string(data1))
+	switch {
+	case expect == expectSuccess && diff != "":
+		return fmt.Errorf("%s and %s differ (-%s +%s):\n%s", args[0], args[1], args[1], args[0], diff)
+	case expect == expectFailure && diff == "":
+		return fmt.Errorf("%s and %s are equal", args[0], args[1])
+	default:
+		return nil
+	}
+}
+
+// cmdEnv implements `env [KEY=VALUE...]`.
+func (s *script) cmdEnv(_ expectation, args []string) error {
+	if len(args) <= 0 {
+		for _, key := range slices.Sorted(maps.Keys(s.environ)) {
+			fmt.Fprintf(s.log, "%s=%s\n", key, s.environ[key])
+		}
+		return nil
+	}
+	for _, arg := range args {
+		key, value, found := strings.Cut(arg, "=")
+		if !found || key == "" {
+			return errors.New("usage: env [KEY=VALUE...]")
+		}
+		s.environ[key] = value
+	}
+	return nil
+}
+
+// cmdExec implements `exec PROGRAM [ARGS...] [&]`.
+func (s *script) cmdExec(expect expectation, args []string) error {
+	background := len(args) > 0 && args[len(args)-1] == "&"
+	if background {
+		args = args[:len(args)-1]
+	}
+	if len(args) <= 0 {
+		return errors.New("usage: exec PROGRAM [ARGS...] [&]")
+	}
+	newRoot, found := s.params.Commands[args[0]]
+	if !found {
+		return fmt.Errorf("unknown program: %s", args[0])
+	}
+	if background && s.background != nil {
+		return errors.New("another command is already running in the background")
+	}
+
+	env := cliptest.NewEnv(args...)
+	env.EnvVars = maps.Clone(s.environ)
+	env.Dir = s.workdir
+	env.StdinReader = bytes.NewReader(s.stdin)
+	s.stdin = nil
+
+	ex := &execution{
+		done:   make(chan struct{}),
+		env:    env,
+		err:    nil,
+		expect: expect,
+		result: nil,
+	}
+	go ex.run(newRoot)
+	if background {
+		s.background = ex
+		return nil
+	}
+	return s.finish(ex)
+}
+
+// cmdExists implements `exists FILE...`.
+func (s *script) cmdExists(expect expectation, args []string) error {
+	if len(args) <= 0 {
+		return errors.New("usage: exists FILE...")
+	}
+	for _, name := range args {
+		_, err := os.Stat(s.path(name))
+		switch {
+		case expect == expectSuccess && err != nil:
+			return fmt.Errorf("%s does not exist", name)
+		case expect == expectFailure && err == nil:
+			return fmt.Errorf("%s exists", name)
+		}
+	}
+	return nil
+}
+
+// cmdExit implements `exit STATUS`.
+func (s *script) cmdExit(_ expectation, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: exit STATUS")
+	}
+	exitcode, err := strconv.Atoi(args[0])
+	if err != nil {
+		return err
+	}
+	if s.exitcode != exitcode {
+		return fmt.Errorf("expected exit status %d, got %d", exitcode, s.exitcode)
+	}
+	return nil
+}
+
+// signalsByName maps the names accepted by `kill` to signals.
+var signalsByName = map[string]clip.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"KILL": syscall.SIGKILL,
+	"QUIT": syscall.SIGQUIT,
+	"TERM": syscall.SIGTERM,
+}
+
+// signalTimeout is the maximum time `kill` waits for the
+// background command to register for receiving signals.
+const signalTimeout = 10 * time.Second
+
+// cmdKill implements `kill [-SIGNAL]`.
+func (s *script) cmdKill(_ expectation, args []string) error {
+	sig := clip.Signal(syscall.SIGINT)
+	switch {
+	case len(args) == 1 && strings.HasPrefix(args[0], "-"):
+		value, found := signalsByName[strings.TrimPrefix(strings.TrimPrefix(args[0], "-"), "SIG")]
+		if !found {
+			return fmt.Errorf("unknown signal: %s", args[0])
+		}
+		sig = value
+	case len(args) != 0:
+		return errors.New("usage: kill [-SIGNAL]")
+	}
+	if s.background == nil {
+		return errors.New("no command running in the background")
+	}
+	select {
+	case <-s.background.env.SignalNotified():
+		s.background.env.SendSignal(sig)
+		return nil
+	case <-s.background.done:
+		return errors.New("the background command exited before receiving the signal")
+	case <-time.After(signalTimeout):
+		return errors.New("the background command does not handle signals")
+	}
+}
+
+// cmdStderr implements `stderr REGEXP`.
+func (s *script) cmdStderr(expect expectation, args []string) error {
+	return match(expect, "stderr", s.stderr, args)
+}
+
+// cmdStdin implements `stdin FILE`.
+func (s *script) cmdStdin(_ expectation, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: stdin FILE")
+	}
+	data, err := s.readFile(args[0])
+	if err != nil {
+		return err
+	}
+	s.stdin = data
+	return nil
+}
+
+// cmdStdout implements `stdout REGEXP`.
+func (s *script) cmdStdout(expect expectation, args []string) error {
+	return match(expect, "stdout", s.stdout, args)
+}
+
+// cmdWait implements `wait`.
+func (s *script) cmdWait(_ expectation, args []string) error {
+	if len(args) != 0 {
+		return errors.New("usage: wait")
+	}
+	if s.background == nil {
+		return nil
+	}
+	ex := s.background
+	s.background = nil
+	return s.finish(ex)
+}
+
+// match checks whether the given regular expression matches the given
+// content, which we read from the stream with the given name. We compile
+// the regular expression in multi-line mode, so `^` and `$` match at the
+// beginning and at the end of each line.
+func match(expect expectation, name, content string, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s REGEXP", name)
+	}
+	re, err := regexp.Compile("(?m)" + args[0])
+	if err != nil {
+		return err
+	}
+	found := re.MatchString(content)
+	switch {
+	case expect == expectSuccess && !found:
+		return fmt.Errorf("no match for %q in %s", args[0], name)
+	case expect == expectFailure && found:
+		return fmt.Errorf("unexpected match for %q in %s", args[0], name)
+	default:
+		return nil
+	}
+}
+
+// finish waits for the given [*execution] to terminate, logs its
+// result, and checks whether the result matches the expectation.
+func (s *script) finish(ex *execution) error {
+	<-ex.done
+	s.stdout = ex.env.StdoutBuffer.String()
+	s.stderr = ex.env.StderrBuffer.String()
+	s.exitcode = 0
+	if ex.result != nil {
+		s.stdout, s.stderr, s.exitcode = ex.result.Stdout, ex.result.Stderr, ex.result.ExitCode
+	}
+
+	if s.stdout != "" {
+		fmt.Fprintf(s.log, "[stdout]\n%s", s.stdout)
+	}
+	if s.stderr != "" {
+		fmt.Fprintf(s.log, "[stderr]\n%s", s.stderr)
+	}
+	fmt.Fprintf(s.log, "[exit status %d]\n", s.exitcode)
+
+	switch {
+	case ex.err != nil:
+		return ex.err
+	case ex.expect == expectSuccess && s.exitcode != 0:
+		return fmt.Errorf("unexpected exit status %d", s.exitcode)
+	case ex.expect == expectFailure && s.exitcode == 0:
+		return errors.New("unexpected success")
+	default:
+		return nil
+	}
+}
+
+// execution is a [*clip.RootCommand] started by `exec`.
+type execution struct {
+	// done is closed when the command terminates.
+	done chan struct{}
+
+	// env is the environment used by the command.
+	env *cliptest.Env
+
+	// err is the error caused by a panic other than exiting.
+	err error
+
+	// expect is the expected result.
+	expect expectation
+
+	// result is the result, which is nil in case of panic.
+	result *cliptest.Result
+}
+
+// run creates the root command using the given [CommandFunc] and runs it.
+func (ex *execution) run(newRoot CommandFunc) {
+	defer close(ex.done)
+	defer func() {
+		if r := recover(); r != nil {
+			ex.err = fmt.Errorf("the command panicked: %v", r)
+		}
+	}()
+	ex.result = ex.env.Run(newRoot(ex.env))
+}
@@ -0,0 +1,242 @@
+// testscript_test.go - test scripts runner tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package testscript
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/cliptest"
+	"github.com/bassosimone/clip/pkg/txtar"
+)
+
+// newTestRootCommand returns the root command of the test program.
+func newTestRootCommand(env *cliptest.Env) *clip.RootCommand[*cliptest.Env] {
+	leaf := func(fn func(ctx context.Context, args *clip.CommandArgs[*cliptest.Env]) error) clip.Command[*cliptest.Env] {
+		return &clip.LeafCommand[*cliptest.Env]{BriefDescriptionText: "Test command.", RunFunc: fn}
+	}
+	return &clip.RootCommand[*cliptest.Env]{
+		AutoCancel:       true,
+		InterruptMessage: "interrupted",
+		Command: &clip.DispatcherCommand[*cliptest.Env]{
+			BriefDescriptionText: "Test program.",
+			Commands: map[string]clip.Command[*cliptest.Env]{
+				"cat": leaf(func(ctx context.Context, args *clip.CommandArgs[*cliptest.Env]) error {
+					data, err := io.ReadAll(args.Env.Stdin())
+					for _, name := range args.Args {
+						var chunk []byte
+						chunk, err = args.Env.FS().ReadFile(name)
+						data = append(data, chunk...)
+					}
+					args.Env.Stdout().Write(data)
+					return err
+				}),
+				"echo": leaf(func(ctx context.Context, args *clip.CommandArgs[*cliptest.Env]) error {
+					fmt.Fprintf(args.Env.Stdout(), "%s\n", strings.Join(args.Args, " "))
+					return nil
+				}),
+				"fail": leaf(func(ctx context.Context, args *clip.CommandArgs[*cliptest.Env]) error {
+					return &clip.ExitError{Code: 3, Err: errors.New("mocked error")}
+				}),
+				"getenv": leaf(func(ctx context.Context, args *clip.CommandArgs[*cliptest.Env]) error {
+					value, _ := args.Env.LookupEnv(args.Args[0])
+					fmt.Fprintf(args.Env.Stdout(), "%s\n", value)
+					return nil
+				}),
+				"panic": leaf(func(ctx context.Context, args *clip.CommandArgs[*cliptest.Env]) error {
+					panic("mocked panic")
+				}),
+				"sleep": leaf(func(ctx context.Context, args *clip.CommandArgs[*cliptest.Env]) error {
+					<-ctx.Done()
+					return context.Cause(ctx)
+				}),
+				"stubborn": leaf(func(ctx context.Context, args *clip.CommandArgs[*cliptest.Env]) error {
+					<-ctx.Done()
+					select {} // ignore the cancellation
+				}),
+			},
+		},
+	}
+}
+
+// newTestParams returns the [*Params] for running the test program.
+func newTestParams() *Params {
+	return &Params{
+		Dir: "testdata",
+		Commands: map[string]CommandFunc{
+			"noauto": func(env *cliptest.Env) *clip.RootCommand[*cliptest.Env] {
+				root := newTestRootCommand(env)
+				root.AutoCancel = false
+				return root
+			},
+			"prog": newTestRootCommand,
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+	Run(t, *newTestParams())
+}
+
+func TestRunArchive(t *testing.T) {
+	type testcase struct {
+		name      string
+		script    string
+		expectErr string
+	}
+
+	cases := []testcase{
+		{
+			name:   "successful script",
+			script: "exec prog echo hello\nstdout '^hello$'\n! stderr .\nexit 0\n",
+		},
+
+		{
+			name:      "we report the location of failures",
+			script:    "# comment\n\nexec prog echo hello\nstdout goodbye\n",
+			expectErr: `test.txtar:4: stdout goodbye: no match for "goodbye" in stdout`,
+		},
+
+		{
+			name:      "exec fails on nonzero exit status",
+			script:    "exec prog fail",
+			expectErr: "test.txtar:1: exec prog fail: unexpected exit status 3",
+		},
+
+		{
+			name:      "! exec fails on success",
+			script:    "! exec prog echo",
+			expectErr: "test.txtar:1: ! exec prog echo: unexpected success",
+		},
+
+		{
+			name:   "? exec accepts any exit status",
+			script: "? exec prog fail\nexit 3\n? exec prog echo\nexit 0\n",
+		},
+
+		{
+			name:      "exit checks the exit status",
+			script:    "! exec prog nonexistent\nexit 1\n",
+			expectErr: "test.txtar:2: exit 1: expected exit status 1, got 2",
+		},
+
+		{
+			name:      "we report panics",
+			script:    "exec prog panic",
+			expectErr: "test.txtar:1: exec prog panic: the command panicked: mocked panic",
+		},
+
+		{
+			name:      "we reject unknown commands",
+			script:    "nonexistent",
+			expectErr: "test.txtar:1: nonexistent: unknown command: nonexistent",
+		},
+
+		{
+			name:      "we reject unknown programs",
+			script:    "exec nonexistent",
+			expectErr: "test.txtar:1: exec nonexistent: unknown program: nonexistent",
+		},
+
+		{
+			name:      "we reject unsupported prefixes",
+			script:    "? stdout .",
+			expectErr: "test.txtar:1: ? stdout .: stdout does not support the ? prefix",
+		},
+
+		{
+			name:      "we reject a lone prefix",
+			script:    "!",
+			expectErr: "test.txtar:1: !: missing command",
+		},
+
+		{
+			name:      "we reject unterminated quotes",
+			script:    "stdout 'x",
+			expectErr: "test.txtar:1: stdout 'x: unterminated quoted string",
+		},
+
+		{
+			name:      "we reject file names outside the working directory",
+			script:    "-- ../x.txt --\n",
+			expectErr: "test.txtar: invalid file name: ../x.txt",
+		},
+
+		{
+			name:   "we extract the files and set the environment",
+			script: "exec prog cat a.txt $WORK/dir/b.txt\ncmp stdout expect.txt\n! cmp stdout a.txt\nexists a.txt dir/b.txt\n! exists c.txt\n-- a.txt --\na\n-- dir/b.txt --\nb\n-- expect.txt --\na\nb\n",
+		},
+
+		{
+			name:      "cmp reports the differences",
+			script:    "exec prog echo a\ncmp stdout b.txt\n-- b.txt --\nb\n",
+			expectErr: "test.txtar:2: cmp stdout b.txt: stdout and b.txt differ (-b.txt +stdout):",
+		},
+
+		{
+			name:   "we set the environment variables and the standard input",
+			script: "env NAME=world\nexec prog getenv NAME\nstdout '^world$'\nexec prog getenv HOME\nstdout '^'$WORK'$'\nstdin a.txt\nexec prog cat\nstdout '^a$'\nexec prog cat\n! stdout .\n-- a.txt --\na\n",
+		},
+
+		{
+			name:      "we check the standard error",
+			script:    "! exec prog fail\n! stderr 'mocked error'\n",
+			expectErr: `test.txtar:2: ! stderr 'mocked error': unexpected match for "mocked error" in stderr`,
+		},
+
+		{
+			name:   "kill interrupts the background command",
+			script: "! exec prog sleep &\nkill -SIGTERM\nwait\nexit 143\nstderr '^prog: signal: terminated$'\n",
+		},
+
+		{
+			name:      "we require wait for background commands",
+			script:    "exec prog echo &\n",
+			expectErr: "test.txtar: background command still running: use wait",
+		},
+
+		{
+			name:      "we run at most one background command",
+			script:    "exec prog echo &\nexec prog echo &\n",
+			expectErr: "test.txtar:2: exec prog echo &: another command is already running in the background",
+		},
+
+		{
+			name:      "kill requires a background command",
+			script:    "kill",
+			expectErr: "test.txtar:1: kill: no command running in the background",
+		},
+
+		{
+			name:      "kill rejects unknown signals",
+			script:    "kill -USR1",
+			expectErr: "test.txtar:1: kill -USR1: unknown signal: -USR1",
+		},
+
+		{
+			name:      "kill fails when the background command exits",
+			script:    "exec noauto echo &\nkill\n",
+			expectErr: "test.txtar:2: kill: the background command exited before receiving the signal",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var log strings.Builder
+			archive := txtar.Parse([]byte(tc.script))
+			err := runArchive(newTestParams(), "test.txtar", archive, t.TempDir(), &log)
+			switch {
+			case tc.expectErr == "" && err != nil:
+				t.Fatal("unexpected error", err, "\n"+log.String())
+			case tc.expectErr != "" && (err == nil || !strings.HasPrefix(err.Error(), tc.expectErr)):
+				t.Fatal("expected", tc.expectErr, "got", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+// words.go - script lines parsing.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package testscript
+
+import (
+	"errors"
+	"strings"
+)
+
+// errUnterminatedQuote indicates that a line contains an unterminated quote.
+var errUnterminatedQuote = errors.New("unterminated quoted string")
+
+// splitWords splits the given script line into words.
+//
+// We separate words using spaces and tabs. We consider text inside single
+// quotes literally, using two consecutive single quotes to represent a single
+// quote. Outside of quotes, we replace `$NAME` and `${NAME}` with the value
+// returned by lookup and we ignore the text following a `#` at the beginning
+// of a word. Two single quotes with nothing between them produce an empty word.
+func splitWords(line string, lookup func(name string) string) ([]string, error) {
+	var (
+		words   []string
+		current strings.Builder
+		inWord  bool
+	)
+	for idx := 0; idx < len(line); idx++ {
+		switch ch := line[idx]; {
+		case ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n':
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+
+		case ch == '#' && !inWord:
+			return words, nil
+
+		case ch == '\'':
+			inWord = true
+			for {
+				idx++
+				if idx >= len(line) {
+					return nil, errUnterminatedQuote
+				}
+				if line[idx] != '\'' {
+					current.WriteByte(line[idx])
+					continue
+				}
+				if idx+1 < len(line) && line[idx+1] == '\'' {
+					current.WriteByte('\'')
+					idx++
+					continue
+				}
+				break
+			}
+
+		case ch == '$':
+			inWord = true
+			name, size := scanVariable(line[idx+1:])
+			if size <= 0 {
+				current.WriteByte(ch)
+				continue
+			}
+			current.WriteString(lookup(name))
+			idx += size
+
+		default:
+			inWord = true
+			current.WriteByte(ch)
+		}
+	}
+	if inWord {
+		words = append(words, current.String())
+	}
+	return words, nil
+}
+
+// scanVariable scans the variable name at the beginning of the given text,
+// which follows a `$`, and returns the name and the number of bytes consumed,
+// which is zero when the text does not start with a valid variable name.
+func scanVariable(text string) (string, int) {
+	if strings.HasPrefix(text, "{") {
+		end := strings.IndexByte(text, '}')
+		if end <= 1 {
+			return "", 0
+		}
+		return text[1:end], end + 1
+	}
+	var size int
+	for size < len(text) && isVariableByte(text[size]) {
+		size++
+	}
+	return text[:size], size
+}
+
+// isVariableByte returns whether the given byte may be part of a variable name.
+func isVariableByte(ch byte) bool {
+	return ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9')
+}
@@ -0,0 +1,86 @@
+// words_test.go - script lines parsing tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package testscript
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSplitWords(t *testing.T) {
+	type testcase struct {
+		name      string
+		line      string
+		expect    []string
+		expectErr error
+	}
+
+	cases := []testcase{
+		{
+			name:   "empty line",
+			line:   "",
+			expect: nil,
+		},
+
+		{
+			name:   "comment line",
+			line:   "  # comment",
+			expect: nil,
+		},
+
+		{
+			name:   "we split using spaces and tabs",
+			line:   "exec  prog\t--help  ",
+			expect: []string{"exec", "prog", "--help"},
+		},
+
+		{
+			name:   "we ignore trailing comments but not # inside words",
+			line:   "stdout a#b # comment",
+			expect: []string{"stdout", "a#b"},
+		},
+
+		{
+			name:   "we do not split or expand quoted text",
+			line:   "stdout 'Usage: $HOME # x' 'it''s' '' a'b c'd",
+			expect: []string{"stdout", "Usage: $HOME # x", "it's", "", "ab cd"},
+		},
+
+		{
+			name:   "we expand variables",
+			line:   "exec prog $HOME/x ${HOME}y $MISSING. $ $1",
+			expect: []string{"exec", "prog", "/home/x", "/homey", ".", "$", "one"},
+		},
+
+		{
+			name:   "we do not expand invalid braced variables",
+			line:   "x ${} ${HOME",
+			expect: []string{"x", "${}", "${HOME"},
+		},
+
+		{
+			name:      "we reject unterminated quotes",
+			line:      "stdout 'Usage:",
+			expectErr: errUnterminatedQuote,
+		},
+	}
+
+	lookup := func(name string) string {
+		return map[string]string{"HOME": "/home", "1": "one"}[name]
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			words, err := splitWords(tc.line, lookup)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatal("expected", tc.expectErr, "got", err)
+			}
+			if diff := cmp.Diff(tc.expect, words); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
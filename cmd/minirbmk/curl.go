@@ -14,18 +14,16 @@ import (
 )
 
 // curlMain is the main entry point for the curl leaf command.
-func curlMain(ctx context.Context, args *clip.CommandArgs[*clip.StdlibExecEnv]) error {
+func curlMain[T clip.ExecEnv](ctx context.Context, args *clip.CommandArgs[T]) error {
 	// Create flag set
-	fset := nflag.NewFlagSet(args.CommandName, nflag.ExitOnError)
+	fset := clip.NewFlagSet(args, nflag.ExitOnError)
 	fset.Description = args.Command.BriefDescription()
 	fset.PositionalArgumentsUsage = "URL ..."
 	fset.MinPositionalArgs = 1
 	fset.MaxPositionalArgs = math.MaxInt
 
-	// Not strictly needed in production but necessary for testing
-	fset.Exit = args.Env.Exit
-	fset.Stderr = args.Env.Stderr()
-	fset.Stdout = args.Env.Stdout()
+	// Allow setting flags using environment variables (e.g., MINIRBMK_CURL_CACERT)
+	fset.EnvVarPrefix = "MINIRBMK_CURL"
 
 	// Add the --cacert flag
 	cacertFlag := fset.StringFlag("cacert", 0, "Add part to the CA certificate file.")
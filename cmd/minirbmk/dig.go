@@ -13,9 +13,9 @@ import (
 )
 
 // digMain is the main entry point for the dig leaf command.
-func digMain(ctx context.Context, args *clip.CommandArgs[*clip.StdlibExecEnv]) error {
+func digMain[T clip.ExecEnv](ctx context.Context, args *clip.CommandArgs[T]) error {
 	// Create flag set
-	fset := nflag.NewFlagSet(args.CommandName, nflag.ExitOnError)
+	fset := clip.NewFlagSet(args, nflag.ExitOnError)
 	fset.Description = args.Command.BriefDescription()
 	fset.PositionalArgumentsUsage = "[@server] name [type] [class]"
 	fset.MinPositionalArgs = 1
@@ -23,11 +23,6 @@ func digMain(ctx context.Context, args *clip.CommandArgs[*clip.StdlibExecEnv]) e
 	fset.LongFlagPrefix = "+"
 	fset.ShortFlagPrefix = "-" // already the default, but set explicitly for clarity
 
-	// Not strictly needed in production but necessary for testing
-	fset.Exit = args.Env.Exit
-	fset.Stderr = args.Env.Stderr()
-	fset.Stdout = args.Env.Stdout()
-
 	// Add the -4 flag
 	fourFlag := fset.BoolFlag("", '4', "Only use IPv4")
 
@@ -6,6 +6,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 
 	"github.com/bassosimone/clip"
 	"github.com/bassosimone/clip/pkg/assert"
@@ -13,19 +14,15 @@ import (
 )
 
 // gitInitMain is the main entry point for the 'git init' leaf command.
-func gitInitMain(ctx context.Context, args *clip.CommandArgs[*clip.StdlibExecEnv]) error {
+func gitInitMain[T clip.ExecEnv](ctx context.Context, args *clip.CommandArgs[T]) error {
 	// Create flag set
-	fset := nflag.NewFlagSet(args.CommandName, nflag.ExitOnError)
+	fset := clip.NewFlagSet(args, nflag.ExitOnError)
 	fset.Description = args.Command.BriefDescription()
 	fset.PositionalArgumentsUsage = "[directory]"
+	fset.PositionalArgumentsCompletion = []nflag.CompletionFunc{nflag.CompleteDirs}
 	fset.MinPositionalArgs = 0
 	fset.MaxPositionalArgs = 1
 
-	// Not strictly needed in production but necessary for testing
-	fset.Exit = args.Env.Exit
-	fset.Stderr = args.Env.Stderr()
-	fset.Stdout = args.Env.Stdout()
-
 	// Add the --branch, -b flag
 	branchFlag := fset.StringFlag("branch", 'b', "Branch name")
 
@@ -44,25 +41,36 @@ func gitInitMain(ctx context.Context, args *clip.CommandArgs[*clip.StdlibExecEnv
 
 	// Print the positional arguments
 	fmt.Fprintf(args.Env.Stdout(), "%v\n", fset.Args())
+
+	// Print the repository directory, which is relative to the working directory
+	dir, err := clip.Extend(args.Env).Getwd()
+	if err != nil {
+		return err
+	}
+	if positional := fset.Args(); len(positional) > 0 {
+		if filepath.IsAbs(positional[0]) {
+			dir = positional[0]
+		} else {
+			dir = filepath.Join(dir, positional[0])
+		}
+	}
+	fmt.Fprintf(args.Env.Stdout(), "directory: %s\n", dir)
 	return nil
 }
 
 // gitCloneMain is the main entry point for the 'git clone' leaf command.
-func gitCloneMain(ctx context.Context, args *clip.CommandArgs[*clip.StdlibExecEnv]) error {
+func gitCloneMain[T clip.ExecEnv](ctx context.Context, args *clip.CommandArgs[T]) error {
 	// Create flag set
-	fset := nflag.NewFlagSet(args.CommandName, nflag.ExitOnError)
+	fset := clip.NewFlagSet(args, nflag.ExitOnError)
 	fset.Description = args.Command.BriefDescription()
 	fset.PositionalArgumentsUsage = "<repository> [directory]"
+	fset.PositionalArgumentsCompletion = []nflag.CompletionFunc{nflag.CompleteNothing, nflag.CompleteDirs}
 	fset.MinPositionalArgs = 1
 	fset.MaxPositionalArgs = 2
 
-	// Not strictly needed in production but necessary for testing
-	fset.Exit = args.Env.Exit
-	fset.Stderr = args.Env.Stderr()
-	fset.Stdout = args.Env.Stdout()
-
 	// Add the -b flag
 	branchFlag := fset.StringFlag("branch", 'b', "Branch name")
+	fset.SetFlagCompletion("branch", 'b', nflag.CompleteChoices("main", "master"))
 
 	// Add the --help flag
 	fset.AutoHelp("help", 'h', "Print this help message and exit.")
@@ -70,6 +78,13 @@ func gitCloneMain(ctx context.Context, args *clip.CommandArgs[*clip.StdlibExecEn
 	// Add the -q, --quiet flag
 	quietFlag := fset.BoolFlag("quiet", 'q', "Run in quiet mode.")
 
+	// Load the defaults from the configuration file, possibly using --config
+	if flag, found := args.LookupPersistentFlag("config"); found && flag.Value.String() != "" {
+		clip.LoadConfigFile(args, fset, flag.Value.String())
+	} else {
+		clip.LoadConfig(args, fset)
+	}
+
 	// Parse the flags
 	assert.NotError(fset.Parse(args.Args))
 
@@ -6,46 +6,56 @@
 package main
 
 import (
+	"time"
+
 	"github.com/bassosimone/clip"
 	"github.com/bassosimone/clip/pkg/nflag"
 )
 
-// configurable for testing
-var env = clip.NewStdlibExecEnv()
-
 func main() {
+	env := clip.NewStdlibExecEnv()
+	newRootCommand(env).Main(env)
+}
+
+// newRootCommand creates the root command using the given [clip.ExecEnv],
+// which allows the tests to use an in-memory [clip.ExecEnv].
+func newRootCommand[T clip.ExecEnv](env T) *clip.RootCommand[T] {
 	// Define the overall suite version
 	const version = "0.1.0"
 
 	// Create the curl leaf command
-	curlCmd := &clip.LeafCommand[*clip.StdlibExecEnv]{
+	curlCmd := &clip.LeafCommand[T]{
 		BriefDescriptionText: "Utility to transfer URLs.",
-		RunFunc:              curlMain,
+		RunFunc:              curlMain[T],
+		UsesNewFlagSet:       true,
 	}
 
 	// Create the dig leaf command
-	digCmd := &clip.LeafCommand[*clip.StdlibExecEnv]{
+	digCmd := &clip.LeafCommand[T]{
 		BriefDescriptionText: "Utility to query the DNS.",
-		RunFunc:              digMain,
+		RunFunc:              digMain[T],
+		UsesNewFlagSet:       true,
 		HelpFlagValue:        "-h", // custom and dig specific
 	}
 
 	// Create the 'git clone' leaf command
-	gitCloneCmd := &clip.LeafCommand[*clip.StdlibExecEnv]{
+	gitCloneCmd := &clip.LeafCommand[T]{
 		BriefDescriptionText: "Clone a repository.",
-		RunFunc:              gitCloneMain,
+		RunFunc:              gitCloneMain[T],
+		UsesNewFlagSet:       true,
 	}
 
 	// Create the 'git init' leaf command.
-	gitInitCmd := &clip.LeafCommand[*clip.StdlibExecEnv]{
+	gitInitCmd := &clip.LeafCommand[T]{
 		BriefDescriptionText: "Init a repository.",
-		RunFunc:              gitInitMain,
+		RunFunc:              gitInitMain[T],
+		UsesNewFlagSet:       true,
 	}
 
 	// Create the git subcommand
-	gitCmd := &clip.DispatcherCommand[*clip.StdlibExecEnv]{
+	gitCmd := &clip.DispatcherCommand[T]{
 		BriefDescriptionText: "Utility to manage repositories.",
-		Commands: map[string]clip.Command[*clip.StdlibExecEnv]{
+		Commands: map[string]clip.Command[T]{
 			"clone": gitCloneCmd,
 			"init":  gitInitCmd,
 		},
@@ -55,21 +65,29 @@ func main() {
 		OptionsArgumentsSeparator: "--",
 	}
 
+	// Create the global flags, which apply to all the subcommands
+	globalFlags := nflag.NewFlagSet("minirbmk", nflag.ExitOnError)
+	globalFlags.EnvVarPrefix = "MINIRBMK"
+	globalFlags.StringFlag("config", 0, "Read the flags defaults from the given configuration file.")
+
 	// Create the root command
-	rootCmd := &clip.RootCommand[*clip.StdlibExecEnv]{
+	rootCmd := &clip.RootCommand[T]{
 		// Use a dispatcher dispatching to `git`, `curl`, and `dig`.
-		Command: &clip.DispatcherCommand[*clip.StdlibExecEnv]{
+		Command: &clip.DispatcherCommand[T]{
 
 			// This text is printed when help is requested
 			BriefDescriptionText: "A collection of UNIX command line tools.",
 
 			// Configure the dispatcher to dispatch by name
-			Commands: map[string]clip.Command[*clip.StdlibExecEnv]{
+			Commands: map[string]clip.Command[T]{
 				"curl": curlCmd,
 				"dig":  digCmd,
 				"git":  gitCmd,
 			},
 
+			// Allow users to type `minirbmk g` instead of `minirbmk git`
+			Aliases: map[string]string{"g": "git"},
+
 			// Cause the dispatcher to call [os.Exit] on error
 			ErrorHandling: nflag.ExitOnError,
 
@@ -83,13 +101,32 @@ func main() {
 
 			// Set the separator used between options and arguments
 			OptionsArgumentsSeparator: "--",
+
+			// Automatically define the completion subcommand
+			Completion: true,
+
+			// Run `minirbmk-foo` from the PATH when `foo` is unknown
+			ExternalCommands: true,
+
+			// Accept the global flags before or after any subcommand
+			PersistentFlags: globalFlags,
 		},
 
 		// Automatic signals handling: SIGINT and SIGTERM will
 		// cancel the context passed to leaf commands.
 		AutoCancel: true,
-	}
 
-	// Execute the root command
-	rootCmd.Main(env)
+		// Force exiting on the second signal or after five seconds.
+		GracePeriod:      5 * time.Second,
+		InterruptMessage: "interrupted, press Ctrl-C again to force",
+
+		// Allow orchestration tools to request JSON errors and help
+		// using either `--output-format json` or the environment.
+		OutputFormatEnvVar: "MINIRBMK_OUTPUT_FORMAT",
+		OutputFormatFlag:   true,
+
+		// Style the help using colors when the terminal supports them.
+		UsageColors: true,
+	}
+	return rootCmd
 }
@@ -3,7 +3,11 @@
 
 package clip
 
-import "context"
+import (
+	"context"
+
+	"github.com/bassosimone/clip/pkg/nflag"
+)
 
 // CommandArgs contains the arguments passed to a [Command].
 type CommandArgs[T ExecEnv] struct {
@@ -18,11 +22,66 @@ type CommandArgs[T ExecEnv] struct {
 	// CommandName is the name of the command.
 	CommandName string
 
+	// CanonicalCommandName is like CommandName but always contains the
+	// canonical names of the subcommands, rather than the aliases or the
+	// abbreviations the user typed (e.g., `minirbmk git clone` rather than
+	// `minirbmk g clone`). When empty, we use CommandName.
+	CanonicalCommandName string
+
 	// Env is the execution environment.
 	Env T
 
 	// Parent is the possibly `nil` parent command.
 	Parent Command[T]
+
+	// PersistentFlags contains the persistent flags of the enclosing
+	// [*DispatcherCommand] instances, from the outermost to the innermost,
+	// which they have already parsed. See the PersistentFlags field of
+	// [*DispatcherCommand] for more details.
+	PersistentFlags []*nflag.FlagSet
+
+	// OutputFormat is the [nflag.OutputFormat] selected by the [*RootCommand],
+	// which the [*DispatcherCommand] uses to print errors and usage, and which
+	// [NewFlagSet] uses to initialize the [*nflag.FlagSet] OutputFormat.
+	OutputFormat nflag.OutputFormat
+
+	// UsageColors indicates whether the [*RootCommand] allows styling the
+	// usage using ANSI colors, which the [*DispatcherCommand] and [NewFlagSet]
+	// only do when the [Terminal] returned by [DetectTerminal] supports them.
+	UsageColors bool
+
+	// Middleware contains the [Middleware] inherited from the [*RootCommand]
+	// and from the enclosing [*DispatcherCommand] instances, from the outermost
+	// to the innermost, which we apply when running the command.
+	Middleware []Middleware[T]
+
+	// root is the possibly nil command run by the [*RootCommand], which
+	// we use to know all the commands (e.g., to validate configuration
+	// files sections).
+	root Command[T]
+
+	// inspecting indicates that we are running the command to
+	// inspect its flags rather than to execute it.
+	inspecting bool
+}
+
+// canonicalCommandName returns the CanonicalCommandName, if set, or the CommandName.
+func (args *CommandArgs[T]) canonicalCommandName() string {
+	if args.CanonicalCommandName != "" {
+		return args.CanonicalCommandName
+	}
+	return args.CommandName
+}
+
+// LookupPersistentFlag returns the long [*nflag.Flag] with the given name among
+// the PersistentFlags, preferring the ones of the innermost dispatcher.
+func (args *CommandArgs[T]) LookupPersistentFlag(name string) (*nflag.Flag, bool) {
+	for idx := len(args.PersistentFlags) - 1; idx >= 0; idx-- {
+		if flag, found := args.PersistentFlags[idx].LookupFlagLong(name); found {
+			return flag, true
+		}
+	}
+	return nil, false
 }
 
 // Command is the generic command interface.
@@ -0,0 +1,444 @@
+// complete.go - dynamic shell completion.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// completionResult is the result of completing a command line.
+type completionResult struct {
+	// candidates contains the completion candidates.
+	candidates []string
+
+	// directive tells the shell how to handle the candidates.
+	directive nflag.CompletionDirective
+}
+
+// CompleteCommand implements the hidden `__complete` command, which the
+// scripts generated by [WriteCompletion] invoke to obtain completions.
+//
+// The arguments are the words following the program name on the command
+// line, where the last argument is the word being completed (possibly
+// empty). The command prints the candidates, one per line, followed by
+// a line containing a colon and the completion directive (e.g., `:1`).
+//
+// To know the flags defined by a command, we invoke it in inspection
+// mode, as documented by [NewFlagSet]. As a safety net for commands not
+// using [NewFlagSet], we pass them only the [Command] help flag.
+//
+// We also complete the persistent flags, including the ones of the
+// enclosing commands (e.g., the `--output-format` flag of the [*RootCommand]),
+// which we do not parse when invoking this command, so that they remain
+// on the command line we complete.
+//
+// The zero value is not ready to use. Initialize the mandatory fields.
+type CompleteCommand[T ExecEnv] struct {
+	// --- mandatory fields ---
+
+	// Command is the mandatory command whose command line we complete.
+	Command Command[T]
+
+	// --- optional fields ---
+
+	// CommandName is the optional name of Command. When unset, we use
+	// the command name without its last word (i.e., `__complete`).
+	CommandName string
+}
+
+var _ Command[*StdlibExecEnv] = &CompleteCommand[*StdlibExecEnv]{}
+
+// BriefDescription implements [Command].
+func (c *CompleteCommand[T]) BriefDescription() string {
+	return "Print the shell completion candidates."
+}
+
+// HelpFlag implements [Command].
+func (c *CompleteCommand[T]) HelpFlag() string {
+	return "--help"
+}
+
+// Run implements [Command].
+func (c *CompleteCommand[T]) Run(ctx context.Context, args *CommandArgs[T]) error {
+	// Determine the name of the command to complete.
+	commandName := c.CommandName
+	if commandName == "" {
+		commandName = args.CommandName
+		if idx := strings.LastIndex(commandName, " "); idx >= 0 {
+			commandName = commandName[:idx]
+		}
+	}
+
+	// Separate the word being completed from the preceding words.
+	words, word := args.Args, ""
+	if len(words) > 0 {
+		words, word = words[:len(words)-1], words[len(words)-1]
+	}
+
+	// Complete the command line.
+	result := completeCommandLine(ctx, &CommandArgs[T]{
+		Args:            words,
+		Command:         c.Command,
+		CommandName:     commandName,
+		Env:             args.Env,
+		Parent:          nil,
+		PersistentFlags: args.PersistentFlags,
+	}, word)
+
+	// Print the candidates followed by the directive.
+	var sb strings.Builder
+	for _, candidate := range result.candidates {
+		fmt.Fprintf(&sb, "%s\n", candidate)
+	}
+	fmt.Fprintf(&sb, ":%d\n", result.directive)
+	_, err := fmt.Fprint(args.Env.Stdout(), sb.String())
+	return err
+}
+
+// SupportsSubcommands implements [Command].
+func (c *CompleteCommand[T]) SupportsSubcommands() bool {
+	return false
+}
+
+// isCompleteCommandLine returns whether args invoke the `__complete` command
+// synthesized by cmd, in which case we must not parse the persistent flags,
+// since parsing would remove them from the command line to complete.
+func isCompleteCommandLine[T ExecEnv](cmd Command[T], args []string) bool {
+	dx, ok := cmd.(*DispatcherCommand[T])
+	return ok && dx.Completion && dx.Commands["__complete"] == nil && len(args) > 0 && args[0] == "__complete"
+}
+
+// completeCommandLine completes the given word, which follows the args.
+func completeCommandLine[T ExecEnv](ctx context.Context, args *CommandArgs[T], word string) completionResult {
+	// Dispatchers know their subcommands, so they can resolve the path.
+	if dx, ok := args.Command.(*DispatcherCommand[T]); ok {
+		return dx.complete(ctx, args, word)
+	}
+
+	// Otherwise, we need to inspect the command flags.
+	fset := InspectFlagSet(ctx, args)
+	if fset == nil {
+		return completionResult{directive: nflag.CompletionDirectiveDefault}
+	}
+	return completeFlags(ctx, fset, args.Args, word)
+}
+
+// completeFlags completes the given word, which follows the words, using
+// the flags and positional arguments defined by the given [*nflag.FlagSet].
+func completeFlags(ctx context.Context, fset *nflag.FlagSet, words []string, word string) completionResult {
+	// Count the positional arguments preceding the word.
+	var (
+		positionals int
+		separated   bool
+	)
+	for idx := 0; idx < len(words); idx++ {
+		entry := words[idx]
+		switch {
+		case separated || (fset.DisablePermute && positionals > 0):
+			positionals++
+
+		case fset.OptionsArgumentsSeparator != "" && entry == fset.OptionsArgumentsSeparator:
+			separated = true
+
+		case flagExpectingValue(fset, entry) != nil:
+			// When the word follows a flag requiring an argument, the word is its value
+			if idx == len(words)-1 {
+				return completeValue(ctx, flagExpectingValue(fset, entry).Complete, "", word)
+			}
+			idx++
+
+		case !isFlagLike(fset, entry):
+			positionals++
+		}
+	}
+
+	// Complete the word as a positional argument unless it looks like a flag.
+	if separated || (fset.DisablePermute && positionals > 0) || !isFlagLike(fset, word) {
+		return completeValue(ctx, fset.PositionalArgumentCompletion(positionals), "", word)
+	}
+
+	// Handle the `--file=FILE` case.
+	for _, pair := range completionFlags(fset) {
+		long := pair.LongFlag
+		if long == nil || !long.TakesArg {
+			continue
+		}
+		prefix := long.Option.Prefix + long.Option.Name + "="
+		if strings.HasPrefix(word, prefix) {
+			return completeValue(ctx, long.Complete, prefix, strings.TrimPrefix(word, prefix))
+		}
+	}
+
+	// Otherwise, complete the flag names.
+	var candidates []string
+	for _, pair := range completionFlags(fset) {
+		for _, flag := range []*nflag.Flag{pair.ShortFlag, pair.LongFlag} {
+			if flag == nil {
+				continue
+			}
+			if name := flag.Option.Prefix + flag.Option.Name; strings.HasPrefix(name, word) {
+				candidates = append(candidates, name)
+			}
+		}
+	}
+	return completionResult{candidates: candidates, directive: nflag.CompletionDirectiveNoFileFallback}
+}
+
+// completionFlags returns the flags defined by the given [*nflag.FlagSet]
+// followed by the flags of its GlobalFlagSets.
+func completionFlags(fset *nflag.FlagSet) []nflag.LongShortFlag {
+	flags := append([]nflag.LongShortFlag{}, fset.Flags()...)
+	for _, global := range fset.GlobalFlagSets {
+		flags = append(flags, global.Flags()...)
+	}
+	return flags
+}
+
+// isFlagLike returns whether the given word is nonempty and either starts with
+// a flag prefix or is a prefix of a flag (e.g., `-` with `--` long flags).
+func isFlagLike(fset *nflag.FlagSet, word string) bool {
+	if word == "" {
+		return false
+	}
+	for _, pair := range completionFlags(fset) {
+		for _, flag := range []*nflag.Flag{pair.ShortFlag, pair.LongFlag} {
+			if flag == nil {
+				continue
+			}
+			prefix := flag.Option.Prefix
+			if strings.HasPrefix(word, prefix) || strings.HasPrefix(prefix, word) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// completeValue completes the given word using the given [nflag.CompletionFunc],
+// which may be nil, and prepending the given prefix to the candidates.
+func completeValue(ctx context.Context, fn nflag.CompletionFunc, prefix, word string) completionResult {
+	if fn == nil {
+		return completionResult{directive: nflag.CompletionDirectiveDefault}
+	}
+	values, directive := fn(ctx, word)
+	candidates := make([]string, 0, len(values))
+	for _, value := range values {
+		candidates = append(candidates, prefix+value)
+	}
+	return completionResult{candidates: candidates, directive: directive}
+}
+
+// flagExpectingValue returns the flag expecting a value as the next command
+// line argument given the current argument, if any, or nil. We also consider
+// the flags of the GlobalFlagSets of the given [*nflag.FlagSet].
+func flagExpectingValue(fset *nflag.FlagSet, arg string) *nflag.Flag {
+	for _, fx := range append([]*nflag.FlagSet{fset}, fset.GlobalFlagSets...) {
+		if flag := flagSetExpectingValue(fx, arg); flag != nil {
+			return flag
+		}
+	}
+	return nil
+}
+
+// flagSetExpectingValue implements [flagExpectingValue] for a single [*nflag.FlagSet].
+func flagSetExpectingValue(fset *nflag.FlagSet, arg string) *nflag.Flag {
+	for _, pair := range fset.Flags() {
+		// Handle the `--file FILE` case
+		if long := pair.LongFlag; long != nil && long.TakesArg && arg == long.Option.Prefix+long.Option.Name {
+			return long
+		}
+
+		// Handle the `-vf FILE` case, noting that `-vfFILE` does not expect a value
+		short := pair.ShortFlag
+		if short == nil || !strings.HasPrefix(arg, short.Option.Prefix) {
+			continue
+		}
+		group := arg[len(short.Option.Prefix):]
+		for idx := 0; idx < len(group); idx++ {
+			flag, found := fset.LookupFlagShort(group[idx])
+			if !found || flag.Option.Prefix != short.Option.Prefix {
+				break
+			}
+			if flag.TakesArg {
+				if idx == len(group)-1 {
+					return flag
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// complete completes the given word, which follows the args.
+func (dx *DispatcherCommand[T]) complete(ctx context.Context, args *CommandArgs[T], word string) completionResult {
+	// Remove the persistent flags, including the ones of the enclosing
+	// commands, which may precede the subcommand name.
+	args = withPersistentFlags(args, dx.PersistentFlags, args.Args)
+	if result, found := completePersistentFlagValue(ctx, args.PersistentFlags, args.Args, word); found {
+		return result
+	}
+	others := args.Args
+	for _, fset := range args.PersistentFlags {
+		_, others = splitPersistentFlags(fset, others)
+	}
+	args = withPersistentFlags(args, nil, others)
+
+	// Without a subcommand name, the word is either a flag or the subcommand name.
+	tokens, commandIdx := dx.scanCommandLine(args.CommandName, args.Args)
+	if commandIdx < 0 {
+		return dx.completeSubcommandNames(args.PersistentFlags, word, true)
+	}
+	subName, subArgs := reorderCommandLine(tokens, commandIdx)
+
+	// Handle the synthesized `help` command, where `help NAME ARGS...`
+	// becomes `NAME help ARGS...` when NAME supports subcommands.
+	name, cmd, _ := dx.lookupCommand(args.CommandName, subName)
+	if cmd == nil && subName == "help" {
+		if len(subArgs) <= 0 {
+			return dx.completeSubcommandNames(args.PersistentFlags, word, false)
+		}
+		name, cmd, _ = dx.lookupCommand(args.CommandName, subArgs[0])
+		subArgs = append([]string{"help"}, subArgs[1:]...)
+		if cmd == nil || !cmd.SupportsSubcommands() {
+			return completionResult{directive: nflag.CompletionDirectiveNoFileFallback}
+		}
+	}
+
+	// Otherwise, the subcommand does not exist or is ambiguous.
+	if cmd == nil {
+		return completionResult{directive: nflag.CompletionDirectiveNoFileFallback}
+	}
+
+	// Continue completing using the subcommand.
+	nargs := &CommandArgs[T]{
+		Args:                 subArgs,
+		CanonicalCommandName: args.canonicalCommandName() + " " + dx.canonicalName(name),
+		Command:              cmd,
+		CommandName:          args.CommandName + " " + name,
+		Env:                  args.Env,
+		Parent:               dx,
+		PersistentFlags:      args.PersistentFlags,
+	}
+	return completeCommandLine(ctx, nargs, word)
+}
+
+// completePersistentFlagValue completes the word when it is the value of one of
+// the given persistent flags, either because it follows such a flag at the end of
+// the args or because it starts with the flag name followed by `=`. The boolean
+// is false when the word is not the value of a persistent flag.
+func completePersistentFlagValue(
+	ctx context.Context, persistent []*nflag.FlagSet, args []string, word string) (completionResult, bool) {
+	for _, fset := range persistent {
+		// Handle the `--file FILE` and `-vf FILE` cases, noting that the flag must
+		// precede the options-arguments separator, hence it must be persistent
+		flags, _ := splitPersistentFlags(fset, args)
+		if len(flags) > 0 && len(args) > 0 && flags[len(flags)-1] == args[len(args)-1] {
+			if flag := flagSetExpectingValue(fset, args[len(args)-1]); flag != nil {
+				return completeValue(ctx, flag.Complete, "", word), true
+			}
+		}
+
+		// Handle the `--file=FILE` case
+		for _, pair := range fset.Flags() {
+			long := pair.LongFlag
+			if long == nil || !long.TakesArg {
+				continue
+			}
+			prefix := long.Option.Prefix + long.Option.Name + "="
+			if strings.HasPrefix(word, prefix) {
+				return completeValue(ctx, long.Complete, prefix, strings.TrimPrefix(word, prefix)), true
+			}
+		}
+	}
+	return completionResult{}, false
+}
+
+// completeSubcommandNames returns the subcommand names matching the word, including
+// `help` when withHelp is true. When the word is a flag, we return the matching
+// flags among the ones handled by the dispatcher and the given persistent flags,
+// which include the PersistentFlags and the ones of the enclosing commands.
+func (dx *DispatcherCommand[T]) completeSubcommandNames(
+	persistent []*nflag.FlagSet, word string, withHelp bool) completionResult {
+	var candidates []string
+
+	// Handle the case where the word is a flag.
+	prefixes := append([]string{}, dx.OptionPrefixes...)
+	for _, fset := range persistent {
+		for _, pair := range fset.Flags() {
+			for _, flag := range []*nflag.Flag{pair.ShortFlag, pair.LongFlag} {
+				if flag != nil {
+					prefixes = append(prefixes, flag.Option.Prefix)
+				}
+			}
+		}
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(word, prefix) {
+			for _, name := range dx.flagNames(persistent) {
+				if strings.HasPrefix(name, word) {
+					candidates = append(candidates, name)
+				}
+			}
+			return completionResult{candidates: candidates, directive: nflag.CompletionDirectiveNoFileFallback}
+		}
+	}
+
+	// Otherwise, consider the subcommand names.
+	commands := dx.cloneSubcommandsForUsage()
+	if withHelp && commands["help"] == nil {
+		commands["help"] = dx
+	}
+	for _, name := range sortedSubcommandNames(commands) {
+		if strings.HasPrefix(name, word) {
+			candidates = append(candidates, name)
+		}
+	}
+	return completionResult{candidates: candidates, directive: nflag.CompletionDirectiveNoFileFallback}
+}
+
+// flagNames returns the names of the flags handled by the
+// dispatcher followed by the names of the given persistent flags.
+func (dx *DispatcherCommand[T]) flagNames(persistent []*nflag.FlagSet) []string {
+	var long, short bool
+	for _, prefix := range dx.OptionPrefixes {
+		long = long || prefix == "--"
+		short = short || prefix == "-"
+	}
+	var names []string
+	switch {
+	case long || short:
+		if long {
+			names = append(names, "--help")
+		}
+		if short {
+			names = append(names, "-h")
+		}
+		if long && dx.Version != "" {
+			names = append(names, "--version")
+		}
+
+	default:
+		for _, prefix := range dx.OptionPrefixes {
+			names = append(names, prefix+"help")
+			if dx.Version != "" {
+				names = append(names, prefix+"version")
+			}
+		}
+	}
+	for _, fset := range persistent {
+		for _, pair := range fset.Flags() {
+			for _, flag := range []*nflag.Flag{pair.ShortFlag, pair.LongFlag} {
+				if flag != nil {
+					names = append(names, flag.Option.Prefix+flag.Option.Name)
+				}
+			}
+		}
+	}
+	return names
+}
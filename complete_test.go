@@ -0,0 +1,234 @@
+// complete_test.go - dynamic shell completion tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCompleteCommand(t *testing.T) {
+	type testcase struct {
+		name   string
+		args   []string
+		expect string
+	}
+
+	cases := []testcase{
+		{
+			name:   "subcommand names",
+			args:   []string{""},
+			expect: "completion\ncurl\ngit\nhelp\nlegacy\nversion\n:1\n",
+		},
+
+		{
+			name:   "hidden subcommand names",
+			args:   []string{"s"},
+			expect: ":1\n",
+		},
+
+		{
+			name:   "subcommand names with prefix",
+			args:   []string{"g"},
+			expect: "git\n:1\n",
+		},
+
+		{
+			name:   "dispatcher flags",
+			args:   []string{"--"},
+			expect: "--help\n--version\n:1\n",
+		},
+
+		{
+			name:   "nested subcommand names",
+			args:   []string{"git", ""},
+			expect: "clone\nhelp\ninit\n:1\n",
+		},
+
+		{
+			name:   "subcommand names after help",
+			args:   []string{"help", "l"},
+			expect: "legacy\n:1\n",
+		},
+
+		{
+			name:   "nested subcommand names after help",
+			args:   []string{"help", "git", "c"},
+			expect: "clone\n:1\n",
+		},
+
+		{
+			name:   "leaf flags",
+			args:   []string{"git", "clone", "-"},
+			expect: "-h\n--help\n-b\n--branch\n--template\n-q\n--quiet\n:1\n",
+		},
+
+		{
+			name:   "leaf flags through an alias",
+			args:   []string{"g", "clone", "--b"},
+			expect: "--branch\n:1\n",
+		},
+
+		{
+			name:   "leaf flags through nested aliases",
+			args:   []string{"g", "cl", "--t"},
+			expect: "--template\n:1\n",
+		},
+
+		{
+			name:   "nested subcommand names after help and an alias",
+			args:   []string{"help", "g", "c"},
+			expect: "clone\n:1\n",
+		},
+
+		{
+			name:   "leaf long flags",
+			args:   []string{"git", "clone", "--q"},
+			expect: "--quiet\n:1\n",
+		},
+
+		{
+			name:   "flag value",
+			args:   []string{"git", "clone", "-qb", "ma"},
+			expect: "main\nmaster\n:1\n",
+		},
+
+		{
+			name:   "long flag value",
+			args:   []string{"git", "clone", "--branch", "d"},
+			expect: "develop\n:1\n",
+		},
+
+		{
+			name:   "long flag value after equal sign",
+			args:   []string{"git", "clone", "--branch=m"},
+			expect: "--branch=main\n--branch=master\n:1\n",
+		},
+
+		{
+			name:   "flag value without completion",
+			args:   []string{"git", "clone", "--template", ""},
+			expect: ":0\n",
+		},
+
+		{
+			name:   "first positional argument",
+			args:   []string{"git", "clone", "-q", "h"},
+			expect: "https://\n:1\n",
+		},
+
+		{
+			name:   "second positional argument",
+			args:   []string{"git", "clone", "-b", "main", "git@x", "-q", ""},
+			expect: ":3\n",
+		},
+
+		{
+			name:   "after the separator",
+			args:   []string{"git", "clone", "--", "-"},
+			expect: ":1\n",
+		},
+
+		{
+			name:   "command not using NewFlagSet",
+			args:   []string{"legacy", "-"},
+			expect: ":0\n",
+		},
+
+		{
+			name:   "we do not run commands not using NewFlagSet",
+			args:   []string{"legacy", "--"},
+			expect: ":0\n",
+		},
+
+		{
+			name:   "unknown subcommand",
+			args:   []string{"nonexistent", ""},
+			expect: ":1\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			env := NewStdlibExecEnv()
+			env.OSStdout = &stdout
+			env.OSExit = func(code int) {
+				t.Fatal("unexpected exit", code)
+			}
+			dx := newTestTree(nil)
+			err := dx.Run(context.Background(), &CommandArgs[*StdlibExecEnv]{
+				Args:        append([]string{"__complete"}, tc.args...),
+				Command:     dx,
+				CommandName: "tool",
+				Env:         env,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := stdout.String(); got != tc.expect {
+				t.Fatalf("expected %q, got %q", tc.expect, got)
+			}
+		})
+	}
+
+	t.Run("we complete the persistent flags of the root command", func(t *testing.T) {
+		cases := []struct {
+			args   []string
+			expect string
+		}{
+			{args: []string{"--out"}, expect: "--output-format\n:1\n"},
+			{args: []string{"git", "--out"}, expect: "--output-format\n:1\n"},
+			{args: []string{"git", "clone", "--out"}, expect: "--output-format\n:1\n"},
+			{args: []string{"--output-format", "j"}, expect: "json\n:1\n"},
+			{args: []string{"git", "clone", "--output-format", ""}, expect: "json\ntext\n:1\n"},
+			{args: []string{"--output-format=t"}, expect: "--output-format=text\n:1\n"},
+			{args: []string{"--output-format", "json", "g"}, expect: "git\n:1\n"},
+			{args: []string{"git", "--output-format=json", "clone", "--q"}, expect: "--quiet\n:1\n"},
+			{args: []string{"git", "clone", "--", "--output-format", ""}, expect: ":3\n"},
+		}
+		for _, tc := range cases {
+			var stdout bytes.Buffer
+			env := NewStdlibExecEnv()
+			env.OSArgs = append([]string{"tool", "__complete"}, tc.args...)
+			env.OSStdout = &stdout
+			env.OSExit = func(code int) {
+				t.Fatal("unexpected exit", code)
+			}
+			root := &RootCommand[*StdlibExecEnv]{Command: newTestTree(nil), OutputFormatFlag: true}
+			root.Main(env)
+			if got := stdout.String(); got != tc.expect {
+				t.Errorf("%v: expected %q, got %q", tc.args, tc.expect, got)
+			}
+		}
+	})
+
+	t.Run("the command is hidden from the usage", func(t *testing.T) {
+		var stdout bytes.Buffer
+		env := NewStdlibExecEnv()
+		env.OSStdout = &stdout
+		dx := newTestTree(nil)
+		err := dx.Run(context.Background(), &CommandArgs[*StdlibExecEnv]{
+			Args:        []string{"--help"},
+			Command:     dx,
+			CommandName: "tool",
+			Env:         env,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(stdout.String(), "__complete") {
+			t.Fatalf("unexpected __complete in usage: %q", stdout.String())
+		}
+	})
+
+	t.Run("SupportsSubcommands", func(t *testing.T) {
+		cc := &CompleteCommand[*StdlibExecEnv]{}
+		if cc.SupportsSubcommands() {
+			t.Fatal("SupportsSubcommands() = true, want false")
+		}
+	})
+}
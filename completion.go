@@ -0,0 +1,514 @@
+// completion.go - shell completion scripts.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// CompletionShells contains the shells supported by [WriteCompletion].
+var CompletionShells = []string{"bash", "fish", "zsh"}
+
+// ErrUnsupportedShell is returned when asking for the completion
+// script of a shell not listed inside [CompletionShells].
+var ErrUnsupportedShell = errors.New("unsupported shell")
+
+// CompletionCommand implements the completion command.
+//
+// The zero value is not ready to use. Initialize the mandatory fields.
+type CompletionCommand[T ExecEnv] struct {
+	// --- mandatory fields ---
+
+	// Command is the mandatory command for which to generate completions.
+	Command Command[T]
+
+	// --- optional fields ---
+
+	// BriefDescriptionText is the optional brief description text.
+	//
+	// When unset, we use a reasonable default value.
+	BriefDescriptionText string
+
+	// ErrorHandling is the optional error handling strategy.
+	//
+	// When unset, we use [ContinueOnError].
+	ErrorHandling nflag.ErrorHandling
+
+	// HelpFlagValue is the optional help flag. When unset, we use "--help".
+	HelpFlagValue string
+
+	// ProgramName is the optional name of the program to complete. When
+	// unset, we use the base name of the first word of the command name.
+	ProgramName string
+}
+
+var _ InspectableCommand[*StdlibExecEnv] = &CompletionCommand[*StdlibExecEnv]{}
+
+// BriefDescription implements [Command].
+func (c *CompletionCommand[T]) BriefDescription() string {
+	output := "Generate the shell completion script."
+	if c.BriefDescriptionText != "" {
+		output = c.BriefDescriptionText
+	}
+	return output
+}
+
+// HelpFlag implements [Command].
+func (c *CompletionCommand[T]) HelpFlag() string {
+	output := "--help"
+	if c.HelpFlagValue != "" {
+		output = c.HelpFlagValue
+	}
+	return output
+}
+
+// Run implements [Command].
+func (c *CompletionCommand[T]) Run(ctx context.Context, args *CommandArgs[T]) error {
+	// Determine the name of the program to complete.
+	progname := c.ProgramName
+	if progname == "" {
+		progname = filepath.Base(strings.Fields(args.CommandName + " _")[0])
+	}
+
+	// Create the command line parser.
+	clp := NewFlagSet(args, c.ErrorHandling)
+	clp.Description = args.Command.BriefDescription()
+	clp.Description += fmt.Sprintf(" The supported shells are: %s.", strings.Join(CompletionShells, ", "))
+	clp.Examples = "Examples:\n"
+	clp.Examples += fmt.Sprintf("  source <(%s bash)\n", args.CommandName)
+	clp.Examples += fmt.Sprintf("  %s fish | source\n", args.CommandName)
+	clp.PositionalArgumentsUsage = "SHELL"
+	clp.MinPositionalArgs = 1
+	clp.MaxPositionalArgs = 1
+
+	// Add the `-h, --help` flag.
+	clp.AutoHelp("help", 'h', "Show this help message and exit.")
+
+	// Parse the command line arguments.
+	if err := clp.Parse(args.Args); err != nil {
+		return err
+	}
+
+	// Write the completion script to the standard output.
+	shell := clp.Args()[0]
+	err := WriteCompletion(args.Env.Stdout(), shell, progname, c.Command)
+	if errors.Is(err, ErrUnsupportedShell) {
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "%s: unsupported shell: %s\n", args.CommandName, shell)
+		fmt.Fprintf(&sb, "Try '%s --help' for more information.\n", args.CommandName)
+		fmt.Fprintln(args.Env.Stderr(), strings.TrimSpace(sb.String()))
+	}
+	return err
+}
+
+// SupportsInspection implements [InspectableCommand].
+func (c *CompletionCommand[T]) SupportsInspection() bool {
+	return true
+}
+
+// SupportsSubcommands implements [Command].
+func (c *CompletionCommand[T]) SupportsSubcommands() bool {
+	return false
+}
+
+// WriteCompletion writes to w the completion script for the given shell. The
+// progname argument is the name of the program to complete, while cmd is the
+// command tree to walk (see [Walk]). Only the names of the subcommands of
+// commands implementing [CommandWithSubcommands] are known statically,
+// therefore the script completes those.
+//
+// When cmd is a [*DispatcherCommand] with the Completion field set, the
+// script invokes `progname __complete` to complete the command line of
+// the other commands (see [*CompleteCommand]). Otherwise, the script falls
+// back to the shell default completion.
+//
+// This function returns [ErrUnsupportedShell] if the shell is not one
+// of the shells listed inside [CompletionShells].
+func WriteCompletion[T ExecEnv](w io.Writer, shell, progname string, cmd Command[T]) error {
+	tree := &completionTree{progname: progname, prefixes: map[string]bool{}}
+	if dx, ok := cmd.(*DispatcherCommand[T]); ok {
+		tree.dynamic = dx.Completion
+	}
+	collectCompletion(tree, cmd)
+
+	switch shell {
+	case "bash":
+		return tree.writeBash(w)
+	case "fish":
+		return tree.writeFish(w)
+	case "zsh":
+		return tree.writeZsh(w)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedShell, shell)
+	}
+}
+
+// completionEntry is a subcommand that the shell may complete.
+type completionEntry struct {
+	name  string
+	brief string
+}
+
+// completionNode contains the subcommands available at a given path.
+type completionNode struct {
+	// path is the path of subcommand names joined and prefixed by `/`.
+	path string
+
+	// entries contains the subcommands sorted by name.
+	entries []completionEntry
+}
+
+// completionTree is the shell independent view of a command tree.
+type completionTree struct {
+	dynamic  bool
+	nodes    []completionNode
+	prefixes map[string]bool
+	progname string
+}
+
+// collectCompletion walks the command tree rooted at cmd and fills the tree.
+func collectCompletion[T ExecEnv](tree *completionTree, cmd Command[T]) {
+	var aliases [][2]string // pairs of alias path and canonical path
+	hidden := map[string]bool{}
+	_ = Walk(cmd, func(path []string, cmd Command[T]) error {
+		nodePath := ""
+		for _, name := range path {
+			nodePath += "/" + name
+		}
+
+		// We do not complete hidden commands and their subcommands
+		if hidden[nodePath] {
+			return SkipSubcommands
+		}
+
+		// We only know how to enumerate the subcommands of some commands
+		cx, ok := cmd.(CommandWithSubcommands[T])
+		if !ok {
+			return nil
+		}
+
+		// Only dispatchers have option prefixes and synthesize `help`
+		dx, isDispatcher := cmd.(*DispatcherCommand[T])
+		if isDispatcher {
+			for _, prefix := range dx.OptionPrefixes {
+				tree.prefixes[prefix] = true
+			}
+			byCommand := dx.aliasesByCommand()
+			for _, name := range slices.Sorted(maps.Keys(byCommand)) {
+				for _, alias := range byCommand[name] {
+					aliases = append(aliases, [2]string{nodePath + "/" + alias, nodePath + "/" + name})
+				}
+			}
+		}
+
+		// Gather the subcommands including the synthesized ones
+		commands := cx.Subcommands()
+		if hx, ok := cmd.(CommandWithHiddenSubcommands[T]); ok {
+			for name := range commands {
+				if hx.SubcommandHidden(name) {
+					hidden[nodePath+"/"+name] = true
+					delete(commands, name)
+				}
+			}
+		}
+		names := sortedSubcommandNames(commands)
+		entries := make([]completionEntry, 0, len(names)+1)
+		for _, name := range names {
+			entries = append(entries, completionEntry{name, commands[name].BriefDescription()})
+		}
+		synthesizeHelp := isDispatcher && commands["help"] == nil
+		if synthesizeHelp {
+			entries = append(entries, completionEntry{"help", "Show help for a command."})
+			sort.SliceStable(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+		}
+		tree.nodes = append(tree.nodes, completionNode{path: nodePath, entries: entries})
+
+		// The synthesized `help` takes the same subcommands as arguments
+		if synthesizeHelp {
+			var helpEntries []completionEntry
+			for _, entry := range entries {
+				if entry.name != "help" {
+					helpEntries = append(helpEntries, entry)
+				}
+			}
+			tree.nodes = append(tree.nodes, completionNode{path: nodePath + "/help", entries: helpEntries})
+		}
+		return nil
+	})
+
+	// Aliases complete like the canonical names. We process the aliases in
+	// reverse order, such that we copy the nodes of the nested aliases before
+	// copying the nodes of the aliases of their parents.
+	for idx := len(aliases) - 1; idx >= 0; idx-- {
+		alias, canonical := aliases[idx][0], aliases[idx][1]
+		for _, node := range tree.nodes {
+			if node.path == canonical || strings.HasPrefix(node.path, canonical+"/") {
+				node.path = alias + strings.TrimPrefix(node.path, canonical)
+				tree.nodes = append(tree.nodes, node)
+			}
+		}
+	}
+}
+
+// funcName returns a shell function name derived from the program name.
+func (tree *completionTree) funcName() string {
+	return "_" + regexp.MustCompile(`[^A-Za-z0-9_]`).ReplaceAllString(tree.progname, "_")
+}
+
+// optionPatterns returns the shell patterns matching options.
+func (tree *completionTree) optionPatterns() []string {
+	var patterns []string
+	for prefix := range tree.prefixes {
+		patterns = append(patterns, shellSingleQuote(prefix)+"*")
+	}
+	sort.Strings(patterns)
+	return patterns
+}
+
+// fishOptionPatterns is like optionPatterns but uses fish quoting.
+func (tree *completionTree) fishOptionPatterns() []string {
+	var patterns []string
+	for prefix := range tree.prefixes {
+		patterns = append(patterns, fishSingleQuote(prefix+"*"))
+	}
+	sort.Strings(patterns)
+	return patterns
+}
+
+func (tree *completionTree) writeBash(w io.Writer) error {
+	var sb strings.Builder
+	fn := tree.funcName()
+
+	fmt.Fprintf(&sb, "# bash completion for %s.\n", tree.progname)
+	fmt.Fprintf(&sb, "#\n")
+	fmt.Fprintf(&sb, "# Load with: source <(%s completion bash)\n", tree.progname)
+	fmt.Fprintf(&sb, "\n")
+	fmt.Fprintf(&sb, "%s() {\n", fn)
+	fmt.Fprintf(&sb, "\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	fmt.Fprintf(&sb, "\tlocal cmdpath=\"\" word i\n")
+	fmt.Fprintf(&sb, "\tfor ((i = 1; i < COMP_CWORD; i++)); do\n")
+	fmt.Fprintf(&sb, "\t\tword=\"${COMP_WORDS[i]}\"\n")
+	if patterns := tree.optionPatterns(); len(patterns) > 0 {
+		fmt.Fprintf(&sb, "\t\tcase \"$word\" in\n")
+		fmt.Fprintf(&sb, "\t\t%s) continue ;;\n", strings.Join(patterns, "|"))
+		fmt.Fprintf(&sb, "\t\tesac\n")
+	}
+	fmt.Fprintf(&sb, "\t\tcmdpath=\"$cmdpath/$word\"\n")
+	fmt.Fprintf(&sb, "\tdone\n")
+	fmt.Fprintf(&sb, "\tcase \"$cmdpath\" in\n")
+	for _, node := range tree.nodes {
+		var names []string
+		for _, entry := range node.entries {
+			names = append(names, entry.name)
+		}
+		fmt.Fprintf(&sb, "\t%s)\n", shellSingleQuote(node.path))
+		fmt.Fprintf(&sb, "\t\tCOMPREPLY=($(compgen -W %s -- \"$cur\"))\n",
+			shellSingleQuote(strings.Join(names, " ")))
+		fmt.Fprintf(&sb, "\t\t;;\n")
+	}
+	fmt.Fprintf(&sb, "\t*)\n")
+	fmt.Fprintf(&sb, "\t\tCOMPREPLY=()\n")
+	if tree.dynamic {
+		fmt.Fprintf(&sb, "\t\tlocal IFS=$'\\n' directive\n")
+		fmt.Fprintf(&sb, "\t\tlocal -a lines\n")
+		fmt.Fprintf(&sb, "\t\tlines=($(\"${COMP_WORDS[0]}\" __complete \"${COMP_WORDS[@]:1:COMP_CWORD}\" 2>/dev/null))\n")
+		fmt.Fprintf(&sb, "\t\tif ((${#lines[@]} > 0)) && [[ \"${lines[${#lines[@]}-1]}\" =~ ^:[0-9]+$ ]]; then\n")
+		fmt.Fprintf(&sb, "\t\t\tdirective=\"${lines[${#lines[@]}-1]#:}\"\n")
+		fmt.Fprintf(&sb, "\t\t\tunset \"lines[${#lines[@]}-1]\"\n")
+		fmt.Fprintf(&sb, "\t\t\tCOMPREPLY=($(compgen -W \"${lines[*]}\" -- \"$cur\"))\n")
+		fmt.Fprintf(&sb, "\t\t\tif ((directive & %d)); then\n", nflag.CompletionDirectiveFilterDirs)
+		fmt.Fprintf(&sb, "\t\t\t\tCOMPREPLY+=($(compgen -d -- \"$cur\"))\n")
+		fmt.Fprintf(&sb, "\t\t\t\tcompopt -o filenames\n")
+		fmt.Fprintf(&sb, "\t\t\tfi\n")
+		fmt.Fprintf(&sb, "\t\t\tif ((directive & %d)); then\n", nflag.CompletionDirectiveNoFileFallback)
+		fmt.Fprintf(&sb, "\t\t\t\tcompopt +o default\n")
+		fmt.Fprintf(&sb, "\t\t\tfi\n")
+		fmt.Fprintf(&sb, "\t\tfi\n")
+	}
+	fmt.Fprintf(&sb, "\t\t;;\n")
+	fmt.Fprintf(&sb, "\tesac\n")
+	fmt.Fprintf(&sb, "}\n")
+	fmt.Fprintf(&sb, "\n")
+	fmt.Fprintf(&sb, "complete -o default -F %s %s\n", fn, shellSingleQuote(tree.progname))
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (tree *completionTree) writeZsh(w io.Writer) error {
+	var sb strings.Builder
+	fn := tree.funcName()
+
+	// Note: we cannot name the variable `path` because zsh ties it to `PATH`
+	fmt.Fprintf(&sb, "#compdef %s\n", tree.progname)
+	fmt.Fprintf(&sb, "#\n")
+	fmt.Fprintf(&sb, "# zsh completion for %s.\n", tree.progname)
+	fmt.Fprintf(&sb, "#\n")
+	fmt.Fprintf(&sb, "# Load with: source <(%s completion zsh)\n", tree.progname)
+	fmt.Fprintf(&sb, "\n")
+	fmt.Fprintf(&sb, "%s() {\n", fn)
+	fmt.Fprintf(&sb, "\tlocal cmdpath=\"\" word i\n")
+	fmt.Fprintf(&sb, "\tlocal -a candidates\n")
+	fmt.Fprintf(&sb, "\tfor ((i = 2; i < CURRENT; i++)); do\n")
+	fmt.Fprintf(&sb, "\t\tword=\"${words[i]}\"\n")
+	if patterns := tree.optionPatterns(); len(patterns) > 0 {
+		fmt.Fprintf(&sb, "\t\tcase \"$word\" in\n")
+		fmt.Fprintf(&sb, "\t\t%s) continue ;;\n", strings.Join(patterns, "|"))
+		fmt.Fprintf(&sb, "\t\tesac\n")
+	}
+	fmt.Fprintf(&sb, "\t\tcmdpath=\"$cmdpath/$word\"\n")
+	fmt.Fprintf(&sb, "\tdone\n")
+	fmt.Fprintf(&sb, "\tcase \"$cmdpath\" in\n")
+	for _, node := range tree.nodes {
+		fmt.Fprintf(&sb, "\t%s)\n", shellSingleQuote(node.path))
+		fmt.Fprintf(&sb, "\t\tcandidates=(\n")
+		for _, entry := range node.entries {
+			name := strings.ReplaceAll(entry.name, ":", `\:`)
+			fmt.Fprintf(&sb, "\t\t\t%s\n", shellSingleQuote(name+":"+completionBrief(entry.brief)))
+		}
+		fmt.Fprintf(&sb, "\t\t)\n")
+		fmt.Fprintf(&sb, "\t\t;;\n")
+	}
+	fmt.Fprintf(&sb, "\t*)\n")
+	if tree.dynamic {
+		fmt.Fprintf(&sb, "\t\tlocal -a lines\n")
+		fmt.Fprintf(&sb, "\t\tlocal directive\n")
+		fmt.Fprintf(&sb, "\t\tlines=(\"${(@f)$(\"${words[1]}\" __complete \"${(@)words[2,CURRENT]}\" 2>/dev/null)}\")\n")
+		fmt.Fprintf(&sb, "\t\tif [[ \"${lines[-1]}\" == :<-> ]]; then\n")
+		fmt.Fprintf(&sb, "\t\t\tdirective=\"${lines[-1]#:}\"\n")
+		fmt.Fprintf(&sb, "\t\t\tlines=(\"${(@)lines[1,-2]}\")\n")
+		fmt.Fprintf(&sb, "\t\t\tif ((directive & %d)); then\n", nflag.CompletionDirectiveFilterDirs)
+		fmt.Fprintf(&sb, "\t\t\t\tcompadd -- \"${(@)lines}\"\n")
+		fmt.Fprintf(&sb, "\t\t\t\t_files -/\n")
+		fmt.Fprintf(&sb, "\t\t\t\treturn\n")
+		fmt.Fprintf(&sb, "\t\t\tfi\n")
+		fmt.Fprintf(&sb, "\t\t\tif ((${#lines} > 0)); then\n")
+		fmt.Fprintf(&sb, "\t\t\t\tcompadd -- \"${(@)lines}\"\n")
+		fmt.Fprintf(&sb, "\t\t\t\treturn\n")
+		fmt.Fprintf(&sb, "\t\t\tfi\n")
+		fmt.Fprintf(&sb, "\t\t\tif ((directive & %d)); then\n", nflag.CompletionDirectiveNoFileFallback)
+		fmt.Fprintf(&sb, "\t\t\t\treturn 1\n")
+		fmt.Fprintf(&sb, "\t\t\tfi\n")
+		fmt.Fprintf(&sb, "\t\tfi\n")
+	}
+	fmt.Fprintf(&sb, "\t\t_files\n")
+	fmt.Fprintf(&sb, "\t\treturn\n")
+	fmt.Fprintf(&sb, "\t\t;;\n")
+	fmt.Fprintf(&sb, "\tesac\n")
+	fmt.Fprintf(&sb, "\t_describe 'command' candidates\n")
+	fmt.Fprintf(&sb, "}\n")
+	fmt.Fprintf(&sb, "\n")
+	fmt.Fprintf(&sb, "if [[ \"$funcstack[1]\" = %s ]]; then\n", shellSingleQuote(fn))
+	fmt.Fprintf(&sb, "\t%s \"$@\"\n", fn)
+	fmt.Fprintf(&sb, "else\n")
+	fmt.Fprintf(&sb, "\tcompdef %s %s\n", fn, shellSingleQuote(tree.progname))
+	fmt.Fprintf(&sb, "fi\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (tree *completionTree) writeFish(w io.Writer) error {
+	var sb strings.Builder
+	fn := "_" + tree.funcName()
+
+	fmt.Fprintf(&sb, "# fish completion for %s.\n", tree.progname)
+	fmt.Fprintf(&sb, "#\n")
+	fmt.Fprintf(&sb, "# Load with: %s completion fish | source\n", tree.progname)
+	fmt.Fprintf(&sb, "\n")
+	fmt.Fprintf(&sb, "function %s_cmdpath\n", fn)
+	fmt.Fprintf(&sb, "\tset -l cmdpath \"\"\n")
+	fmt.Fprintf(&sb, "\tfor word in (commandline -opc)[2..-1]\n")
+	if patterns := tree.fishOptionPatterns(); len(patterns) > 0 {
+		fmt.Fprintf(&sb, "\t\tswitch $word\n")
+		fmt.Fprintf(&sb, "\t\t\tcase %s\n", strings.Join(patterns, " "))
+		fmt.Fprintf(&sb, "\t\t\t\tcontinue\n")
+		fmt.Fprintf(&sb, "\t\tend\n")
+	}
+	fmt.Fprintf(&sb, "\t\tset cmdpath \"$cmdpath/$word\"\n")
+	fmt.Fprintf(&sb, "\tend\n")
+	fmt.Fprintf(&sb, "\techo $cmdpath\n")
+	fmt.Fprintf(&sb, "end\n")
+	fmt.Fprintf(&sb, "\n")
+	fmt.Fprintf(&sb, "function %s_at\n", fn)
+	fmt.Fprintf(&sb, "\tset -l cmdpath (%s_cmdpath)\n", fn)
+	fmt.Fprintf(&sb, "\ttest \"$cmdpath\" = \"$argv[1]\"\n")
+	fmt.Fprintf(&sb, "end\n")
+	fmt.Fprintf(&sb, "\n")
+	for _, node := range tree.nodes {
+		condition := fishSingleQuote(fn + "_at " + fishSingleQuote(node.path))
+		for _, entry := range node.entries {
+			fmt.Fprintf(&sb, "complete -c %s -f -n %s -a %s -d %s\n",
+				fishSingleQuote(tree.progname), condition, fishSingleQuote(entry.name),
+				fishSingleQuote(completionBrief(entry.brief)))
+		}
+	}
+
+	if tree.dynamic {
+		var paths []string
+		for _, node := range tree.nodes {
+			paths = append(paths, fishSingleQuote(node.path))
+		}
+		fmt.Fprintf(&sb, "\n")
+		fmt.Fprintf(&sb, "function %s_dynamic\n", fn)
+		fmt.Fprintf(&sb, "\tset -l cmdpath (%s_cmdpath)\n", fn)
+		fmt.Fprintf(&sb, "\tswitch \"$cmdpath\"\n")
+		fmt.Fprintf(&sb, "\t\tcase %s\n", strings.Join(paths, " "))
+		fmt.Fprintf(&sb, "\t\t\treturn 1\n")
+		fmt.Fprintf(&sb, "\tend\n")
+		fmt.Fprintf(&sb, "end\n")
+		fmt.Fprintf(&sb, "\n")
+		fmt.Fprintf(&sb, "function %s_complete\n", fn)
+		fmt.Fprintf(&sb, "\tset -l words (commandline -opc)\n")
+		fmt.Fprintf(&sb, "\tset -l cur (commandline -ct)\n")
+		fmt.Fprintf(&sb, "\tset -q cur[1]; or set cur \"\"\n")
+		fmt.Fprintf(&sb, "\tset -l lines ($words[1] __complete $words[2..-1] $cur 2>/dev/null)\n")
+		fmt.Fprintf(&sb, "\tstring match -qr '^:[0-9]+$' -- \"$lines[-1]\"; or return 1\n")
+		fmt.Fprintf(&sb, "\tset -l directive (string sub -s 2 -- $lines[-1])\n")
+		fmt.Fprintf(&sb, "\tif test (count $lines) -gt 1\n")
+		fmt.Fprintf(&sb, "\t\tprintf '%%s\\n' $lines[1..-2]\n")
+		fmt.Fprintf(&sb, "\tend\n")
+		fmt.Fprintf(&sb, "\tif test (math \"floor($directive / %d) %% 2\") -eq 1\n", nflag.CompletionDirectiveFilterDirs)
+		fmt.Fprintf(&sb, "\t\t__fish_complete_directories \"$cur\"\n")
+		fmt.Fprintf(&sb, "\t\treturn 0\n")
+		fmt.Fprintf(&sb, "\tend\n")
+		fmt.Fprintf(&sb, "\ttest (math \"floor($directive / %d) %% 2\") -eq 1\n", nflag.CompletionDirectiveNoFileFallback)
+		fmt.Fprintf(&sb, "end\n")
+		fmt.Fprintf(&sb, "\n")
+		fmt.Fprintf(&sb, "complete -c %s -n %s -a %s\n", fishSingleQuote(tree.progname),
+			fishSingleQuote(fn+"_dynamic"), fishSingleQuote("("+fn+"_complete)"))
+		fmt.Fprintf(&sb, "complete -c %s -f -n %s\n", fishSingleQuote(tree.progname),
+			fishSingleQuote(fn+"_dynamic; and "+fn+"_complete >/dev/null"))
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// completionBrief returns the brief description on a single line.
+func completionBrief(brief string) string {
+	return strings.Join(strings.Fields(brief), " ")
+}
+
+// fishSingleQuote quotes the given string using fish single quotes.
+func fishSingleQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+}
+
+// shellSingleQuote quotes the given string using single quotes.
+func shellSingleQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
@@ -0,0 +1,199 @@
+// completion_test.go - shell completion scripts tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+func TestWriteCompletion(t *testing.T) {
+	t.Run("unsupported shell", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := WriteCompletion(&buf, "tcsh", "tool", Command[*StdlibExecEnv](newTestTree(nil)))
+		if !errors.Is(err, ErrUnsupportedShell) {
+			t.Fatalf("expected ErrUnsupportedShell, got %v", err)
+		}
+		if buf.Len() != 0 {
+			t.Fatalf("expected no output, got %q", buf.String())
+		}
+	})
+
+	for _, shell := range CompletionShells {
+		t.Run(shell, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := WriteCompletion(&buf, shell, "tool", Command[*StdlibExecEnv](newTestTree(nil)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, expect := range []string{"tool", "/git", "clone", "completion", "version"} {
+				if !strings.Contains(buf.String(), expect) {
+					t.Errorf("expected %q inside the %s script", expect, shell)
+				}
+			}
+		})
+	}
+
+	type testcase struct {
+		name     string
+		hidden   []string
+		expect   []string
+		unexpect []string
+	}
+
+	cases := []testcase{
+		{
+			name:     "the script does not contain hidden commands",
+			expect:   []string{"'/git')"},
+			unexpect: []string{"secret"},
+		},
+
+		{
+			name:     "the script does not contain hidden subtrees",
+			hidden:   []string{"git"},
+			unexpect: []string{"git", "clone"},
+		},
+
+		{
+			name:   "the script contains the nodes of the aliases",
+			expect: []string{"'/g')", "'/g/help')"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dx := newTestTree(nil)
+			for _, name := range tc.hidden {
+				dx.Hidden[name] = true
+			}
+			var buf bytes.Buffer
+			err := WriteCompletion(&buf, "bash", "tool", Command[*StdlibExecEnv](dx))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, expect := range tc.expect {
+				if !strings.Contains(buf.String(), expect) {
+					t.Errorf("expected %q inside the script", expect)
+				}
+			}
+			for _, unexpect := range tc.unexpect {
+				if strings.Contains(buf.String(), unexpect) {
+					t.Errorf("unexpected %q inside the script", unexpect)
+				}
+			}
+		})
+	}
+
+	t.Run("the bash script completes subcommands", func(t *testing.T) {
+		bash, err := exec.LookPath("bash")
+		if err != nil {
+			t.Skip("bash is not installed")
+		}
+
+		// Write the completion script
+		var buf bytes.Buffer
+		err = WriteCompletion(&buf, "bash", "tool", Command[*StdlibExecEnv](newTestTree(nil)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		script := filepath.Join(t.TempDir(), "tool.bash")
+		if err := os.WriteFile(script, buf.Bytes(), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		// Simulate completing `tool -v git <TAB>`
+		driver := `source "$1"; COMP_WORDS=(tool -v git ""); COMP_CWORD=3; _tool; echo "${COMPREPLY[*]}"`
+		output, err := exec.Command(bash, "-c", driver, "bash", script).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSpace(string(output)); got != "clone help init" {
+			t.Fatalf("expected %q, got %q", "clone help init", got)
+		}
+	})
+}
+
+func TestCompletionCommand(t *testing.T) {
+	t.Run("BriefDescription", func(t *testing.T) {
+		expect := "foo"
+		cc := &CompletionCommand[*StdlibExecEnv]{BriefDescriptionText: expect}
+		if got := cc.BriefDescription(); got != expect {
+			t.Fatalf("BriefDescription() = %q, want %q", got, expect)
+		}
+	})
+
+	t.Run("HelpFlag default value", func(t *testing.T) {
+		cc := &CompletionCommand[*StdlibExecEnv]{}
+		if got := cc.HelpFlag(); got != "--help" {
+			t.Fatalf("HelpFlag() = %q, want %q", got, "--help")
+		}
+	})
+
+	t.Run("SupportsSubcommands", func(t *testing.T) {
+		cc := &CompletionCommand[*StdlibExecEnv]{}
+		if cc.SupportsSubcommands() {
+			t.Fatal("SupportsSubcommands() = true, want false")
+		}
+	})
+
+	t.Run("synthesized by the dispatcher", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		env := NewStdlibExecEnv()
+		env.OSStdout = &stdout
+		env.OSStderr = &stderr
+		dx := newTestTree(nil)
+		err := dx.Run(context.Background(), &CommandArgs[*StdlibExecEnv]{
+			Args:        []string{"completion", "zsh"},
+			Command:     dx,
+			CommandName: "/usr/bin/tool",
+			Env:         env,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(stdout.String(), "#compdef tool\n") {
+			t.Fatalf("unexpected zsh script: %q", stdout.String())
+		}
+	})
+
+	t.Run("with unsupported shell", func(t *testing.T) {
+		var stderr bytes.Buffer
+		env := NewStdlibExecEnv()
+		env.OSStderr = &stderr
+		dx := newTestTree(nil)
+		err := dx.Run(context.Background(), &CommandArgs[*StdlibExecEnv]{
+			Args:        []string{"completion", "tcsh"},
+			Command:     dx,
+			CommandName: "tool",
+			Env:         env,
+		})
+		if !errors.Is(err, ErrUnsupportedShell) {
+			t.Fatalf("expected ErrUnsupportedShell, got %v", err)
+		}
+		if !strings.Contains(stderr.String(), "tool completion: unsupported shell: tcsh") {
+			t.Fatalf("unexpected stderr: %q", stderr.String())
+		}
+	})
+
+	t.Run("help is forwarded to the synthesized command", func(t *testing.T) {
+		dx := newTestTree(nil)
+		err := dx.Run(context.Background(), &CommandArgs[*StdlibExecEnv]{
+			Args:        []string{"help", "completion"},
+			Command:     dx,
+			CommandName: "tool",
+			Env:         NewStdlibExecEnv(),
+		})
+		if !errors.Is(err, nflag.ErrHelp) {
+			t.Fatalf("expected nflag.ErrHelp, got %v", err)
+		}
+	})
+}
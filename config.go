@@ -0,0 +1,150 @@
+// config.go - configuration files integration.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/bassosimone/clip/pkg/config"
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// ConfigFilePath returns the path of the default configuration file of the
+// program with the given name, which is `$XDG_CONFIG_HOME/<prog>/config.toml`
+// or, if `$XDG_CONFIG_HOME` is not set, `$HOME/.config/<prog>/config.toml`.
+// We only use the base name of progname. The boolean is false when neither
+// environment variable is set, in which case there is no configuration file.
+func ConfigFilePath(env ExecEnv, progname string) (string, bool) {
+	progname = filepath.Base(progname)
+	if dir, found := env.LookupEnv("XDG_CONFIG_HOME"); found && dir != "" {
+		return filepath.Join(dir, progname, "config.toml"), true
+	}
+	if home, found := env.LookupEnv("HOME"); found && home != "" {
+		return filepath.Join(home, ".config", progname, "config.toml"), true
+	}
+	return "", false
+}
+
+// ConfigSection returns the section of the configuration file that applies
+// to the command with the given name, which is the command path without
+// the program name, joined using dots. For example, the section of the
+// `prog git clone` command is `git.clone`.
+func ConfigSection(commandName string) string {
+	words := strings.Fields(commandName)
+	if len(words) <= 1 {
+		return ""
+	}
+	return strings.Join(words[1:], ".")
+}
+
+// LoadConfig is like [LoadConfigFile] but uses the file returned by
+// [ConfigFilePath] for the program that is running args.Command, which
+// is optional, therefore we ignore it when it does not exist.
+func LoadConfig[T ExecEnv](args *CommandArgs[T], fset *nflag.FlagSet) {
+	progname, _, _ := strings.Cut(args.CommandName, " ")
+	filename, found := ConfigFilePath(args.Env, progname)
+	if !found {
+		return
+	}
+	loadConfigFile(args, fset, filename, true)
+}
+
+// LoadConfigFile reads the given configuration file using the [FS] returned by
+// [Extend] for args.Env, and sets the flags in fset using the [ConfigSection] for
+// args.CanonicalCommandName, such that aliases and abbreviations share the section
+// of the canonical command. We choose the file format according to its extension
+// (see [config.Parse]). Since the caller explicitly selected the file, we report
+// a file that does not exist like any other failure. Call this function before
+// [*nflag.FlagSet.Parse], such that environment variables and the command line
+// override the configuration file.
+//
+// We print a warning on fset.Stderr, using fset.OutputFormat, for each section
+// not corresponding to any command reachable from the [*RootCommand], with the
+// "unknown_config_section" kind when printing JSON (see [nflag.ErrorReport]).
+// We report the other failures, such
+// as missing files, unknown keys, and invalid values, using the AfterParse hook
+// of fset, such that [*nflag.FlagSet.Parse] handles them like parse errors
+// according to the fset.ErrorHandling policy, but only after handling the help
+// flags. Therefore, `--help` works regardless of the configuration file.
+func LoadConfigFile[T ExecEnv](args *CommandArgs[T], fset *nflag.FlagSet, filename string) {
+	loadConfigFile(args, fset, filename, false)
+}
+
+// loadConfigFile implements [LoadConfig] and [LoadConfigFile]. When optional
+// is true, we ignore the file if it does not exist.
+func loadConfigFile[T ExecEnv](args *CommandArgs[T], fset *nflag.FlagSet, filename string, optional bool) {
+	// do not read files when inspecting the flags
+	if args.inspecting {
+		return
+	}
+	file, err := readConfigFile(args.Env, filename)
+	if optional && errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err == nil {
+		if args.root != nil {
+			warnUnknownConfigSections(fset, file, configSections(args.root))
+		}
+		err = file.Apply(fset, ConfigSection(args.canonicalCommandName()))
+	}
+	if err != nil {
+		deferConfigError(fset, err)
+	}
+}
+
+// readConfigFile reads and parses the given configuration file.
+func readConfigFile(env ExecEnv, filename string) (*config.File, error) {
+	data, err := Extend(env).FS().ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return config.Parse(filename, data)
+}
+
+// configSections returns the configuration sections of
+// the commands inside the tree rooted at root.
+func configSections[T ExecEnv](root Command[T]) map[string]bool {
+	sections := map[string]bool{}
+	_ = Walk(root, func(path []string, cmd Command[T]) error {
+		sections[strings.Join(path, ".")] = true
+		return nil
+	})
+	return sections
+}
+
+// warnUnknownConfigSections prints a warning on fset.Stderr, using fset.OutputFormat,
+// for each section of the file not contained in the given sections, mentioning the
+// first line using it.
+func warnUnknownConfigSections(fset *nflag.FlagSet, file *config.File, sections map[string]bool) {
+	for _, entry := range file.Entries {
+		if sections[entry.Section] {
+			continue
+		}
+		sections[entry.Section] = true // only warn once
+		printWarning(fset.Stderr, fset.OutputFormat, &nflag.ErrorReport{
+			Kind:    "unknown_config_section",
+			Program: fset.ProgramName,
+			Message: fmt.Sprintf("%s:%d: unknown section [%s]", file.Filename, entry.Line, entry.Section),
+			Value:   entry.Section,
+		})
+	}
+}
+
+// deferConfigError arranges for [*nflag.FlagSet.Parse] to return the given
+// error after parsing, preserving the existing AfterParse hook, if any.
+func deferConfigError(fset *nflag.FlagSet, err error) {
+	afterParse := fset.AfterParse
+	fset.AfterParse = func(fx *nflag.FlagSet) error {
+		if afterParse != nil {
+			if err := afterParse(fx); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+}
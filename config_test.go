@@ -0,0 +1,297 @@
+// config_test.go - configuration files integration tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/bassosimone/clip/pkg/config"
+	"github.com/bassosimone/clip/pkg/nflag"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConfigFilePath(t *testing.T) {
+	type testcase struct {
+		name        string
+		environ     map[string]string
+		expect      string
+		expectFound bool
+	}
+
+	cases := []testcase{
+		{
+			name:        "we prefer XDG_CONFIG_HOME",
+			environ:     map[string]string{"XDG_CONFIG_HOME": "/xdg", "HOME": "/home/user"},
+			expect:      "/xdg/tool/config.toml",
+			expectFound: true,
+		},
+
+		{
+			name:        "we fall back to HOME",
+			environ:     map[string]string{"XDG_CONFIG_HOME": "", "HOME": "/home/user"},
+			expect:      "/home/user/.config/tool/config.toml",
+			expectFound: true,
+		},
+
+		{
+			name:        "we fail without HOME",
+			environ:     map[string]string{},
+			expectFound: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := NewStdlibExecEnv()
+			env.OSLookupEnv = func(key string) (string, bool) {
+				value, found := tc.environ[key]
+				return value, found
+			}
+			got, found := ConfigFilePath(env, "/usr/local/bin/tool")
+			if found != tc.expectFound {
+				t.Fatal("expected", tc.expectFound, "got", found)
+			}
+			if diff := cmp.Diff(tc.expect, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestConfigSection(t *testing.T) {
+	cases := map[string]string{
+		"tool":           "",
+		"tool git":       "git",
+		"tool git clone": "git.clone",
+	}
+	for commandName, expect := range cases {
+		t.Run(commandName, func(t *testing.T) {
+			if diff := cmp.Diff(expect, ConfigSection(commandName)); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	// newEnv creates an [*StdlibExecEnv] containing the given files.
+	newEnv := func(files map[string]string) *StdlibExecEnv {
+		env := NewStdlibExecEnv()
+		env.OSLookupEnv = func(key string) (string, bool) {
+			if key == "HOME" {
+				return "/home/user", true
+			}
+			return "", false
+		}
+		fsys := mapFS{}
+		for name, data := range files {
+			fsys[fsys.name(name)] = &fstest.MapFile{Data: []byte(data)}
+		}
+		env.OSFileSystem = fsys
+		return env
+	}
+
+	// newFlagSet creates the [*nflag.FlagSet] used by the test cases.
+	newFlagSet := func(env *StdlibExecEnv, handling nflag.ErrorHandling) (*nflag.FlagSet, *CommandArgs[*StdlibExecEnv], *string) {
+		args := &CommandArgs[*StdlibExecEnv]{CommandName: "tool git clone", Env: env}
+		fset := NewFlagSet(args, handling)
+		fset.AutoHelp("help", 'h', "Print this help message and exit.")
+		branch := fset.StringFlag("branch", 'b', "Branch name.")
+		return fset, args, branch
+	}
+
+	const filename = "/home/user/.config/tool/config.toml"
+
+	t.Run("we apply the command section and the command line overrides it", func(t *testing.T) {
+		env := newEnv(map[string]string{filename: "branch = \"x\"\n[git.clone]\nbranch = \"main\"\n"})
+		fset, args, branch := newFlagSet(env, nflag.ContinueOnError)
+		LoadConfig(args, fset)
+		if *branch != "main" {
+			t.Fatalf("expected %q, got %q", "main", *branch)
+		}
+		if err := fset.Parse([]string{"-b", "dev"}); err != nil {
+			t.Fatal(err)
+		}
+		if *branch != "dev" {
+			t.Fatalf("expected %q, got %q", "dev", *branch)
+		}
+	})
+
+	t.Run("we use the section of the canonical command name", func(t *testing.T) {
+		env := newEnv(map[string]string{filename: "[g.clone]\nbranch = \"x\"\n[git.clone]\nbranch = \"main\"\n"})
+		fset, args, branch := newFlagSet(env, nflag.ContinueOnError)
+		args.CommandName, args.CanonicalCommandName = "tool g clone", "tool git clone"
+		LoadConfig(args, fset)
+		if err := fset.Parse(nil); err != nil {
+			t.Fatal(err)
+		}
+		if *branch != "main" {
+			t.Fatalf("expected %q, got %q", "main", *branch)
+		}
+	})
+
+	t.Run("we ignore files that do not exist", func(t *testing.T) {
+		fset, args, _ := newFlagSet(newEnv(nil), nflag.ContinueOnError)
+		LoadConfig(args, fset)
+		if err := fset.Parse(nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("we return errors for explicit files that do not exist", func(t *testing.T) {
+		fset, args, _ := newFlagSet(newEnv(nil), nflag.ContinueOnError)
+		LoadConfigFile(args, fset, "/etc/tool.toml")
+		if err := fset.Parse(nil); !errors.Is(err, fs.ErrNotExist) {
+			t.Fatal("expected", fs.ErrNotExist, "got", err)
+		}
+	})
+
+	t.Run("we return read errors when parsing", func(t *testing.T) {
+		env := newEnv(nil)
+		env.OSFileSystem = failingFS{fs.ErrPermission}
+		fset, args, _ := newFlagSet(env, nflag.ContinueOnError)
+		LoadConfig(args, fset)
+		if err := fset.Parse(nil); !errors.Is(err, fs.ErrPermission) {
+			t.Fatal("expected", fs.ErrPermission, "got", err)
+		}
+	})
+
+	t.Run("we choose the format using the file extension", func(t *testing.T) {
+		env := newEnv(map[string]string{"/etc/tool.json": `{"git": {"clone": {"branch": "main"}}}`})
+		fset, args, branch := newFlagSet(env, nflag.ContinueOnError)
+		LoadConfigFile(args, fset, "/etc/tool.json")
+		if err := fset.Parse(nil); err != nil {
+			t.Fatal(err)
+		}
+		if *branch != "main" {
+			t.Fatalf("expected %q, got %q", "main", *branch)
+		}
+	})
+
+	t.Run("we return syntax errors when parsing", func(t *testing.T) {
+		env := newEnv(map[string]string{filename: "[git.clone\n"})
+		fset, args, _ := newFlagSet(env, nflag.ContinueOnError)
+		LoadConfig(args, fset)
+		var ex *config.Error
+		if err := fset.Parse(nil); !errors.As(err, &ex) || ex.Line != 1 {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("we handle help flags before reporting errors", func(t *testing.T) {
+		env := newEnv(map[string]string{filename: "[git.clone]\nverbose = true\n"})
+		fset, args, _ := newFlagSet(env, nflag.ContinueOnError)
+		LoadConfig(args, fset)
+		if err := fset.Parse([]string{"--help"}); !errors.Is(err, nflag.ErrHelp) {
+			t.Fatal("expected", nflag.ErrHelp, "got", err)
+		}
+	})
+
+	t.Run("we print the error and exit with ExitOnError", func(t *testing.T) {
+		env := newEnv(map[string]string{filename: "[git.clone]\nbranch = \"main\"\nverbose = true\n"})
+		var stderr bytes.Buffer
+		env.OSStderr = &stderr
+		exitcode := -1
+		env.OSExit = func(code int) {
+			exitcode = code
+		}
+		fset, args, _ := newFlagSet(env, nflag.ExitOnError)
+		LoadConfig(args, fset)
+		func() {
+			defer func() { recover() }() // the mocked exit returns, so we panic
+			fset.Parse(nil)
+		}()
+		if exitcode != 2 {
+			t.Fatalf("expected exit code 2, got %d", exitcode)
+		}
+		expect := "tool git clone: " + filename + ":3: unknown key \"verbose\" in section [git.clone]\n" +
+			"Try 'tool git clone --help' for more help.\n"
+		if diff := cmp.Diff(expect, stderr.String()); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("we warn about sections not corresponding to any command", func(t *testing.T) {
+		env := newEnv(map[string]string{filename: "[gti.clone]\nbranch = \"x\"\nquiet = true\n[git]\n[git.clone]\nbranch = \"main\"\n"})
+		var stderr bytes.Buffer
+		env.OSStderr = &stderr
+		fset, args, branch := newFlagSet(env, nflag.ContinueOnError)
+		leaf := &LeafCommand[*StdlibExecEnv]{}
+		args.root = &DispatcherCommand[*StdlibExecEnv]{
+			Commands: map[string]Command[*StdlibExecEnv]{
+				"git": &DispatcherCommand[*StdlibExecEnv]{
+					Commands: map[string]Command[*StdlibExecEnv]{"clone": leaf},
+				},
+			},
+		}
+		LoadConfig(args, fset)
+		if err := fset.Parse(nil); err != nil {
+			t.Fatal(err)
+		}
+		if *branch != "main" {
+			t.Fatalf("expected %q, got %q", "main", *branch)
+		}
+		expect := "tool git clone: warning: " + filename + ":2: unknown section [gti.clone]\n"
+		if diff := cmp.Diff(expect, stderr.String()); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("we do not read files in inspection mode", func(t *testing.T) {
+		env := newEnv(nil)
+		env.OSFileSystem = failingFS{fs.ErrPermission}
+		fset, args, _ := newFlagSet(env, nflag.ContinueOnError)
+		args.inspecting = true
+		LoadConfig(args, fset)
+		if fset.AfterParse != nil {
+			t.Fatal("expected no AfterParse hook")
+		}
+	})
+}
+
+// failingFS is an [FS] whose ReadFile always fails with the given error.
+type failingFS struct {
+	err error
+}
+
+var _ FS = failingFS{}
+
+// MkdirAll implements [FS].
+func (f failingFS) MkdirAll(name string, perm fs.FileMode) error {
+	return f.err
+}
+
+// Open implements [FS].
+func (f failingFS) Open(name string) (fs.File, error) {
+	return nil, f.err
+}
+
+// ReadDir implements [FS].
+func (f failingFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return nil, f.err
+}
+
+// ReadFile implements [FS].
+func (f failingFS) ReadFile(name string) ([]byte, error) {
+	return nil, f.err
+}
+
+// Remove implements [FS].
+func (f failingFS) Remove(name string) error {
+	return f.err
+}
+
+// Stat implements [FS].
+func (f failingFS) Stat(name string) (fs.FileInfo, error) {
+	return nil, f.err
+}
+
+// WriteFile implements [FS].
+func (f failingFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return f.err
+}
@@ -8,12 +8,17 @@ import (
 	"errors"
 	"fmt"
 	"maps"
+	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/bassosimone/clip/internal/termstyle"
 	"github.com/bassosimone/clip/pkg/assert"
 	"github.com/bassosimone/clip/pkg/nflag"
 	"github.com/bassosimone/clip/pkg/scanner"
+	"github.com/bassosimone/clip/pkg/suggest"
 	"github.com/bassosimone/textwrap"
 	"github.com/kballard/go-shellquote"
 )
@@ -32,14 +37,122 @@ type DispatcherCommand[T ExecEnv] struct {
 	// Commands optionally contains the subcommands.
 	Commands map[string]Command[T]
 
+	// Aliases optionally maps alternative subcommand names to the canonical
+	// names used in Commands (e.g., `g` to `git`). The canonical name may also
+	// be the name of a synthesized command, such as `version`. When a name is
+	// both in Commands and in Aliases, Commands takes precedence.
+	//
+	// The usage lists the aliases next to the canonical name, and `help`
+	// resolves aliases like the canonical names.
+	Aliases map[string]string
+
+	// CanonicalCommandName optionally controls the CommandName we pass to
+	// a subcommand invoked through an alias. When true, we use the canonical
+	// name (e.g., `minirbmk git`). Otherwise, we use the alias the user
+	// typed (e.g., `minirbmk g`).
+	CanonicalCommandName bool
+
+	// AllowAbbreviations optionally enables resolving a subcommand from any
+	// unambiguous prefix of its name (e.g., `minirbmk g` for `minirbmk git`),
+	// considering the names in Commands along with the `version` and
+	// `completion` commands we would synthesize. When the prefix matches
+	// more than one name, we return an [*AmbiguousCommandError]. Exact names
+	// and Aliases take precedence over abbreviations, which otherwise behave
+	// like aliases with respect to CanonicalCommandName.
+	//
+	// When false, we only accept exact names and Aliases.
+	AllowAbbreviations bool
+
+	// Hidden optionally contains the names of Commands and Aliases we should
+	// not advertise. We still dispatch hidden commands, but we do not list
+	// them in the usage, in the completions, in the suggestions, and in
+	// the generated documentation, and we do not consider them when
+	// resolving abbreviations. The value returned by Subcommands contains
+	// the hidden commands, such that [Walk] and [Find] reach them, and
+	// SubcommandHidden reports whether a subcommand is hidden.
+	Hidden map[string]bool
+
+	// Deprecated optionally maps the names of deprecated Commands to the
+	// related [Deprecation]. When invoking a deprecated command, including
+	// through aliases and abbreviations, we print a warning on the standard
+	// error, using the "deprecated_command" kind when printing JSON (see
+	// [nflag.ErrorReport]). You typically also want to mark deprecated
+	// commands as Hidden.
+	Deprecated map[string]Deprecation
+
+	// Groups optionally assigns subcommands to named groups. The usage lists
+	// a section for each group, in the given order, followed by an "Other
+	// commands" section containing the subcommands not assigned to any group.
+	// See [*DispatcherCommand.SubcommandGroups] for more details.
+	//
+	// When empty, the usage lists all the subcommands in a single section.
+	Groups []CommandGroup
+
+	// ExternalCommands optionally enables running external commands, which
+	// allows third parties to extend the program without recompiling it.
+	//
+	// When the subcommand `foo` does not exist, we look for an executable
+	// named `<prog>-foo` inside the directories listed by the PATH environment
+	// variable, which we obtain using [ExecEnv.LookupEnv], and we run it using
+	// an [*ExternalCommand]. Here, <prog> is the canonical dispatcher command
+	// name (see [CommandArgs]) with spaces replaced by dashes (e.g., `minirbmk-git`
+	// for both `minirbmk git` and its alias `minirbmk g`) and without the
+	// leading directories of the program name. On Windows, like [os/exec.LookPath],
+	// we also require one of the extensions listed by the PATHEXT environment
+	// variable (e.g., `minirbmk-git.exe`). The usage lists the external
+	// commands in the "External commands" section.
+	//
+	// With [nflag.ExitOnError], we exit with the external command status.
+	ExternalCommands bool
+
+	// PersistentFlags optionally contains flags applying to the dispatcher and to
+	// all the commands below it (e.g., `--verbose` or `--config FILE`), which the
+	// user may specify before or after any subcommand name.
+	//
+	// Before looking for the subcommand name, we remove these flags and their
+	// values from the command line, knowing which flags require a value, and we
+	// parse them using [*nflag.FlagSet.Parse], which also applies the environment
+	// variables. We stop at the options-arguments separator of the flag set.
+	// We parse using a copy of the flag set (see [*nflag.FlagSet.Clone]) with
+	// the OutputFormat selected by the [*RootCommand] (see [CommandArgs]) and
+	// using the environment variables, exit function, standard output, and
+	// standard error of the [ExecEnv], like [NewFlagSet], so we do not modify
+	// this field. We print parse errors like the other usage errors,
+	// regardless of the ErrorHandling of the flag set.
+	// The usage lists these flags in the "Global options" section, and so does
+	// the usage of the [*nflag.FlagSet] instances created using [NewFlagSet]
+	// by the commands below the dispatcher. We also pass these flags to the
+	// commands using the PersistentFlags field of [CommandArgs].
+	//
+	// Because we remove these flags from the whole command line, the commands
+	// below the dispatcher cannot define flags with the same names: [NewFlagSet]
+	// configures the [*nflag.FlagSet] to panic when they do. Do not define help
+	// flags here, since the dispatcher and the commands below it handle help
+	// flags on their own.
+	PersistentFlags *nflag.FlagSet
+
+	// Middleware optionally contains [Middleware] wrapping the commands below
+	// the dispatcher, with the first entry being the outermost. The commands
+	// also inherit the Middleware of the enclosing dispatchers and of the
+	// [*RootCommand], which wraps this Middleware.
+	//
+	// The Middleware does not wrap the dispatchers, which pass it down to
+	// their subcommands. Therefore, it does not run when the dispatcher
+	// prints its usage or fails to find a subcommand.
+	Middleware []Middleware[T]
+
 	// Usage is the optional usage string for this dispatcher. If empty, we
-	// automatically generate a usage string when needed.
+	// automatically generate a usage string when needed. We always generate
+	// the usage when printing it using [nflag.OutputFormatJSON].
 	Usage string
 
 	// ErrorHandling indicates how the dispatcher should handle errors.
 	//
 	// Added in v0.3.0. When empty the behavior is [ContinueOnError], which
 	// is exactly cosistent with the v0.2.0 behavior.
+	//
+	// With [nflag.ExitOnError], before exiting, we print the error and we
+	// choose the exit status like the [*RootCommand] does.
 	ErrorHandling nflag.ErrorHandling
 
 	// Version is the program version string. If this field is set, the
@@ -73,9 +186,73 @@ type DispatcherCommand[T ExecEnv] struct {
 	//
 	// New in v0.6.0 and tied to OptionPrefixes.
 	OptionsArgumentsSeparator string
+
+	// Completion optionally enables the `completion` subcommand.
+	//
+	// If the `completion` command exists, the dispatcher will invoke it.
+	//
+	// Otherwise, the dispatcher will create a [*CompletionCommand] on
+	// the fly, configured to generate completion scripts for this
+	// dispatcher, and invoke it. Because the generated scripts cover
+	// the whole command tree below the dispatcher, you typically want
+	// to enable this field only on the top-level dispatcher.
+	//
+	// Additionally, the dispatcher synthesizes the hidden `__complete`
+	// command (see [*CompleteCommand]), which the scripts invoke to
+	// complete the flags of the commands below the dispatcher.
+	//
+	// When false, we don't handle `completion`.
+	Completion bool
+
+	// SuggestionsMaxDistance optionally configures the maximum edit
+	// distance (see [suggest.Distance]) between an unknown subcommand
+	// name and the subcommands we suggest instead. We further limit the
+	// distance to half the length of the unknown name, such that we do
+	// not consider all the short names similar to each other.
+	//
+	// When zero, we use [DefaultSuggestionsMaxDistance]. When negative,
+	// we do not suggest any subcommand.
+	SuggestionsMaxDistance int
+
+	// SuggestionsMaxCount optionally configures the maximum number of
+	// subcommands we suggest when the subcommand name is unknown.
+	//
+	// When zero, we use [DefaultSuggestionsMaxCount]. When negative,
+	// we do not suggest any subcommand.
+	SuggestionsMaxCount int
+}
+
+// Deprecation describes a deprecated [*DispatcherCommand] subcommand.
+type Deprecation struct {
+	// Replacement is the optional name of the subcommand replacing the
+	// deprecated one, which we mention in the warning.
+	Replacement string
+
+	// Forward optionally causes the dispatcher to run the Replacement
+	// rather than the deprecated subcommand, as if the user typed the
+	// Replacement. In such a case, the deprecated subcommand does not need
+	// to be part of the dispatcher Commands.
+	Forward bool
 }
 
-var _ Command[*StdlibExecEnv] = (*DispatcherCommand[*StdlibExecEnv])(nil)
+// CommandGroup is a named group of [*DispatcherCommand] subcommands.
+type CommandGroup struct {
+	// Title is the group title (e.g., `Core commands`).
+	Title string
+
+	// Commands contains the names of the subcommands in the group.
+	Commands []string
+}
+
+// DefaultSuggestionsMaxDistance is the default maximum edit distance
+// of the subcommands suggested by a [*DispatcherCommand].
+const DefaultSuggestionsMaxDistance = 2
+
+// DefaultSuggestionsMaxCount is the default maximum number of
+// subcommands suggested by a [*DispatcherCommand].
+const DefaultSuggestionsMaxCount = 3
+
+var _ CommandWithHiddenSubcommands[*StdlibExecEnv] = (*DispatcherCommand[*StdlibExecEnv])(nil)
 
 // --- public code ---
 
@@ -94,14 +271,65 @@ func (dx *DispatcherCommand[T]) SupportsSubcommands() bool {
 	return true
 }
 
+// Subcommands implements [CommandWithSubcommands].
+//
+// The returned map contains the Commands along with the `version` and
+// `completion` commands we would synthesize, including the Hidden
+// commands. It does not contain the `help` command.
+func (dx *DispatcherCommand[T]) Subcommands() map[string]Command[T] {
+	return dx.cloneSubcommands()
+}
+
+// SubcommandHidden implements [CommandWithHiddenSubcommands].
+func (dx *DispatcherCommand[T]) SubcommandHidden(name string) bool {
+	return dx.Hidden[name]
+}
+
+// SubcommandGroups returns the groups of subcommands listed when printing the
+// usage, or nil when the dispatcher does not configure any Groups.
+//
+// Each group contains the names of the subcommands returned by Subcommands
+// that are not Hidden, sorted by name. We omit the groups not containing
+// any of such subcommands, and we append an "Other commands" group
+// containing the subcommands not assigned to any group, if any.
+func (dx *DispatcherCommand[T]) SubcommandGroups() []CommandGroup {
+	if len(dx.Groups) <= 0 {
+		return nil
+	}
+	commands := dx.cloneSubcommandsForUsage()
+	var output []CommandGroup
+	for _, group := range dx.Groups {
+		var names []string
+		for _, name := range group.Commands {
+			if commands[name] != nil {
+				names = append(names, name)
+				delete(commands, name)
+			}
+		}
+		if len(names) > 0 {
+			sort.Strings(names)
+			output = append(output, CommandGroup{Title: group.Title, Commands: names})
+		}
+	}
+	if len(commands) > 0 {
+		output = append(output, CommandGroup{Title: "Other commands", Commands: sortedSubcommandNames(commands)})
+	}
+	return output
+}
+
 // Run implements [Command].
 func (dx *DispatcherCommand[T]) Run(ctx context.Context, args *CommandArgs[T]) error {
-	return dx.maybeHandleError(args.Env, dx.dispatch(ctx, args))
+	return dx.maybeHandleError(ctx, args, dx.dispatch(ctx, args))
 }
 
 // --- error filtering code ---
 
-func (dx *DispatcherCommand[T]) maybeHandleError(env T, err error) error {
+// maybeHandleError handles the error according to the ErrorHandling policy.
+//
+// With [nflag.ExitOnError], we behave like the [*RootCommand] would do, since
+// we exit before returning to it: we map cancellations caused by signals to
+// the signal exit status and we print the errors not reported yet.
+func (dx *DispatcherCommand[T]) maybeHandleError(ctx context.Context, args *CommandArgs[T], err error) error {
 	// Determine what to do based on the policy
 	switch {
 	case err == nil:
@@ -111,12 +339,11 @@ func (dx *DispatcherCommand[T]) maybeHandleError(env T, err error) error {
 		return err
 
 	case dx.ErrorHandling == nflag.ExitOnError:
-		switch {
-		case errors.Is(err, ErrNoSuchCommand):
-			env.Exit(2)
-		default:
-			env.Exit(1)
+		err = signalExitError(ctx, err)
+		if !isReportedError(err) {
+			printError(args.Env.Stderr(), args.OutputFormat, args.CommandName, err)
 		}
+		args.Env.Exit(ExitCode(err))
 	}
 
 	// We end up here for [PanicOnError] or whenever env.Exit is so
@@ -127,70 +354,52 @@ func (dx *DispatcherCommand[T]) maybeHandleError(env T, err error) error {
 // --- dispatching code ---
 
 func (dx *DispatcherCommand[T]) dispatch(ctx context.Context, args *CommandArgs[T]) error {
-	// Handle the case where there are no arguments
-	if len(args.Args) <= 0 {
-		return dx.printUsage(args.Env, args.CommandName)
+	// Parse and remove the persistent flags unless we are completing
+	// the command line, which needs them (see [*CompleteCommand])
+	if !isCompleteCommandLine(Command[T](dx), args.Args) {
+		nargs, err := dx.parsePersistentFlags(args)
+		if err != nil {
+			return err
+		}
+		args = nargs
 	}
 
-	// Unconditionally scan the command line. Note that, with empty separators
-	// and prefixes, the scanner will return all positional arguments.
-	//
-	// Also, the scanner only fails if the program name is missing, which is
-	// something we already checked for above, hence the assert.
-	//
-	// Also, the scanner returns the program name as the first token, we
-	// know that, so we can assert again for the type and then skip it.
-	sx := &scanner.Scanner{Prefixes: dx.OptionPrefixes, Separators: []string{}}
-	if dx.OptionsArgumentsSeparator != "" {
-		sx.Separators = append(sx.Separators, dx.OptionsArgumentsSeparator)
+	// Handle the case where there are no arguments
+	if len(args.Args) <= 0 {
+		return dx.printUsage(args)
 	}
-	argv := append([]string{args.CommandName}, args.Args...)
-	tokens := assert.NotError1(sx.Scan(argv))
-	_, ok := tokens[0].(scanner.ProgramNameToken)
-	assert.True(ok, "the first token must be a ProgramNameToken")
-	tokens = tokens[1:]
 
-	// Now, scan the tokens to find the subcommand name.
-	commandIdx := -1
-scannerLoop:
-	for idx := 0; idx < len(tokens); idx++ {
-		switch tokens[idx].(type) {
-		case scanner.PositionalArgumentToken:
-			commandIdx = idx // first positional argument stops the search
-			break scannerLoop
-		case scanner.OptionsArgumentsSeparatorToken:
-			break scannerLoop
-		}
-	}
+	// Scan the command line to find the subcommand name.
+	tokens, commandIdx := dx.scanCommandLine(args.CommandName, args.Args)
 
 	// With a subcommand name, we're in business.
 	if commandIdx >= 0 {
 		// Reorder the command line arguments to move the subcommand at the beginning
-		var subArgs []string
-		subName := tokens[commandIdx].String()
-		for idx := 0; idx < len(tokens); idx++ {
-			if idx != commandIdx {
-				subArgs = append(subArgs, tokens[idx].String())
-			}
+		subName, subArgs := reorderCommandLine(tokens, commandIdx)
+
+		// Attempt to find the command resolving aliases and abbreviations and
+		// synthesizing the `version` and `completion` commands when needed
+		dx.maybeWarnDeprecated(args, subName)
+		name, cmd, err := dx.lookupCommand(args.CommandName, subName)
+		if err != nil {
+			return dx.errorAmbiguousCommand(args, err)
 		}
-
-		// Attempt an exact match with subName
-		if cmd := dx.Commands[subName]; cmd != nil {
-			return dx.run(ctx, cmd, args, subName, subArgs)
+		if cmd != nil {
+			return dx.run(ctx, cmd, args, name, subArgs)
 		}
 
-		// Special case: synthesize `help` and `version` commands
-		// when they have not been explicitly defined
-		switch {
-		case subName == "help":
+		// Special case: synthesize `help` when not explicitly defined
+		if subName == "help" {
 			return dx.maybeForwardHelp(ctx, args, subArgs)
+		}
 
-		case dx.Version != "" && subName == "version":
-			return dx.handleVersionCommand(ctx, args, subArgs)
+		// Possibly run an external command
+		if cmd := dx.lookupExternalCommand(args.Env, args.canonicalCommandName(), subName); cmd != nil {
+			return dx.run(ctx, cmd, args, subName, subArgs)
 		}
 
 		// Otherwise mention that the given command was not found
-		return dx.errorNoSuchCommand(args.Env, args.CommandName, subName)
+		return dx.errorNoSuchCommand(args, subName)
 	}
 
 	// If we have tokens, attempt to find a `--help` or `--version` or `-h`
@@ -200,30 +409,92 @@ scannerLoop:
 		case scanner.OptionToken:
 			switch {
 			case tok.Name == "help" || tok.Name == "h":
-				return dx.printUsage(args.Env, args.CommandName)
+				return dx.printUsage(args)
 			case dx.Version != "" && tok.Name == "version":
 				return dx.handleVersionFlag(args.Env)
 			}
 		}
 	}
 
-	return dx.errorInvalidFlags(args.Env, args.CommandName, args.Args)
+	return dx.errorInvalidFlags(args)
+}
+
+// scanCommandLine scans the given arguments and returns the tokens, not including
+// the program name, along with the index of the token containing the subcommand
+// name. The returned index is negative when there is no subcommand name.
+func (dx *DispatcherCommand[T]) scanCommandLine(commandName string, args []string) ([]scanner.Token, int) {
+	// Unconditionally scan the command line. Note that, with empty separators
+	// and prefixes, the scanner will return all positional arguments.
+	//
+	// Also, the scanner only fails if the program name is missing, which is
+	// something we cannot have since we prepend the command name, hence the assert.
+	//
+	// Also, the scanner returns the program name as the first token, we
+	// know that, so we can assert again for the type and then skip it.
+	sx := &scanner.Scanner{Prefixes: dx.OptionPrefixes, Separators: []string{}}
+	if dx.OptionsArgumentsSeparator != "" {
+		sx.Separators = append(sx.Separators, dx.OptionsArgumentsSeparator)
+	}
+	argv := append([]string{commandName}, args...)
+	tokens := assert.NotError1(sx.Scan(argv))
+	_, ok := tokens[0].(scanner.ProgramNameToken)
+	assert.True(ok, "the first token must be a ProgramNameToken")
+	tokens = tokens[1:]
+
+	// Now, scan the tokens to find the subcommand name.
+	for idx := 0; idx < len(tokens); idx++ {
+		switch tokens[idx].(type) {
+		case scanner.PositionalArgumentToken:
+			return tokens, idx // first positional argument stops the search
+		case scanner.OptionsArgumentsSeparatorToken:
+			return tokens, -1
+		}
+	}
+	return tokens, -1
+}
+
+// reorderCommandLine returns the subcommand name and the subcommand arguments
+// given the tokens and the index of the subcommand name.
+func reorderCommandLine(tokens []scanner.Token, commandIdx int) (string, []string) {
+	var subArgs []string
+	subName := tokens[commandIdx].String()
+	for idx := 0; idx < len(tokens); idx++ {
+		if idx != commandIdx {
+			subArgs = append(subArgs, tokens[idx].String())
+		}
+	}
+	return subName, subArgs
 }
 
 // ErrInvalidFlags is returned when the command line contains invalid
 // flags and no subcommand is specified.
 var ErrInvalidFlags = errors.New("invalid flags")
 
-func (dx *DispatcherCommand[T]) errorInvalidFlags(env T, commandName string, args []string) error {
+func (dx *DispatcherCommand[T]) errorInvalidFlags(args *CommandArgs[T]) error {
+	if args.OutputFormat == nflag.OutputFormatJSON {
+		report := newErrorReport(args.CommandName, ErrInvalidFlags)
+		report.Value = shellquote.Join(args.Args...)
+		nflag.PrintJSON(args.Env.Stderr(), report)
+		return ErrInvalidFlags
+	}
 	var sb strings.Builder
-	fmt.Fprintf(&sb, "%s: invalid flags: %s\n", commandName, shellquote.Join(args...))
-	fmt.Fprintf(&sb, "Try '%s --help' for more information.\n", commandName)
-	fmt.Fprintln(env.Stderr(), strings.TrimSpace(sb.String()))
+	fmt.Fprintf(&sb, "%s: invalid flags: %s\n", args.CommandName, shellquote.Join(args.Args...))
+	fmt.Fprintf(&sb, "Try '%s --help' for more information.\n", args.CommandName)
+	fmt.Fprintln(args.Env.Stderr(), strings.TrimSpace(sb.String()))
 	return ErrInvalidFlags
 }
 
-func (dx *DispatcherCommand[T]) printUsage(env T, commandName string) error {
-	_, err := fmt.Fprintln(env.Stdout(), dx.formatUsage(commandName))
+func (dx *DispatcherCommand[T]) printUsage(args *CommandArgs[T]) error {
+	var external map[string]Command[T]
+	if dx.ExternalCommands {
+		external = listExternalCommands(args.Env, args.canonicalCommandName())
+	}
+	if args.OutputFormat == nflag.OutputFormatJSON {
+		nflag.PrintJSON(args.Env.Stdout(), dx.usageReport(args.CommandName, external, args.PersistentFlags))
+		return nil
+	}
+	usage := dx.formatUsage(args.CommandName, external, args.PersistentFlags, usageTerminal(args))
+	_, err := fmt.Fprintln(args.Env.Stdout(), usage)
 	return err
 }
 
@@ -232,10 +503,130 @@ func (dx *DispatcherCommand[T]) handleVersionFlag(env T) error {
 	return command.PrintVersion(env)
 }
 
-func (dx *DispatcherCommand[T]) handleVersionCommand(
-	ctx context.Context, args *CommandArgs[T], subArgs []string) error {
-	command := &VersionCommand[T]{ErrorHandling: dx.ErrorHandling, Version: dx.Version}
-	return dx.run(ctx, command, args, "version", subArgs)
+// lookupCommand returns the command to use for the given subcommand name,
+// looking it up into Commands, resolving Aliases and abbreviations, forwarding
+// Deprecated commands to their replacement, and possibly synthesizing it.
+// The commandName argument is the name of this dispatcher. The returned
+// name is the subcommand name to use when running the command, which
+// depends on CanonicalCommandName. The returned command is nil when not found.
+// The returned error is an [*AmbiguousCommandError] when the name is an
+// ambiguous abbreviation.
+func (dx *DispatcherCommand[T]) lookupCommand(commandName, subName string) (string, Command[T], error) {
+	name, err := dx.resolveName(subName)
+	if err != nil {
+		return "", nil, err
+	}
+	if deprecation := dx.Deprecated[name]; deprecation.Forward && deprecation.Replacement != "" {
+		name, subName = deprecation.Replacement, deprecation.Replacement
+	}
+	cmd := dx.Commands[name]
+	if cmd == nil {
+		cmd = dx.synthesizeCommand(commandName, name)
+	}
+	if cmd != nil && dx.CanonicalCommandName {
+		subName = name
+	}
+	return subName, cmd, nil
+}
+
+// canonicalName is like resolveName but returns the given name on error.
+func (dx *DispatcherCommand[T]) canonicalName(subName string) string {
+	name, err := dx.resolveName(subName)
+	if err != nil {
+		return subName
+	}
+	return name
+}
+
+// resolveName returns the canonical name corresponding to the given alias or
+// abbreviation, or the name itself when it is neither an alias nor an abbreviation.
+func (dx *DispatcherCommand[T]) resolveName(subName string) (string, error) {
+	if dx.Commands[subName] != nil {
+		return subName, nil
+	}
+	if canonical, found := dx.Aliases[subName]; found {
+		return canonical, nil
+	}
+	if !dx.AllowAbbreviations {
+		return subName, nil
+	}
+	commands := dx.cloneSubcommandsForUsage()
+	if commands[subName] != nil {
+		return subName, nil
+	}
+	var candidates []string
+	for _, name := range sortedSubcommandNames(commands) {
+		if strings.HasPrefix(name, subName) {
+			candidates = append(candidates, name)
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return subName, nil
+	case 1:
+		return candidates[0], nil
+	default:
+		return "", &AmbiguousCommandError{Name: subName, Candidates: candidates}
+	}
+}
+
+// lookupExternalCommand returns the external command implementing
+// the given subcommand, or nil if ExternalCommands is false or there
+// is no such command.
+func (dx *DispatcherCommand[T]) lookupExternalCommand(env T, commandName, subName string) Command[T] {
+	if !dx.ExternalCommands {
+		return nil
+	}
+	return lookupExternalCommand(env, commandName, subName)
+}
+
+// maybeWarnDeprecated prints a warning on the standard error, using the
+// selected output format, when the given subcommand name refers to a
+// Deprecated command.
+func (dx *DispatcherCommand[T]) maybeWarnDeprecated(args *CommandArgs[T], subName string) {
+	name, err := dx.resolveName(subName)
+	if err != nil {
+		return
+	}
+	deprecation, found := dx.Deprecated[name]
+	if !found {
+		return
+	}
+	report := &nflag.ErrorReport{
+		Kind:    "deprecated_command",
+		Program: args.CommandName,
+		Message: fmt.Sprintf("'%s' is deprecated", name),
+		Command: name,
+	}
+	if deprecation.Replacement != "" {
+		report.Message += fmt.Sprintf("; use '%s' instead", deprecation.Replacement)
+		report.Suggestions = []string{deprecation.Replacement}
+	}
+	printWarning(args.Env.Stderr(), args.OutputFormat, report)
+}
+
+// synthesizeCommand returns the command to use for the given name when
+// it is not explicitly defined inside Commands. The commandName argument is
+// the name of this dispatcher. The return value is nil when we do not
+// know how to synthesize the given command.
+func (dx *DispatcherCommand[T]) synthesizeCommand(commandName, subName string) Command[T] {
+	switch {
+	case dx.Version != "" && subName == "version":
+		return &VersionCommand[T]{ErrorHandling: dx.ErrorHandling, Version: dx.Version}
+
+	case dx.Completion && subName == "completion":
+		return &CompletionCommand[T]{
+			Command:       dx,
+			ErrorHandling: dx.ErrorHandling,
+			ProgramName:   filepath.Base(commandName),
+		}
+
+	case dx.Completion && subName == "__complete":
+		return &CompleteCommand[T]{Command: dx, CommandName: commandName}
+
+	default:
+		return nil
+	}
 }
 
 func (dx *DispatcherCommand[T]) maybeForwardHelp(
@@ -248,23 +639,24 @@ func (dx *DispatcherCommand[T]) maybeForwardHelp(
 	// So the first action is to check whether there's anything
 	// after `help` otherwise it's equivalent to `--help`
 	if len(subArgs) <= 0 {
-		return dx.printUsage(args.Env, args.CommandName)
+		return dx.printUsage(args)
 	}
 	subName, subArgs := subArgs[0], subArgs[1:]
 
-	// Attempt to locate the command
-	cmd := dx.Commands[subName]
+	// Attempt to locate the command, possibly synthesizing it
+	dx.maybeWarnDeprecated(args, subName)
+	subName, cmd, err := dx.lookupCommand(args.CommandName, subName)
+	if err != nil {
+		return dx.errorAmbiguousCommand(args, err)
+	}
+	if cmd == nil {
+		cmd = dx.lookupExternalCommand(args.Env, args.canonicalCommandName(), subName)
+	}
 
 	switch {
-	// Handle the case of autogenerated version subcommand
-	case cmd == nil && dx.Version != "" && subName == "version":
-		cmd = &VersionCommand[T]{ErrorHandling: dx.ErrorHandling, Version: dx.Version}
-		subArgs = append(subArgs, cmd.HelpFlag())
-		return dx.run(ctx, cmd, args, subName, subArgs)
-
 	// We don't have a subcommand with the provided name
 	case cmd == nil:
-		return dx.errorNoSuchCommand(args.Env, args.CommandName, subName)
+		return dx.errorNoSuchCommand(args, subName)
 
 	// The subcommand supports subcommands so we can ask for it to provide help
 	case cmd.SupportsSubcommands():
@@ -281,29 +673,171 @@ func (dx *DispatcherCommand[T]) maybeForwardHelp(
 func (dx *DispatcherCommand[T]) run(
 	ctx context.Context, cmd Command[T], args *CommandArgs[T], subName string, subArgs []string) error {
 	nargs := &CommandArgs[T]{
-		Args:        subArgs,
-		Command:     cmd,
-		CommandName: args.CommandName + " " + subName,
-		Env:         args.Env,
-		Parent:      dx,
+		Args:                 subArgs,
+		CanonicalCommandName: args.canonicalCommandName() + " " + dx.canonicalName(subName),
+		Command:              cmd,
+		CommandName:          args.CommandName + " " + subName,
+		Env:                  args.Env,
+		Middleware:           appendMiddleware(args.Middleware, dx.Middleware...),
+		OutputFormat:         args.OutputFormat,
+		Parent:               dx,
+		PersistentFlags:      args.PersistentFlags,
+		UsageColors:          args.UsageColors,
+		root:                 args.root,
 	}
-	return cmd.Run(ctx, nargs)
+	return runCommand(ctx, cmd, nargs)
 }
 
 // ErrNoSuchCommand is returned when a command is not found.
+//
+// The [*DispatcherCommand] wraps this error using [*NoSuchCommandError].
 var ErrNoSuchCommand = errors.New("no such command")
 
-func (dx *DispatcherCommand[T]) errorNoSuchCommand(env T, commandName, subcommandName string) error {
+// NoSuchCommandError is the error returned by [*DispatcherCommand] when
+// the subcommand is not found. It wraps [ErrNoSuchCommand].
+type NoSuchCommandError struct {
+	// Name is the name of the subcommand that was not found.
+	Name string
+
+	// Suggestions contains the names of the most similar subcommands,
+	// sorted by similarity, which may be empty.
+	Suggestions []string
+}
+
+var _ error = &NoSuchCommandError{}
+
+// Error implements error.
+func (err *NoSuchCommandError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrNoSuchCommand.Error(), err.Name)
+}
+
+// Unwrap returns [ErrNoSuchCommand].
+func (err *NoSuchCommandError) Unwrap() error {
+	return ErrNoSuchCommand
+}
+
+func (dx *DispatcherCommand[T]) errorNoSuchCommand(args *CommandArgs[T], subcommandName string) error {
+	err := &NoSuchCommandError{Name: subcommandName, Suggestions: dx.suggestSubcommands(subcommandName)}
+	if args.OutputFormat == nflag.OutputFormatJSON {
+		nflag.PrintJSON(args.Env.Stderr(), newErrorReport(args.CommandName, err))
+		return err
+	}
+	commandName := args.CommandName
 	var sb strings.Builder
 	fmt.Fprintf(&sb, "%s: no such command: %s\n", commandName, subcommandName)
+	switch len(err.Suggestions) {
+	case 0:
+		// nothing
+	case 1:
+		fmt.Fprintf(&sb, "Did you mean this?\n")
+	default:
+		fmt.Fprintf(&sb, "Did you mean one of these?\n")
+	}
+	for _, name := range err.Suggestions {
+		fmt.Fprintf(&sb, "  %s\n", name)
+	}
 	fmt.Fprintf(&sb, "Try '%s --help' for more information.\n", commandName)
-	fmt.Fprintln(env.Stderr(), strings.TrimSpace(sb.String()))
-	return ErrNoSuchCommand
+	fmt.Fprintln(args.Env.Stderr(), strings.TrimSpace(sb.String()))
+	return err
+}
+
+// ErrAmbiguousCommand is returned when an abbreviated command name
+// matches more than one command.
+//
+// The [*DispatcherCommand] wraps this error using [*AmbiguousCommandError].
+var ErrAmbiguousCommand = errors.New("ambiguous command")
+
+// AmbiguousCommandError is the error returned by [*DispatcherCommand] when
+// the subcommand name is an abbreviation matching more than one subcommand.
+// It wraps [ErrAmbiguousCommand].
+type AmbiguousCommandError struct {
+	// Name is the ambiguous abbreviated subcommand name.
+	Name string
+
+	// Candidates contains the names of the matching subcommands sorted by name.
+	Candidates []string
+}
+
+var _ error = &AmbiguousCommandError{}
+
+// Error implements error.
+func (err *AmbiguousCommandError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrAmbiguousCommand.Error(), err.Name)
+}
+
+// Unwrap returns [ErrAmbiguousCommand].
+func (err *AmbiguousCommandError) Unwrap() error {
+	return ErrAmbiguousCommand
+}
+
+func (dx *DispatcherCommand[T]) errorAmbiguousCommand(args *CommandArgs[T], err error) error {
+	if args.OutputFormat == nflag.OutputFormatJSON {
+		nflag.PrintJSON(args.Env.Stderr(), newErrorReport(args.CommandName, err))
+		return err
+	}
+	commandName := args.CommandName
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: %s\n", commandName, err.Error())
+	var ambiguous *AmbiguousCommandError
+	if errors.As(err, &ambiguous) {
+		fmt.Fprintf(&sb, "It may be one of these:\n")
+		for _, name := range ambiguous.Candidates {
+			fmt.Fprintf(&sb, "  %s\n", name)
+		}
+	}
+	fmt.Fprintf(&sb, "Try '%s --help' for more information.\n", commandName)
+	fmt.Fprintln(args.Env.Stderr(), strings.TrimSpace(sb.String()))
+	return err
+}
+
+// suggestSubcommands returns the subcommands, including the synthesized
+// `help` command, most similar to the given unknown subcommand name.
+//
+// We also compare the name with the Aliases, but we suggest the canonical
+// names, only once. To avoid suggesting unrelated short names, we allow at
+// most an edit every two letters of the given name.
+func (dx *DispatcherCommand[T]) suggestSubcommands(subcommandName string) []string {
+	maxDistance := dx.SuggestionsMaxDistance
+	if maxDistance == 0 {
+		maxDistance = DefaultSuggestionsMaxDistance
+	}
+	maxDistance = min(maxDistance, utf8.RuneCountInString(subcommandName)/2)
+	maxCount := dx.SuggestionsMaxCount
+	if maxCount == 0 {
+		maxCount = DefaultSuggestionsMaxCount
+	}
+	commands := dx.cloneSubcommandsForUsage()
+	if commands["help"] == nil {
+		commands["help"] = dx
+	}
+	canonical := make(map[string]string)
+	for alias, name := range dx.Aliases {
+		if commands[alias] == nil && commands[name] != nil && !dx.Hidden[alias] {
+			commands[alias] = commands[name]
+			canonical[alias] = name
+		}
+	}
+	names := sortedSubcommandNames(commands)
+	var output []string
+	for _, name := range suggest.Suggest(subcommandName, names, maxDistance, len(names)) {
+		if cname, found := canonical[name]; found {
+			name = cname
+		}
+		if len(output) < maxCount && !slices.Contains(output, name) {
+			output = append(output, name)
+		}
+	}
+	return output
 }
 
 // --- formatting code ---
 
-func (dx *DispatcherCommand[T]) formatUsage(commandName string) string {
+// formatUsage formats the usage, listing the given external commands, if any,
+// except the ones shadowed by other subcommands, which take precedence, and
+// the flags of the given persistent flag sets, if any. We wrap the text using
+// the width of the given [Terminal] and we style it when it allows colors.
+func (dx *DispatcherCommand[T]) formatUsage(
+	commandName string, external map[string]Command[T], persistent []*nflag.FlagSet, term Terminal) string {
 	// If the user configured the usage string, use it
 	if dx.Usage != "" {
 		return dx.Usage
@@ -311,23 +845,43 @@ func (dx *DispatcherCommand[T]) formatUsage(commandName string) string {
 
 	// Otherwise, create a simple usage string message
 	var sb strings.Builder
+	style, width := termstyle.Style(term.Colors), term.UsageWidth()
 
 	// Synopsis
 	fmt.Fprintf(&sb, "\n")
-	fmt.Fprintf(&sb, "Usage: %s [command] [args]\n", commandName)
+	if len(persistent) > 0 {
+		fmt.Fprintf(&sb, "%s %s [options] [command] [args]\n", style.Heading("Usage:"), commandName)
+	} else {
+		fmt.Fprintf(&sb, "%s %s [command] [args]\n", style.Heading("Usage:"), commandName)
+	}
 
 	// Description
 	fmt.Fprintf(&sb, "\n")
-	fmt.Fprintf(&sb, "%s\n", textwrap.Do(dx.BriefDescriptionText, 72, ""))
+	fmt.Fprintf(&sb, "%s\n", textwrap.Do(dx.BriefDescriptionText, width, ""))
 
 	// Commands
+	commands, groups := dx.usageGroups(commandName, external)
+	aliases := dx.aliasesByCommand()
 	fmt.Fprintf(&sb, "\n")
-	fmt.Fprintf(&sb, "Commands:\n")
-	commands := dx.cloneSubcommandsForUsage()
-	for _, name := range sortedSubcommandNames(commands) {
-		fmt.Fprintf(&sb, "  %s\n", name)
-		cmd := commands[name]
-		fmt.Fprintf(&sb, "%s\n\n", textwrap.Do(cmd.BriefDescription(), 72, "    "))
+	for _, group := range groups {
+		fmt.Fprintf(&sb, "%s\n", style.Heading(group.Title+":"))
+		for _, name := range group.Commands {
+			fmt.Fprintf(&sb, "  %s\n", strings.Join(append([]string{name}, aliases[name]...), ", "))
+			cmd := commands[name]
+			fmt.Fprintf(&sb, "%s\n\n", textwrap.Do(cmd.BriefDescription(), width, "    "))
+		}
+	}
+
+	// Global options
+	var globals bool
+	for _, fset := range persistent {
+		if !globals && len(fset.Flags()) > 0 {
+			fmt.Fprintf(&sb, "%s\n", style.Heading("Global options:"))
+			globals = true
+		}
+		view := *fset // format the flags consistently with the rest of the usage
+		view.UsageColors, view.UsageWidth = term.Colors, width
+		view.PrintFlagsUsage(&sb)
 	}
 
 	// Conclusion
@@ -342,10 +896,62 @@ func (dx *DispatcherCommand[T]) formatUsage(commandName string) string {
 	return strings.TrimSpace(sb.String())
 }
 
+// usageGroups returns the commands listed by the usage, including the given external
+// commands not shadowed by other subcommands, and the sections listing them.
+func (dx *DispatcherCommand[T]) usageGroups(
+	commandName string, external map[string]Command[T]) (map[string]Command[T], []CommandGroup) {
+	commands := dx.cloneSubcommandsForUsage()
+	groups := dx.SubcommandGroups()
+	if groups == nil {
+		groups = []CommandGroup{{Title: "Commands", Commands: sortedSubcommandNames(commands)}}
+	}
+	external = maps.Clone(external)
+	for name := range external {
+		if _, cmd, err := dx.lookupCommand(commandName, name); cmd != nil || err != nil || name == "help" {
+			delete(external, name)
+		}
+	}
+	if len(external) > 0 {
+		maps.Copy(commands, external)
+		groups = append(groups, CommandGroup{Title: "External commands", Commands: sortedSubcommandNames(external)})
+	}
+	return commands, groups
+}
+
 func (dx *DispatcherCommand[T]) cloneSubcommandsForUsage() map[string]Command[T] {
+	output := dx.cloneSubcommands()
+	for name := range dx.Hidden {
+		delete(output, name)
+	}
+	return output
+}
+
+func (dx *DispatcherCommand[T]) cloneSubcommands() map[string]Command[T] {
 	output := maps.Clone(dx.Commands)
-	if dx.Version != "" && output["version"] == nil {
-		output["version"] = &VersionCommand[T]{Version: dx.Version}
+	if output == nil {
+		output = make(map[string]Command[T])
+	}
+	for _, name := range []string{"completion", "version"} {
+		if output[name] == nil {
+			if cmd := dx.synthesizeCommand("", name); cmd != nil {
+				output[name] = cmd
+			}
+		}
+	}
+	return output
+}
+
+// aliasesByCommand maps each canonical name to its sorted aliases, ignoring
+// the aliases shadowed by Commands entries.
+func (dx *DispatcherCommand[T]) aliasesByCommand() map[string][]string {
+	output := make(map[string][]string)
+	for alias, name := range dx.Aliases {
+		if dx.Commands[alias] == nil && !dx.Hidden[alias] {
+			output[name] = append(output[name], alias)
+		}
+	}
+	for _, aliases := range output {
+		sort.Strings(aliases)
 	}
 	return output
 }
@@ -4,9 +4,11 @@
 package clip
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/bassosimone/clip/pkg/nflag"
@@ -39,12 +41,30 @@ func TestDispatch(t *testing.T) {
 		}
 	})
 
+	t.Run("Subcommands", func(t *testing.T) {
+		dx := &DispatcherCommand[*StdlibExecEnv]{
+			Commands: map[string]Command[*StdlibExecEnv]{
+				"test": &DispatcherCommand[*StdlibExecEnv]{},
+			},
+			Completion: true,
+			Version:    "0.1.0",
+		}
+		commands := dx.Subcommands()
+		if diff := cmp.Diff([]string{"completion", "test", "version"}, sortedSubcommandNames(commands)); diff != "" {
+			t.Errorf("Subcommands() mismatch (-want +got):\n%s", diff)
+		}
+		delete(commands, "test")
+		if dx.Commands["test"] == nil {
+			t.Error("Subcommands() returned the original map")
+		}
+	})
+
 	t.Run("formatUsage", func(t *testing.T) {
 		expected := "Usage: test [subcommand]\n\nAvailable subcommands:\n  dig    Dig for something\n  tools  Tools for various tasks\n"
 		dx := &DispatcherCommand[*StdlibExecEnv]{
 			Usage: expected,
 		}
-		if diff := cmp.Diff(dx.formatUsage("test"), expected); diff != "" {
+		if diff := cmp.Diff(dx.formatUsage("test", nil, nil, Terminal{}), expected); diff != "" {
 			t.Errorf("formatUsage() mismatch (-want +got):\n%s", diff)
 		}
 	})
@@ -422,3 +442,587 @@ func TestDispatch(t *testing.T) {
 		})
 	})
 }
+
+func TestDispatchSuggestions(t *testing.T) {
+	type testcase struct {
+		name        string
+		args        []string
+		maxDistance int
+		maxCount    int
+		expect      []string
+		expectLines string
+	}
+
+	cases := []testcase{
+		{
+			name:        "single suggestion",
+			args:        []string{"gti", "clone"},
+			expect:      []string{"git"},
+			expectLines: "test: no such command: gti\nDid you mean this?\n  git\nTry 'test --help' for more information.\n",
+		},
+
+		{
+			name:        "multiple suggestions",
+			args:        []string{"gits"},
+			expect:      []string{"gist", "git"},
+			expectLines: "test: no such command: gits\nDid you mean one of these?\n  gist\n  git\nTry 'test --help' for more information.\n",
+		},
+
+		{
+			name:        "one-letter names are not similar to everything",
+			args:        []string{"x"},
+			expect:      nil,
+			expectLines: "test: no such command: x\nTry 'test --help' for more information.\n",
+		},
+
+		{
+			name:        "two-letter names are not similar to unrelated aliases",
+			args:        []string{"ab"},
+			expect:      nil,
+			expectLines: "test: no such command: ab\nTry 'test --help' for more information.\n",
+		},
+
+		{
+			name:        "we suggest the canonical name of aliases once",
+			args:        []string{"gi"},
+			expect:      []string{"git"},
+			expectLines: "Did you mean this?\n  git\n",
+		},
+
+		{
+			name:        "synthesized commands",
+			args:        []string{"verison"},
+			expect:      []string{"version"},
+			expectLines: "Did you mean this?\n  version\n",
+		},
+
+		{
+			name:        "synthesized help command",
+			args:        []string{"hlep"},
+			expect:      []string{"help"},
+			expectLines: "Did you mean this?\n  help\n",
+		},
+
+		{
+			name:        "help followed by an unknown command",
+			args:        []string{"help", "gti"},
+			expect:      []string{"git"},
+			expectLines: "Did you mean this?\n  git\n",
+		},
+
+		{
+			name:        "limited count",
+			args:        []string{"gat"},
+			maxCount:    1,
+			expect:      []string{"git"},
+			expectLines: "Did you mean this?\n  git\n",
+		},
+
+		{
+			name:        "limited distance",
+			args:        []string{"gt"},
+			maxDistance: 1,
+			expect:      []string{"git"},
+			expectLines: "Did you mean this?\n  git\n",
+		},
+
+		{
+			name:        "disabled suggestions",
+			args:        []string{"gti"},
+			maxDistance: -1,
+			expect:      nil,
+			expectLines: "test: no such command: gti\nTry 'test --help' for more information.\n",
+		},
+
+		{
+			name:        "no similar command",
+			args:        []string{"xyzzy"},
+			expect:      nil,
+			expectLines: "test: no such command: xyzzy\nTry 'test --help' for more information.\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stderr bytes.Buffer
+			env := NewStdlibExecEnv()
+			env.OSStderr = &stderr
+			leaf := &LeafCommand[*StdlibExecEnv]{
+				RunFunc: func(ctx context.Context, args *CommandArgs[*StdlibExecEnv]) error {
+					return nil
+				},
+			}
+			dx := &DispatcherCommand[*StdlibExecEnv]{
+				Aliases: map[string]string{"g": "git"},
+				Commands: map[string]Command[*StdlibExecEnv]{
+					"gist": leaf,
+					"git":  leaf,
+				},
+				SuggestionsMaxCount:    tc.maxCount,
+				SuggestionsMaxDistance: tc.maxDistance,
+				Version:                "0.1.0",
+			}
+			err := dx.Run(context.Background(), &CommandArgs[*StdlibExecEnv]{
+				Args:        tc.args,
+				Command:     dx,
+				CommandName: "test",
+				Env:         env,
+			})
+			if !errors.Is(err, ErrNoSuchCommand) {
+				t.Fatalf("expected ErrNoSuchCommand, got %v", err)
+			}
+			var nsc *NoSuchCommandError
+			if !errors.As(err, &nsc) {
+				t.Fatalf("expected *NoSuchCommandError, got %T", err)
+			}
+			if diff := cmp.Diff(tc.expect, nsc.Suggestions); diff != "" {
+				t.Errorf("Suggestions mismatch (-want +got):\n%s", diff)
+			}
+			if !strings.Contains(stderr.String(), tc.expectLines) {
+				t.Errorf("expected %q inside %q", tc.expectLines, stderr.String())
+			}
+		})
+	}
+}
+
+func TestDispatchAliases(t *testing.T) {
+	type testcase struct {
+		name            string
+		args            []string
+		canonical       bool
+		expectName      string
+		expectCanonical string
+		expectStdout    string
+	}
+
+	cases := []testcase{
+		{
+			name:            "canonical name",
+			args:            []string{"git", "clone"},
+			expectName:      "test git clone",
+			expectCanonical: "test git clone",
+		},
+
+		{
+			name:            "alias with the alias as the command name",
+			args:            []string{"g", "clone"},
+			expectName:      "test g clone",
+			expectCanonical: "test git clone",
+		},
+
+		{
+			name:            "alias with the canonical command name",
+			args:            []string{"g", "clone"},
+			canonical:       true,
+			expectName:      "test git clone",
+			expectCanonical: "test git clone",
+		},
+
+		{
+			name:            "Commands take precedence over Aliases",
+			args:            []string{"gi", "clone"},
+			expectName:      "test gi clone",
+			expectCanonical: "test gi clone",
+		},
+
+		{
+			name:         "help resolves aliases",
+			args:         []string{"help", "g"},
+			expectStdout: "Usage: test g [command] [args]",
+		},
+
+		{
+			name:         "alias of a synthesized command",
+			args:         []string{"v"},
+			expectStdout: "0.1.0",
+		},
+
+		{
+			name:         "usage lists the aliases",
+			args:         []string{"--help"},
+			expectStdout: "  git, g, gt\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			env := NewStdlibExecEnv()
+			env.OSStdout = &stdout
+			var gotName, gotCanonical string
+			leaf := &LeafCommand[*StdlibExecEnv]{
+				RunFunc: func(ctx context.Context, args *CommandArgs[*StdlibExecEnv]) error {
+					gotName, gotCanonical = args.CommandName, args.CanonicalCommandName
+					return nil
+				},
+			}
+			git := &DispatcherCommand[*StdlibExecEnv]{
+				BriefDescriptionText: "Git commands.",
+				Commands:             map[string]Command[*StdlibExecEnv]{"clone": leaf},
+			}
+			dx := &DispatcherCommand[*StdlibExecEnv]{
+				Aliases:              map[string]string{"g": "git", "gi": "git", "gt": "git", "v": "version"},
+				CanonicalCommandName: tc.canonical,
+				Commands:             map[string]Command[*StdlibExecEnv]{"git": git, "gi": git},
+				OptionPrefixes:       []string{"-", "--"},
+				Version:              "0.1.0",
+			}
+			err := dx.Run(context.Background(), &CommandArgs[*StdlibExecEnv]{
+				Args:        tc.args,
+				Command:     dx,
+				CommandName: "test",
+				Env:         env,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.expectName, gotName); diff != "" {
+				t.Errorf("CommandName mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.expectCanonical, gotCanonical); diff != "" {
+				t.Errorf("CanonicalCommandName mismatch (-want +got):\n%s", diff)
+			}
+			if !strings.Contains(stdout.String(), tc.expectStdout) {
+				t.Errorf("expected %q inside %q", tc.expectStdout, stdout.String())
+			}
+		})
+	}
+}
+
+func TestDispatchAbbreviations(t *testing.T) {
+	type testcase struct {
+		name         string
+		args         []string
+		disabled     bool
+		canonical    bool
+		expectName   string
+		expectErr    error
+		expectStderr string
+	}
+
+	cases := []testcase{
+		{
+			name:       "unique prefix",
+			args:       []string{"a", "show"},
+			expectName: "test a",
+		},
+
+		{
+			name:       "unique prefix with the canonical command name",
+			args:       []string{"a", "show"},
+			canonical:  true,
+			expectName: "test address",
+		},
+
+		{
+			name:       "exact names take precedence",
+			args:       []string{"link"},
+			expectName: "test link",
+		},
+
+		{
+			name:       "aliases take precedence",
+			args:       []string{"l"},
+			expectName: "test l",
+		},
+
+		{
+			name:         "ambiguous prefix",
+			args:         []string{"li"},
+			expectErr:    ErrAmbiguousCommand,
+			expectStderr: "test: ambiguous command: li\nIt may be one of these:\n  link\n  linkage\nTry 'test --help' for more information.\n",
+		},
+
+		{
+			name:         "ambiguous prefix after help",
+			args:         []string{"help", "li"},
+			expectErr:    ErrAmbiguousCommand,
+			expectStderr: "test: ambiguous command: li\n",
+		},
+
+		{
+			name:       "prefix of a synthesized command",
+			args:       []string{"vers"},
+			expectName: "",
+		},
+
+		{
+			name:         "no matching command",
+			args:         []string{"x"},
+			expectErr:    ErrNoSuchCommand,
+			expectStderr: "test: no such command: x\n",
+		},
+
+		{
+			name:         "disabled abbreviations",
+			args:         []string{"a"},
+			disabled:     true,
+			expectErr:    ErrNoSuchCommand,
+			expectStderr: "test: no such command: a\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stderr bytes.Buffer
+			env := NewStdlibExecEnv()
+			env.OSStderr = &stderr
+			env.OSStdout = &bytes.Buffer{}
+			var gotName string
+			leaf := &LeafCommand[*StdlibExecEnv]{
+				RunFunc: func(ctx context.Context, args *CommandArgs[*StdlibExecEnv]) error {
+					gotName = args.CommandName
+					return nil
+				},
+			}
+			dx := &DispatcherCommand[*StdlibExecEnv]{
+				Aliases:              map[string]string{"l": "linkage"},
+				AllowAbbreviations:   !tc.disabled,
+				CanonicalCommandName: tc.canonical,
+				Commands: map[string]Command[*StdlibExecEnv]{
+					"address": leaf,
+					"link":    leaf,
+					"linkage": leaf,
+				},
+				Version: "0.1.0",
+			}
+			err := dx.Run(context.Background(), &CommandArgs[*StdlibExecEnv]{
+				Args:        tc.args,
+				Command:     dx,
+				CommandName: "test",
+				Env:         env,
+			})
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v, got %v", tc.expectErr, err)
+			}
+			if diff := cmp.Diff(tc.expectName, gotName); diff != "" {
+				t.Errorf("CommandName mismatch (-want +got):\n%s", diff)
+			}
+			if !strings.Contains(stderr.String(), tc.expectStderr) {
+				t.Errorf("expected %q inside %q", tc.expectStderr, stderr.String())
+			}
+		})
+	}
+
+	t.Run("AmbiguousCommandError", func(t *testing.T) {
+		err := &AmbiguousCommandError{Name: "li", Candidates: []string{"link", "linkage"}}
+		if diff := cmp.Diff("ambiguous command: li", err.Error()); diff != "" {
+			t.Errorf("Error() mismatch (-want +got):\n%s", diff)
+		}
+		if !errors.Is(err, ErrAmbiguousCommand) {
+			t.Error("expected the error to wrap ErrAmbiguousCommand")
+		}
+	})
+}
+
+func TestDispatchHiddenAndDeprecated(t *testing.T) {
+	type testcase struct {
+		name         string
+		args         []string
+		expectName   string
+		expectStdout string
+		expectStderr string
+	}
+
+	cases := []testcase{
+		{
+			name:       "hidden commands are dispatched",
+			args:       []string{"secret"},
+			expectName: "test secret",
+		},
+
+		{
+			name:         "hidden commands are not listed in the usage",
+			args:         []string{"help"},
+			expectStdout: "Commands:\n  address\n    Manage addresses.\n\n  legacy, old\n    Legacy command.\n\n  link\n    Manage links.\n\nTry",
+		},
+
+		{
+			name:         "deprecated commands print a warning",
+			args:         []string{"legacy"},
+			expectName:   "test legacy",
+			expectStderr: "test: warning: 'legacy' is deprecated; use 'link' instead\n",
+		},
+
+		{
+			name:         "deprecated commands print a warning through aliases",
+			args:         []string{"old"},
+			expectName:   "test old",
+			expectStderr: "test: warning: 'legacy' is deprecated; use 'link' instead\n",
+		},
+
+		{
+			name:         "deprecated commands without replacement",
+			args:         []string{"secret-legacy"},
+			expectName:   "test secret-legacy",
+			expectStderr: "test: warning: 'secret-legacy' is deprecated\n",
+		},
+
+		{
+			name:         "deprecated commands forwarded to the replacement",
+			args:         []string{"addr"},
+			expectName:   "test address",
+			expectStderr: "test: warning: 'addr' is deprecated; use 'address' instead\n",
+		},
+
+		{
+			name:         "help for forwarded commands",
+			args:         []string{"help", "addr"},
+			expectName:   "test address",
+			expectStderr: "test: warning: 'addr' is deprecated; use 'address' instead\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			env := NewStdlibExecEnv()
+			env.OSStderr = &stderr
+			env.OSStdout = &stdout
+			var gotName string
+			newLeaf := func(brief string) *LeafCommand[*StdlibExecEnv] {
+				return &LeafCommand[*StdlibExecEnv]{
+					BriefDescriptionText: brief,
+					RunFunc: func(ctx context.Context, args *CommandArgs[*StdlibExecEnv]) error {
+						gotName = args.CommandName
+						return nil
+					},
+				}
+			}
+			dx := &DispatcherCommand[*StdlibExecEnv]{
+				Aliases: map[string]string{"old": "legacy", "sec": "secret"},
+				Commands: map[string]Command[*StdlibExecEnv]{
+					"address":       newLeaf("Manage addresses."),
+					"legacy":        newLeaf("Legacy command."),
+					"link":          newLeaf("Manage links."),
+					"secret":        newLeaf("Secret command."),
+					"secret-legacy": newLeaf("Secret legacy command."),
+				},
+				Deprecated: map[string]Deprecation{
+					"addr":          {Replacement: "address", Forward: true},
+					"legacy":        {Replacement: "link"},
+					"secret-legacy": {},
+				},
+				Hidden: map[string]bool{"secret": true, "secret-legacy": true},
+			}
+			err := dx.Run(context.Background(), &CommandArgs[*StdlibExecEnv]{
+				Args:        tc.args,
+				Command:     dx,
+				CommandName: "test",
+				Env:         env,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.expectName, gotName); diff != "" {
+				t.Errorf("CommandName mismatch (-want +got):\n%s", diff)
+			}
+			if !strings.Contains(stdout.String(), tc.expectStdout) {
+				t.Errorf("expected %q inside %q", tc.expectStdout, stdout.String())
+			}
+			if diff := cmp.Diff(tc.expectStderr, stderr.String()); diff != "" {
+				t.Errorf("stderr mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("hidden commands are part of Subcommands", func(t *testing.T) {
+		leaf := &LeafCommand[*StdlibExecEnv]{}
+		dx := &DispatcherCommand[*StdlibExecEnv]{
+			Commands: map[string]Command[*StdlibExecEnv]{"link": leaf, "secret": leaf},
+			Hidden:   map[string]bool{"secret": true, "version": true},
+			Version:  "0.1.0",
+		}
+		expect := []string{"link", "secret", "version"}
+		if diff := cmp.Diff(expect, sortedSubcommandNames(dx.Subcommands())); diff != "" {
+			t.Errorf("Subcommands() mismatch (-want +got):\n%s", diff)
+		}
+		if !dx.SubcommandHidden("secret") || dx.SubcommandHidden("link") {
+			t.Error("SubcommandHidden returned unexpected values")
+		}
+		if suggestions := dx.suggestSubcommands("secrets"); len(suggestions) != 0 {
+			t.Errorf("expected no suggestions, got %v", suggestions)
+		}
+	})
+}
+
+func TestDispatchGroups(t *testing.T) {
+	leaf := &LeafCommand[*StdlibExecEnv]{BriefDescriptionText: "Leaf command."}
+	dx := &DispatcherCommand[*StdlibExecEnv]{
+		BriefDescriptionText: "Test dispatcher.",
+		Commands: map[string]Command[*StdlibExecEnv]{
+			"clone":  leaf,
+			"commit": leaf,
+			"gc":     leaf,
+			"other":  leaf,
+			"secret": leaf,
+		},
+		Groups: []CommandGroup{
+			{Title: "Core commands", Commands: []string{"commit", "clone", "nonexistent"}},
+			{Title: "Empty", Commands: []string{"secret"}},
+			{Title: "Maintenance", Commands: []string{"gc", "version"}},
+		},
+		Hidden:  map[string]bool{"secret": true},
+		Version: "0.1.0",
+	}
+
+	t.Run("SubcommandGroups", func(t *testing.T) {
+		expect := []CommandGroup{
+			{Title: "Core commands", Commands: []string{"clone", "commit"}},
+			{Title: "Maintenance", Commands: []string{"gc", "version"}},
+			{Title: "Other commands", Commands: []string{"other"}},
+		}
+		if diff := cmp.Diff(expect, dx.SubcommandGroups()); diff != "" {
+			t.Errorf("SubcommandGroups() mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("SubcommandGroups without groups", func(t *testing.T) {
+		dx := &DispatcherCommand[*StdlibExecEnv]{Commands: dx.Commands}
+		if groups := dx.SubcommandGroups(); groups != nil {
+			t.Errorf("expected nil, got %+v", groups)
+		}
+	})
+
+	t.Run("formatUsage", func(t *testing.T) {
+		expect := "Core commands:\n" +
+			"  clone\n    Leaf command.\n\n" +
+			"  commit\n    Leaf command.\n\n" +
+			"Maintenance:\n" +
+			"  gc\n    Leaf command.\n\n" +
+			"  version\n    Print the program version and exit.\n\n" +
+			"Other commands:\n" +
+			"  other\n    Leaf command.\n\n" +
+			"Try 'test help COMMAND'"
+		if usage := dx.formatUsage("test", nil, nil, Terminal{}); !strings.Contains(usage, expect) {
+			t.Errorf("expected %q inside %q", expect, usage)
+		}
+	})
+
+	t.Run("formatUsage using the Terminal", func(t *testing.T) {
+		dx := &DispatcherCommand[*StdlibExecEnv]{
+			BriefDescriptionText: "Test dispatcher managing a collection of tools.",
+			Commands: map[string]Command[*StdlibExecEnv]{
+				"other": &LeafCommand[*StdlibExecEnv]{BriefDescriptionText: "Leaf command doing something."},
+			},
+		}
+		persistent := nflag.NewFlagSet("test", nflag.ContinueOnError)
+		persistent.StringFlag("config", 'c', "Read the configuration from the given file.")
+		expect := "\x1b[1mUsage:\x1b[0m test [options] [command] [args]\n\n" +
+			"Test dispatcher managing\na collection of tools.\n\n" +
+			"\x1b[1mCommands:\x1b[0m\n" +
+			"  other\n    Leaf command doing\n    something.\n\n" +
+			"\x1b[1mGlobal options:\x1b[0m\n" +
+			"  \x1b[1;36m-c\x1b[0m, \x1b[1;36m--config\x1b[0m=\x1b[4mVALUE\x1b[0m\n" +
+			"    Read the\n    configuration from\n    the given file.\n\n" +
+			"Try 'test help COMMAND' for more information on COMMAND.\n\n" +
+			"Use 'test help' to show this help screen."
+		usage := dx.formatUsage("test", nil, []*nflag.FlagSet{persistent}, Terminal{Colors: true, Width: 25})
+		if diff := cmp.Diff(expect, usage); diff != "" {
+			t.Errorf("formatUsage() mismatch (-want +got):\n%s", diff)
+		}
+		if persistent.UsageColors || persistent.UsageWidth != nflag.DefaultUsageWidth {
+			t.Error("formatUsage() modified the persistent flags")
+		}
+	})
+}
@@ -24,6 +24,15 @@ The [*RootCommand] optionally allows to react to signals and otherwise
 dispatches the work to the [*DispatcherCommand] or [*LeafCommand]
 contained within.
 
+With AutoCancel, the first SIGINT or SIGTERM cancels the context passed to
+the commands, and a second signal, or the expiry of the optional GracePeriod,
+forces the program to exit with status 128+N, where N is the signal number
+(e.g., 130 for SIGINT). Set InterruptMessage to tell the user about this.
+The cause of the cancellation is a [*SignalError], which commands may obtain
+using [context.Cause] to distinguish SIGINT from SIGTERM. When the command
+fails with an error wrapping [context.Canceled], the program exits with
+the same 128+N status, unless the error carries its own exit status.
+
 # DispatcherCommand
 
 A [*DispatcherCommand] dispatches the execution to subcommands by
@@ -41,7 +50,44 @@ non-option token and use it as the command to execute. If the
 there is no such command, but the token is "help" we automatically
 print the help message for the [*DispatcherCommand]. If the token
 is "version and you configured the version string, we print the
-version string. Otherwise, we return [ErrNoSuchCommand].
+version string. Otherwise, we return a [*NoSuchCommandError]
+wrapping [ErrNoSuchCommand] and containing the subcommands most
+similar to the unknown one, which we also suggest to the user.
+
+You can also configure aliases for subcommands (e.g., `g` for `git`),
+which the usage lists next to the canonical name. By default, the
+alias is part of the command name seen by the subcommand, but you can
+choose to use the canonical name instead. Additionally, you can opt
+into resolving subcommands from any unambiguous prefix of their name
+(e.g., `a` for `address`), in which case we return an
+[*AmbiguousCommandError] wrapping [ErrAmbiguousCommand] when the
+prefix matches more than one subcommand.
+
+To migrate away from old subcommand names without advertising them,
+you can mark subcommands as hidden, which we dispatch but do not list
+in the usage and completions, and as deprecated, which causes us to
+print a warning and possibly forward to the replacement subcommand.
+
+When there are many subcommands, you can assign them to named groups
+(e.g., "Core commands" and "Maintenance"), in which case the usage,
+the man pages, and the reference documentation list one section per
+group, in the order you configured.
+
+You can also allow third parties to extend the program with external
+commands, like git does. When enabled, we run the `prog-foo` executable
+found in the PATH when the `foo` subcommand does not exist, using an
+[*ExternalCommand] that inherits the standard I/O, the environment and
+the working directory, and returns an [*ExternalCommandError] containing
+the nonzero exit status. Like [os/exec.LookPath], on Windows we consider
+the executables with an extension listed by PATHEXT (`prog-foo.exe`).
+
+Use the PersistentFlags field to declare global flags (e.g., `--verbose`
+or `--config FILE`) that the user may specify before or after any
+subcommand name. Because we know which of these flags require a value,
+we do not confuse their values with subcommand names. The commands below
+the dispatcher list these flags in the "Global options" section of their
+usage and obtain them through the PersistentFlags field of [CommandArgs].
+Therefore, these commands cannot define flags with the same names.
 
 When no command is specified and you configured the options
 prefixes, we also scan the command-line for a flag named `help`
@@ -56,6 +102,81 @@ manually enforce a reasonably consistent convention for
 the command-line interface of your subcommands. But, on the
 flip side, the resulting code is straightforward and readable.
 
+# Middleware
+
+To implement cross-cutting behavior, such as timing, logging, capturing
+panics, and checking authorization, attach [Middleware] to the [*RootCommand]
+or to a [*DispatcherCommand]. The [Middleware] wraps the command that actually
+runs below them, including synthesized commands, and sees its [CommandArgs].
+Use [PreRun] and [PostRun] to create [Middleware] running code before and
+after the command. The function passed to [PostRun] runs even when
+the command fails and receives the error.
+
+# Errors and Exit Statuses
+
+When the command fails, the [*RootCommand] prints the error prefixed by
+the program name, unless it was already printed (e.g., by a dispatcher
+whose subcommand does not exist), and exits with the status returned
+by [ExitCode]. By default, usage errors cause exit status 2 and other
+errors cause exit status 1. To choose the exit status, return an
+[*ExitError], possibly using the sysexits.h constants (e.g., [ExitNoInput]),
+or any error implementing [ExitCoder]. An [*ExitError] without an
+underlying error exits silently with the given status.
+
+# Machine-Readable Output
+
+When other programs run your program and parse its output, set the
+OutputFormat field of [*RootCommand] to [nflag.OutputFormatJSON], or let
+the user select the output format using an environment variable or the
+`--output-format` flag. In such a case, we print errors and warnings on
+the standard error as single line JSON objects (see [nflag.ErrorReport]),
+including the kind (e.g., "unknown_option" or "deprecated_command"), the
+offending option, the token index, and the suggestions, and we print the
+usage on the standard output as JSON objects. The [*nflag.FlagSet] instances
+created using [NewFlagSet] also honour the selected output format.
+
+# Terminal Output
+
+We print the usage of [*DispatcherCommand] and of the [*nflag.FlagSet]
+instances created using [NewFlagSet] according to the [Terminal] returned by
+[DetectTerminal]. We wrap the text leaving a one column margin from the terminal
+width, which the user may override using the `COLUMNS` environment variable, and
+we never wrap beyond [MaxUsageWidth] columns, to keep the text readable.
+When the UsageColors field of [*RootCommand] is true and the standard output is
+a terminal, we also style headings, flag names, and placeholders using ANSI
+colors. The user may disable colors by setting `NO_COLOR`, `CLICOLOR=0`, or
+`TERM=dumb` and force them by setting `CLICOLOR_FORCE=1`. When the standard
+output is not a terminal, we wrap at 72 columns (see [nflag.DefaultUsageWidth])
+without colors.
+
+# Command Tree
+
+A [*DispatcherCommand] implements the optional [CommandWithSubcommands]
+interface, which exposes its subcommands. Use [Walk] to visit the whole
+command tree, obtaining the path of each command, and [Find] to get the
+command reached through a given path (e.g., `git clone`). Shell completion
+and the documentation generators use these functions.
+
+# Shell Completion
+
+When you set the Completion field of a [*DispatcherCommand], it
+synthesizes a `completion` subcommand, which prints the completion
+script for the shell named as its argument (bash, fish, or zsh).
+
+The script is generated by [WriteCompletion], which walks the
+command tree and statically knows the subcommands of each
+[*DispatcherCommand]. You can also invoke [WriteCompletion] directly,
+e.g., to generate scripts when packaging your program.
+
+The generated scripts complete the flags of leaf commands by invoking
+the hidden `__complete` subcommand (see [*CompleteCommand]), which
+inspects the flags defined by commands using [NewFlagSet] and implementing
+[InspectableCommand] (e.g., a [*LeafCommand] with UsesNewFlagSet), along
+with the persistent flags, including the `--output-format` flag enabled by
+the OutputFormatFlag field of [*RootCommand]. To complete flag values and
+positional arguments, use [*nflag.FlagSet.SetFlagCompletion] and the
+PositionalArgumentsCompletion field of [*nflag.FlagSet].
+
 # LeafCommand and FlagSet
 
 To parse command line flags in a [LeafCommand], you should
@@ -63,6 +184,26 @@ use the [pkg/nflag] package, which provides a functionality
 similar to the standard library `flag` package, but with
 the possibility of customizing the options prefixes.
 
+Use [NewFlagSet] to create a [*nflag.FlagSet] wired to the
+[ExecEnv] and set the UsesNewFlagSet field of [LeafCommand], such
+that shell completion and documentation generators can inspect
+its flags. We never run commands that do not opt in this way.
+
+# Configuration Files
+
+Use [LoadConfig] before parsing the command line to read flag defaults
+from `~/.config/<prog>/config.toml` (see [ConfigFilePath]), where each
+section configures a command (e.g., `[git.clone]`), using its canonical
+name also when invoked through an alias, and each key is the long name
+of a flag, ignoring the file when it does not exist. Use [LoadConfigFile]
+to read another file, possibly in the JSON or INI format, which must
+exist. Environment variables and the command line override
+the configuration file. We read files using the [FS] of the [ExecEnv]
+(see [Extend]). We warn about sections not matching any command, and
+we report unknown keys and invalid values, with their line number, when
+parsing the command line, after handling the help flags.
+See [pkg/config] for more details.
+
 # Testability
 
 All top-level types depend on an abstract T type, bounded by the
@@ -70,5 +211,22 @@ All top-level types depend on an abstract T type, bounded by the
 library, but highly customizable is [*StdlibExecEnv]. By using
 such an interface, it is possible to write highly testable code
 where most of the environment dependencies can be mocked.
+
+The optional [ExtendedExecEnv] interface also covers the working directory,
+the file system, the clock, the whole environment, and the host name. Use
+[Extend] to access it from any [ExecEnv], falling back to the standard
+library when the [ExecEnv] does not implement it. We discover the external
+commands using its file system. Likewise, the optional [TerminalExecEnv]
+interface describes the terminal attached to the standard output.
+
+The [github.com/bassosimone/clip/cliptest] package provides an in-memory
+[ExecEnv] capturing the output and the exit status, along with helpers
+to run a [*RootCommand] inside tests. The cmd/minirbmk example shows how
+to write a command tree generic over the [ExecEnv] to this end.
+
+The [github.com/bassosimone/clip/cliptest/testscript] package runs end-to-end
+tests written as scripts inside txtar archives, which run a [*RootCommand]
+in process inside a sandbox directory and check its output and exit status.
+The cmd/minirbmk/testdata directory contains examples of such scripts.
 */
 package clip
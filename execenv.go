@@ -5,7 +5,9 @@ package clip
 
 import (
 	"io"
+	"io/fs"
 	"os"
+	"time"
 )
 
 // ExecEnv is the execution environment used by [Command].
@@ -31,3 +33,96 @@ type ExecEnv interface {
 	// Stderr is the standard error of the command.
 	Stderr() io.Writer
 }
+
+// ExtendedExecEnv is an optional extension of [ExecEnv] covering the working
+// directory, the file system, the clock, the whole environment, and the host
+// name, which allows testing commands depending on them without the host.
+//
+// Use [Extend] to obtain an [ExtendedExecEnv] from any [ExecEnv].
+type ExtendedExecEnv interface {
+	ExecEnv
+
+	// Environ returns the environment variables using the `KEY=value` form.
+	Environ() []string
+
+	// FS returns the file system.
+	FS() FS
+
+	// Getwd returns the current working directory.
+	Getwd() (string, error)
+
+	// Hostname returns the host name.
+	Hostname() (string, error)
+
+	// Now returns the current time.
+	Now() time.Time
+}
+
+// FS is the writable file system of an [ExtendedExecEnv].
+//
+// The methods resemble the ones of [fs.FS] and its extensions, except that the
+// names use the operating system syntax and are either absolute or relative to
+// the working directory. The [OSFS] type implements this interface using the
+// [os] package.
+type FS interface {
+	// MkdirAll creates the named directory along with any missing parents.
+	MkdirAll(name string, perm fs.FileMode) error
+
+	// Open opens the named file for reading.
+	Open(name string) (fs.File, error)
+
+	// ReadDir reads the named directory and returns its entries sorted by name.
+	ReadDir(name string) ([]fs.DirEntry, error)
+
+	// ReadFile reads the named file and returns its contents.
+	ReadFile(name string) ([]byte, error)
+
+	// Remove removes the named file or empty directory.
+	Remove(name string) error
+
+	// Stat returns the [fs.FileInfo] describing the named file.
+	Stat(name string) (fs.FileInfo, error)
+
+	// WriteFile writes data to the named file, creating it if necessary.
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+}
+
+// Extend returns the given [ExecEnv] as an [ExtendedExecEnv]. When env does not
+// implement [ExtendedExecEnv], we return an [ExtendedExecEnv] wrapping env that
+// implements the additional methods using the standard library.
+func Extend(env ExecEnv) ExtendedExecEnv {
+	if xenv, ok := env.(ExtendedExecEnv); ok {
+		return xenv
+	}
+	return stdlibExtension{env}
+}
+
+// stdlibExtension extends an [ExecEnv] using the standard library.
+type stdlibExtension struct {
+	ExecEnv
+}
+
+// Environ implements [ExtendedExecEnv].
+func (stdlibExtension) Environ() []string {
+	return os.Environ()
+}
+
+// FS implements [ExtendedExecEnv].
+func (stdlibExtension) FS() FS {
+	return OSFS{}
+}
+
+// Getwd implements [ExtendedExecEnv].
+func (stdlibExtension) Getwd() (string, error) {
+	return os.Getwd()
+}
+
+// Hostname implements [ExtendedExecEnv].
+func (stdlibExtension) Hostname() (string, error) {
+	return os.Hostname()
+}
+
+// Now implements [ExtendedExecEnv].
+func (stdlibExtension) Now() time.Time {
+	return time.Now()
+}
@@ -0,0 +1,55 @@
+// execenv_test.go - execution environment tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// minimalExecEnv is an [ExecEnv] that does not implement [ExtendedExecEnv].
+type minimalExecEnv struct {
+	ExecEnv
+}
+
+func TestExtend(t *testing.T) {
+	t.Run("we return an ExtendedExecEnv as is", func(t *testing.T) {
+		env := NewStdlibExecEnv()
+		if xenv := Extend(env); xenv != env {
+			t.Fatal("expected the same env")
+		}
+	})
+
+	t.Run("we use the standard library otherwise", func(t *testing.T) {
+		env := minimalExecEnv{NewStdlibExecEnv()}
+		if _, ok := any(env).(ExtendedExecEnv); ok {
+			t.Fatal("expected an env not implementing ExtendedExecEnv")
+		}
+		xenv := Extend(env)
+
+		if diff := cmp.Diff(os.Environ(), xenv.Environ()); diff != "" {
+			t.Error(diff)
+		}
+		if _, ok := xenv.FS().(OSFS); !ok {
+			t.Errorf("FS() = %T, want %T", xenv.FS(), OSFS{})
+		}
+		expectWd, _ := os.Getwd()
+		if got, _ := xenv.Getwd(); got != expectWd {
+			t.Errorf("Getwd() = %q, want %q", got, expectWd)
+		}
+		expectHostname, _ := os.Hostname()
+		if got, _ := xenv.Hostname(); got != expectHostname {
+			t.Errorf("Hostname() = %q, want %q", got, expectHostname)
+		}
+		if got := xenv.Now(); time.Since(got) > time.Minute {
+			t.Errorf("Now() = %v, too far in the past", got)
+		}
+		if diff := cmp.Diff(os.Args, xenv.Args()); diff != "" {
+			t.Error(diff)
+		}
+	})
+}
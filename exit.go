@@ -0,0 +1,202 @@
+// exit.go - exit status handling.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bassosimone/clip/pkg/config"
+	"github.com/bassosimone/clip/pkg/nflag"
+	"github.com/bassosimone/clip/pkg/nparser"
+)
+
+// These constants define conventional exit statuses.
+const (
+	// ExitSuccess indicates success.
+	ExitSuccess = 0
+
+	// ExitFailure indicates a generic failure.
+	ExitFailure = 1
+
+	// ExitUsageError indicates a command line usage error, such as an
+	// unknown subcommand or flag. We use this status for the errors
+	// classified as usage errors by [ExitCode].
+	ExitUsageError = 2
+
+	// ExitSignalBase is the base of the exit statuses indicating that a
+	// signal terminated the program. Following the shell convention, the
+	// status is the sum of this constant and the signal number (e.g., 130
+	// for SIGINT and 143 for SIGTERM).
+	ExitSignalBase = 128
+)
+
+// These constants define the exit statuses of the BSD sysexits.h
+// header, which you may optionally use with [*ExitError].
+const (
+	// ExitUsage indicates that the command was used incorrectly (EX_USAGE).
+	ExitUsage = 64
+
+	// ExitDataErr indicates that the input data was incorrect (EX_DATAERR).
+	ExitDataErr = 65
+
+	// ExitNoInput indicates that an input file was not readable (EX_NOINPUT).
+	ExitNoInput = 66
+
+	// ExitNoUser indicates that the user does not exist (EX_NOUSER).
+	ExitNoUser = 67
+
+	// ExitNoHost indicates that the host does not exist (EX_NOHOST).
+	ExitNoHost = 68
+
+	// ExitUnavailable indicates that a service is unavailable (EX_UNAVAILABLE).
+	ExitUnavailable = 69
+
+	// ExitSoftware indicates an internal software error (EX_SOFTWARE).
+	ExitSoftware = 70
+
+	// ExitOSErr indicates an operating system error (EX_OSERR).
+	ExitOSErr = 71
+
+	// ExitOSFile indicates that a system file is missing or broken (EX_OSFILE).
+	ExitOSFile = 72
+
+	// ExitCantCreat indicates that an output file cannot be created (EX_CANTCREAT).
+	ExitCantCreat = 73
+
+	// ExitIOErr indicates an I/O error (EX_IOERR).
+	ExitIOErr = 74
+
+	// ExitTempFail indicates a temporary failure; the user may retry (EX_TEMPFAIL).
+	ExitTempFail = 75
+
+	// ExitProtocol indicates a remote protocol error (EX_PROTOCOL).
+	ExitProtocol = 76
+
+	// ExitNoPerm indicates insufficient permissions (EX_NOPERM).
+	ExitNoPerm = 77
+
+	// ExitConfig indicates a configuration error (EX_CONFIG).
+	ExitConfig = 78
+)
+
+// ExitCoder is an error carrying the exit status of the program.
+//
+// The [*ExitError], [*ExternalCommandError], and [*SignalError] types
+// implement this interface.
+type ExitCoder interface {
+	error
+	ExitCode() int
+}
+
+// ExitError is an [ExitCoder] wrapping an error. Return it from a command
+// to control the exit status of the program (see [*RootCommand]).
+type ExitError struct {
+	// Code is the exit status.
+	Code int
+
+	// Err is the optional underlying error. When nil, [*RootCommand]
+	// exits with Code without printing any error message.
+	Err error
+}
+
+var _ ExitCoder = &ExitError{}
+
+// Error implements error.
+func (err *ExitError) Error() string {
+	if err.Err == nil {
+		return fmt.Sprintf("exit status %d", err.Code)
+	}
+	return err.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (err *ExitError) Unwrap() error {
+	return err.Err
+}
+
+// ExitCode implements [ExitCoder].
+func (err *ExitError) ExitCode() int {
+	return err.Code
+}
+
+// ExitCode returns the exit status corresponding to the given error:
+//
+//   - [ExitSuccess] when the error is nil or wraps [nflag.ErrHelp];
+//
+//   - the value returned by the first [ExitCoder] in the error chain;
+//
+//   - [ExitUsageError] for usage errors, i.e., [ErrNoSuchCommand],
+//     [ErrAmbiguousCommand], [ErrInvalidFlags], the errors returned by
+//     [*nflag.FlagSet.Parse], and configuration files errors;
+//
+//   - [ExitFailure] otherwise.
+func ExitCode(err error) int {
+	var coder ExitCoder
+	switch {
+	case err == nil || errors.Is(err, nflag.ErrHelp):
+		return ExitSuccess
+	case errors.As(err, &coder):
+		return coder.ExitCode()
+	case isUsageError(err):
+		return ExitUsageError
+	default:
+		return ExitFailure
+	}
+}
+
+// isUsageError returns whether the given error is a usage error.
+func isUsageError(err error) bool {
+	return errors.Is(err, ErrNoSuchCommand) ||
+		errors.Is(err, ErrAmbiguousCommand) ||
+		errors.Is(err, ErrInvalidFlags) ||
+		errorsAs[*config.Error](err) ||
+		errorsAs[nflag.ErrInvalidEnvVar](err) ||
+		errorsAs[nflag.ErrInvalidFlagValue](err) ||
+		errorsAs[nflag.ErrInvalidOutputFormat](err) ||
+		errorsAs[nparser.ErrOptionRequiresArgument](err) ||
+		errorsAs[nparser.ErrOptionRequiresNoArgument](err) ||
+		errorsAs[nparser.ErrTooFewPositionalArguments](err) ||
+		errorsAs[nparser.ErrTooManyPositionalArguments](err) ||
+		errorsAs[nparser.ErrUnknownOption](err)
+}
+
+// errorsAs returns whether the error chain contains an error of type E.
+func errorsAs[E error](err error) bool {
+	var target E
+	return errors.As(err, &target)
+}
+
+// reportedError wraps an error that the [*DispatcherCommand]
+// has already printed, such that we do not print it again.
+type reportedError struct {
+	error
+}
+
+// Unwrap returns the underlying error.
+func (err reportedError) Unwrap() error {
+	return err.error
+}
+
+// isReportedError returns whether we should not print the given error, because
+// it does not indicate a failure or because we have already printed it.
+func isReportedError(err error) bool {
+	var (
+		exit     *ExitError
+		external *ExternalCommandError
+	)
+	switch {
+	case err == nil || errors.Is(err, nflag.ErrHelp):
+		return true // nothing to report
+	case errors.As(err, &exit) && exit.Err == nil:
+		return true // the command asked us to be silent
+	case errors.As(err, &external):
+		return true // the external command printed its own errors
+	case errorsAs[reportedError](err):
+		return true // the dispatcher printed the error
+	default:
+		// the dispatcher prints these errors before returning them
+		return errors.Is(err, ErrNoSuchCommand) || errors.Is(err, ErrAmbiguousCommand) || errors.Is(err, ErrInvalidFlags)
+	}
+}
@@ -0,0 +1,77 @@
+// exit_test.go - exit status handling tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+
+	"github.com/bassosimone/clip/pkg/config"
+	"github.com/bassosimone/clip/pkg/nflag"
+	"github.com/bassosimone/clip/pkg/nparser"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExitError(t *testing.T) {
+	t.Run("with an underlying error", func(t *testing.T) {
+		cause := errors.New("database is locked")
+		err := &ExitError{Code: ExitTempFail, Err: cause}
+		if diff := cmp.Diff("database is locked", err.Error()); diff != "" {
+			t.Fatal(diff)
+		}
+		if !errors.Is(err, cause) {
+			t.Fatal("expected the error to wrap the cause")
+		}
+		if err.ExitCode() != 75 {
+			t.Fatal("unexpected exit code", err.ExitCode())
+		}
+	})
+
+	t.Run("without an underlying error", func(t *testing.T) {
+		err := &ExitError{Code: 3}
+		if diff := cmp.Diff("exit status 3", err.Error()); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+}
+
+func TestExitCode(t *testing.T) {
+	type testcase struct {
+		name   string
+		err    error
+		expect int
+	}
+
+	cases := []testcase{
+		{name: "nil", err: nil, expect: 0},
+		{name: "help", err: fmt.Errorf("wrapped: %w", nflag.ErrHelp), expect: 0},
+		{name: "generic error", err: errors.New("mocked error"), expect: 1},
+		{name: "ExitError", err: fmt.Errorf("wrapped: %w", &ExitError{Code: ExitConfig}), expect: 78},
+		{name: "ExternalCommandError", err: &ExternalCommandError{Path: "/bin/false", Status: 4}, expect: 4},
+		{name: "SignalError", err: fmt.Errorf("wrapped: %w", &SignalError{Signal: syscall.SIGINT}), expect: 130},
+		{name: "no such command", err: &NoSuchCommandError{Name: "x"}, expect: 2},
+		{name: "ambiguous command", err: &AmbiguousCommandError{Name: "x"}, expect: 2},
+		{name: "invalid flags", err: ErrInvalidFlags, expect: 2},
+		{name: "unknown option", err: nparser.ErrUnknownOption{Name: "x"}, expect: 2},
+		{name: "missing argument", err: nparser.ErrOptionRequiresArgument{}, expect: 2},
+		{name: "unexpected argument", err: nparser.ErrOptionRequiresNoArgument{}, expect: 2},
+		{name: "too few arguments", err: nparser.ErrTooFewPositionalArguments{}, expect: 2},
+		{name: "too many arguments", err: nparser.ErrTooManyPositionalArguments{}, expect: 2},
+		{name: "invalid environment variable", err: nflag.ErrInvalidEnvVar{Err: errors.New("x")}, expect: 2},
+		{name: "invalid flag value", err: nflag.ErrInvalidFlagValue{Err: errors.New("x")}, expect: 2},
+		{name: "invalid output format", err: nflag.ErrInvalidOutputFormat{Name: "xml"}, expect: 2},
+		{name: "configuration file", err: &config.Error{Err: config.ErrUnknownKey}, expect: 2},
+		{name: "invalid flags configuration", err: nparser.ErrAmbiguousPrefix{Prefix: "-"}, expect: 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ExitCode(tc.err); got != tc.expect {
+				t.Fatal("expected", tc.expect, "got", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+// external.go - external commands (aka plugins).
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// ExternalCommand is a [Command] running an external executable.
+//
+// The [*DispatcherCommand] creates instances of this type when
+// discovering external commands (see its ExternalCommands field).
+//
+// The zero value is not ready to use. Initialize the mandatory fields.
+type ExternalCommand[T ExecEnv] struct {
+	// --- mandatory fields ---
+
+	// Path is the mandatory path of the executable.
+	Path string
+
+	// --- optional fields ---
+
+	// BriefDescriptionText is the optional brief description text.
+	//
+	// When unset, we use a description mentioning the Path.
+	BriefDescriptionText string
+}
+
+var _ Command[*StdlibExecEnv] = &ExternalCommand[*StdlibExecEnv]{}
+
+// BriefDescription implements [Command].
+func (c *ExternalCommand[T]) BriefDescription() string {
+	output := fmt.Sprintf("Run the external command at %s.", c.Path)
+	if c.BriefDescriptionText != "" {
+		output = c.BriefDescriptionText
+	}
+	return output
+}
+
+// HelpFlag implements [Command].
+func (c *ExternalCommand[T]) HelpFlag() string {
+	return "--help"
+}
+
+// Run implements [Command].
+//
+// The executable inherits the standard input, output and error, the environment
+// variables, and the working directory of the [ExecEnv] (see [Extend]), and
+// receives the args. When the executable exits with a nonzero status, we return
+// an [*ExternalCommandError] containing the status.
+//
+// The args do not contain the persistent flags, which the enclosing
+// [*DispatcherCommand] instances have already parsed and removed. Instead,
+// we pass the value of each persistent flag bound to an environment variable
+// (see [*nflag.FlagSet.EnvVarName]) using such a variable. The executable
+// does not see the other persistent flags.
+func (c *ExternalCommand[T]) Run(ctx context.Context, args *CommandArgs[T]) error {
+	xenv := Extend(args.Env)
+	cwd, err := xenv.Getwd()
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, c.Path, args.Args...)
+	cmd.Dir = cwd
+	cmd.Env = append(xenv.Environ(), persistentFlagsEnviron(args.PersistentFlags)...)
+	cmd.Stdin = args.Env.Stdin()
+	cmd.Stdout = args.Env.Stdout()
+	cmd.Stderr = args.Env.Stderr()
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		return &ExternalCommandError{Path: c.Path, Status: exitErr.ExitCode()}
+	}
+	return err
+}
+
+// SupportsSubcommands implements [Command].
+func (c *ExternalCommand[T]) SupportsSubcommands() bool {
+	return false
+}
+
+// persistentFlagsEnviron returns the `KEY=value` environment variables
+// corresponding to the persistent flags bound to environment variables.
+func persistentFlagsEnviron(fsets []*nflag.FlagSet) []string {
+	var environ []string
+	for _, fset := range fsets {
+		for _, pair := range fset.Flags() {
+			if name := fset.EnvVarName(pair); name != "" {
+				environ = append(environ, name+"="+pair.Value.String())
+			}
+		}
+	}
+	return environ
+}
+
+// ExternalCommandError is the error returned by [*ExternalCommand]
+// when the executable exits with a nonzero status.
+type ExternalCommandError struct {
+	// Path is the path of the executable.
+	Path string
+
+	// Status is the exit status of the executable.
+	Status int
+}
+
+var _ ExitCoder = &ExternalCommandError{}
+
+// Error implements error.
+func (err *ExternalCommandError) Error() string {
+	return fmt.Sprintf("external command %s exited with status %d", err.Path, err.Status)
+}
+
+// ExitCode returns the exit status of the executable.
+func (err *ExternalCommandError) ExitCode() int {
+	return err.Status
+}
+
+// externalCommandPrefix returns the prefix of the executables implementing external
+// commands given the canonical dispatcher command name (e.g., `minirbmk-` for
+// `/usr/bin/minirbmk` and `minirbmk-git-` for `/usr/bin/minirbmk git`). We only
+// use the base name of the program, which is the first word of the command name.
+func externalCommandPrefix(commandName string) string {
+	program, subcommands, _ := strings.Cut(commandName, " ")
+	words := append([]string{filepath.Base(program)}, strings.Fields(subcommands)...)
+	return strings.Join(words, "-") + "-"
+}
+
+// lookupExternalCommand returns the [*ExternalCommand] implementing the given subcommand
+// using the first matching executable inside the PATH, or nil if there is no such executable.
+func lookupExternalCommand[T ExecEnv](env T, commandName, subName string) Command[T] {
+	if subName == "" || strings.ContainsAny(subName, `/\`) {
+		return nil
+	}
+	fsys := Extend(env).FS()
+	exts := executableExtensions(env)
+	for _, dir := range searchPath(env) {
+		for _, ext := range exts {
+			path := filepath.Join(dir, externalCommandPrefix(commandName)+subName+ext)
+			if isExecutable(fsys, path) {
+				return &ExternalCommand[T]{Path: path}
+			}
+		}
+	}
+	return nil
+}
+
+// listExternalCommands returns all the external commands inside the PATH indexed by name. When
+// several directories contain the same command, we use the first one, like the shell does.
+func listExternalCommands[T ExecEnv](env T, commandName string) map[string]Command[T] {
+	output := make(map[string]Command[T])
+	prefix := externalCommandPrefix(commandName)
+	fsys := Extend(env).FS()
+	exts := executableExtensions(env)
+	for _, dir := range searchPath(env) {
+		entries, err := fsys.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, found := strings.CutPrefix(entry.Name(), prefix)
+			if found {
+				name, found = cutExecutableExtension(name, exts)
+			}
+			if !found || name == "" || output[name] != nil {
+				continue
+			}
+			if path := filepath.Join(dir, entry.Name()); isExecutable(fsys, path) {
+				output[name] = &ExternalCommand[T]{Path: path}
+			}
+		}
+	}
+	return output
+}
+
+// searchPath returns the directories listed by the PATH environment variable.
+func searchPath[T ExecEnv](env T) []string {
+	value, _ := env.LookupEnv("PATH")
+	var dirs []string
+	for _, dir := range filepath.SplitList(value) {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// executablesGOOS is the operating system whose rules we follow
+// to recognize executables.
+var executablesGOOS = runtime.GOOS // for testing
+
+// executableExtensions returns the extensions that the name of an executable
+// may have, like [exec.LookPath] does. On Windows, we use the extensions in
+// PATHEXT, defaulting to `.com`, `.exe`, `.bat` and `.cmd`. Elsewhere, we only
+// return the empty extension, since executables have no extension.
+func executableExtensions[T ExecEnv](env T) []string {
+	if executablesGOOS != "windows" {
+		return []string{""}
+	}
+	value, _ := env.LookupEnv("PATHEXT")
+	var exts []string
+	for _, ext := range strings.Split(strings.ToLower(value), ";") {
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		exts = append(exts, ext)
+	}
+	if len(exts) <= 0 {
+		exts = []string{".com", ".exe", ".bat", ".cmd"}
+	}
+	return exts
+}
+
+// cutExecutableExtension returns the name without the first of the given
+// extensions it ends with, ignoring case, and whether there is such extension.
+func cutExecutableExtension(name string, exts []string) (string, bool) {
+	for _, ext := range exts {
+		if len(name) >= len(ext) && strings.EqualFold(name[len(name)-len(ext):], ext) {
+			return name[:len(name)-len(ext)], true
+		}
+	}
+	return "", false
+}
+
+// isExecutable returns whether the given path is an executable regular file. On
+// Windows, where the extension determines whether a file is executable (see
+// [executableExtensions]), we do not check the permissions.
+func isExecutable(fsys FS, path string) bool {
+	finfo, err := fsys.Stat(path)
+	if err != nil || !finfo.Mode().IsRegular() {
+		return false
+	}
+	return executablesGOOS == "windows" || finfo.Mode().Perm()&0111 != 0
+}
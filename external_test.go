@@ -0,0 +1,422 @@
+// external_test.go - external commands tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/bassosimone/clip/pkg/nflag"
+	"github.com/google/go-cmp/cmp"
+)
+
+// newExternalTestEnv creates a temporary directory containing external
+// commands and returns an [*StdlibExecEnv] using it as the PATH.
+func newExternalTestEnv(t *testing.T) (*StdlibExecEnv, *bytes.Buffer, *bytes.Buffer) {
+	if runtime.GOOS == "windows" {
+		t.Skip("external commands tests require a POSIX shell")
+	}
+	dir := t.TempDir()
+	files := []struct {
+		name    string
+		content string
+		mode    os.FileMode
+	}{
+		{"test-hello", "#!/bin/sh\necho \"hello $*\"\n", 0755},
+		{"test-fail", "#!/bin/sh\necho failure >&2\nexit 3\n", 0755},
+		{"test-curl", "#!/bin/sh\necho shadowed\n", 0755},
+		{"test-data", "not executable\n", 0644},
+		{"other-hello", "#!/bin/sh\necho other\n", 0755},
+	}
+	for _, file := range files {
+		if err := os.WriteFile(filepath.Join(dir, file.name), []byte(file.content), file.mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	env := NewStdlibExecEnv()
+	env.OSLookupEnv = func(key string) (string, bool) {
+		if key == "PATH" {
+			return dir, true
+		}
+		return "", false
+	}
+	env.OSStdout = &stdout
+	env.OSStderr = &stderr
+	return env, &stdout, &stderr
+}
+
+func TestDispatchExternalCommands(t *testing.T) {
+	type testcase struct {
+		name         string
+		args         []string
+		disabled     bool
+		expectErr    error
+		expectStatus int
+		expectStdout string
+		expectStderr string
+	}
+
+	cases := []testcase{
+		{
+			name:         "we run the external command with the remaining args",
+			args:         []string{"hello", "-v", "world"},
+			expectStdout: "hello -v world\n",
+		},
+
+		{
+			name:         "help forwards to the external command",
+			args:         []string{"help", "hello"},
+			expectStdout: "hello --help\n",
+		},
+
+		{
+			name:         "we return the external command status",
+			args:         []string{"fail"},
+			expectStatus: 3,
+			expectStderr: "failure\n",
+		},
+
+		{
+			name:         "Commands take precedence over external commands",
+			args:         []string{"curl"},
+			expectStdout: "",
+		},
+
+		{
+			name:         "we ignore files that are not executable",
+			args:         []string{"data"},
+			expectErr:    ErrNoSuchCommand,
+			expectStderr: "test: no such command: data\n",
+		},
+
+		{
+			name:         "we do not run external commands when disabled",
+			args:         []string{"hello"},
+			disabled:     true,
+			expectErr:    ErrNoSuchCommand,
+			expectStderr: "test: no such command: hello\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			env, stdout, stderr := newExternalTestEnv(t)
+			dx := newTestTree(nil)
+			dx.ExternalCommands = !tc.disabled
+			err := dx.Run(context.Background(), &CommandArgs[*StdlibExecEnv]{
+				Args:        tc.args,
+				Command:     dx,
+				CommandName: "/usr/local/bin/test",
+				Env:         env,
+			})
+			var external *ExternalCommandError
+			switch {
+			case tc.expectStatus != 0:
+				if !errors.As(err, &external) || external.ExitCode() != tc.expectStatus {
+					t.Fatalf("expected status %d, got %v", tc.expectStatus, err)
+				}
+			case !errors.Is(err, tc.expectErr):
+				t.Fatalf("expected %v, got %v", tc.expectErr, err)
+			}
+			if diff := cmp.Diff(tc.expectStdout, stdout.String()); diff != "" {
+				t.Errorf("stdout mismatch (-want +got):\n%s", diff)
+			}
+			if !strings.Contains(stderr.String(), strings.ReplaceAll(tc.expectStderr, "test:", "/usr/local/bin/test:")) {
+				t.Errorf("expected %q inside %q", tc.expectStderr, stderr.String())
+			}
+		})
+	}
+
+	t.Run("we exit with the external command status with ExitOnError", func(t *testing.T) {
+		env, _, _ := newExternalTestEnv(t)
+		exitcode := -1
+		env.OSExit = func(code int) {
+			exitcode = code
+		}
+		dx := newTestTree(nil)
+		dx.ExternalCommands = true
+		dx.ErrorHandling = nflag.ExitOnError
+		func() {
+			defer func() { recover() }() // the mocked exit returns, so we panic
+			dx.Run(context.Background(), &CommandArgs[*StdlibExecEnv]{
+				Args:        []string{"fail"},
+				Command:     dx,
+				CommandName: "test",
+				Env:         env,
+			})
+		}()
+		if exitcode != 3 {
+			t.Fatalf("expected 3, got %d", exitcode)
+		}
+	})
+
+	t.Run("we use the environment, the working directory, and the persistent flags", func(t *testing.T) {
+		env, stdout, _ := newExternalTestEnv(t)
+		bindir, _ := env.LookupEnv("PATH")
+		script := "#!/bin/sh\necho \"$(pwd) $TEST_NAME $TEST_CONFIG $*\"\n"
+		if err := os.WriteFile(filepath.Join(bindir, "test-where"), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+		workdir := t.TempDir()
+		env.OSEnviron = func() []string {
+			return []string{"TEST_NAME=value"}
+		}
+		env.OSGetwd = func() (string, error) {
+			return workdir, nil
+		}
+		dx := newTestTree(nil)
+		dx.ExternalCommands = true
+		dx.PersistentFlags = nflag.NewFlagSet("test", nflag.ContinueOnError)
+		dx.PersistentFlags.EnvVarPrefix = "TEST"
+		dx.PersistentFlags.StringFlag("config", 0, "Configuration file.")
+		err := dx.Run(context.Background(), &CommandArgs[*StdlibExecEnv]{
+			Args:        []string{"--config", "x.toml", "where", "arg"},
+			Command:     dx,
+			CommandName: "test",
+			Env:         env,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := workdir + " value x.toml arg\n"
+		if diff := cmp.Diff(expect, stdout.String()); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("the usage lists the external commands", func(t *testing.T) {
+		env, stdout, _ := newExternalTestEnv(t)
+		dx := newTestTree(nil)
+		dx.ExternalCommands = true
+		err := dx.Run(context.Background(), &CommandArgs[*StdlibExecEnv]{
+			Args:        []string{"help"},
+			Command:     dx,
+			CommandName: "test",
+			Env:         env,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, expect := range []string{
+			"Print the program version and exit.\n\nExternal commands:\n  fail\n",
+			"test-fail.\n\n  hello\n",
+			"test-hello.\n\nTry",
+		} {
+			if !strings.Contains(stdout.String(), expect) {
+				t.Errorf("expected %q inside %q", expect, stdout.String())
+			}
+		}
+	})
+
+	t.Run("nested dispatchers use the full command name", func(t *testing.T) {
+		env, _, _ := newExternalTestEnv(t)
+		if cmd := lookupExternalCommand(env, "other", "hello"); cmd == nil {
+			t.Fatal("expected to find other-hello")
+		}
+		if cmd := lookupExternalCommand(env, "test git", "hello"); cmd != nil {
+			t.Fatal("expected not to find test-git-hello")
+		}
+		for _, tc := range []struct {
+			commandName string
+			expect      string
+		}{
+			{commandName: "/usr/bin/minirbmk", expect: "minirbmk-"},
+			{commandName: "/usr/bin/minirbmk git", expect: "minirbmk-git-"},
+			{commandName: "minirbmk git remote", expect: "minirbmk-git-remote-"},
+			{commandName: "/usr/bin/minirbmk git/remote", expect: "minirbmk-git/remote-"},
+		} {
+			if got := externalCommandPrefix(tc.commandName); got != tc.expect {
+				t.Errorf("%q: expected %q, got %q", tc.commandName, tc.expect, got)
+			}
+		}
+	})
+
+	t.Run("nested dispatchers reached through aliases use the canonical name", func(t *testing.T) {
+		env, stdout, _ := newExternalTestEnv(t)
+		bindir, _ := env.LookupEnv("PATH")
+		script := "#!/bin/sh\necho \"nested $*\"\n"
+		if err := os.WriteFile(filepath.Join(bindir, "test-git-hello"), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+		dx := newTestTree(nil)
+		dx.Commands["git"].(*DispatcherCommand[*StdlibExecEnv]).ExternalCommands = true
+		err := dx.Run(context.Background(), &CommandArgs[*StdlibExecEnv]{
+			Args:        []string{"g", "hello", "world"},
+			Command:     dx,
+			CommandName: "/usr/local/bin/test",
+			Env:         env,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff("nested world\n", stdout.String()); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+}
+
+// mapFS is an [FS] backed by an [fstest.MapFS] where the names are
+// the absolute names without the leading slash. We do not support writing.
+type mapFS fstest.MapFS
+
+// name converts the given absolute name to the [fstest.MapFS] name.
+func (m mapFS) name(name string) string {
+	return strings.TrimPrefix(filepath.ToSlash(name), "/")
+}
+
+// MkdirAll implements [FS].
+func (m mapFS) MkdirAll(name string, perm fs.FileMode) error {
+	return errors.ErrUnsupported
+}
+
+// Open implements [FS].
+func (m mapFS) Open(name string) (fs.File, error) {
+	return fstest.MapFS(m).Open(m.name(name))
+}
+
+// ReadDir implements [FS].
+func (m mapFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fstest.MapFS(m).ReadDir(m.name(name))
+}
+
+// ReadFile implements [FS].
+func (m mapFS) ReadFile(name string) ([]byte, error) {
+	return fstest.MapFS(m).ReadFile(m.name(name))
+}
+
+// Remove implements [FS].
+func (m mapFS) Remove(name string) error {
+	return errors.ErrUnsupported
+}
+
+// Stat implements [FS].
+func (m mapFS) Stat(name string) (fs.FileInfo, error) {
+	return fstest.MapFS(m).Stat(m.name(name))
+}
+
+// WriteFile implements [FS].
+func (m mapFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return errors.ErrUnsupported
+}
+
+// setExecutablesGOOS sets executablesGOOS for the duration of the test.
+func setExecutablesGOOS(t *testing.T, goos string) {
+	saved := executablesGOOS
+	executablesGOOS = goos
+	t.Cleanup(func() { executablesGOOS = saved })
+}
+
+func TestDiscoverExternalCommandsUsingFS(t *testing.T) {
+	setExecutablesGOOS(t, "linux")
+	env := NewStdlibExecEnv()
+	env.OSLookupEnv = func(key string) (string, bool) {
+		return "/opt/bin" + string(filepath.ListSeparator) + "/usr/bin", key == "PATH"
+	}
+	env.OSFileSystem = mapFS{
+		"opt/bin/test-hello": {Mode: 0755},
+		"opt/bin/test-data":  {Mode: 0644},
+		"usr/bin/test-hello": {Mode: 0755},
+		"usr/bin/test-world": {Mode: 0755},
+		"usr/bin/test-dir":   {Mode: fs.ModeDir | 0755},
+	}
+
+	cmd := lookupExternalCommand(env, "test", "hello")
+	if diff := cmp.Diff(&ExternalCommand[*StdlibExecEnv]{Path: filepath.FromSlash("/opt/bin/test-hello")}, cmd); diff != "" {
+		t.Fatal(diff)
+	}
+	if cmd := lookupExternalCommand(env, "test", "data"); cmd != nil {
+		t.Fatal("expected not to find test-data")
+	}
+
+	expect := map[string]Command[*StdlibExecEnv]{
+		"hello": &ExternalCommand[*StdlibExecEnv]{Path: filepath.FromSlash("/opt/bin/test-hello")},
+		"world": &ExternalCommand[*StdlibExecEnv]{Path: filepath.FromSlash("/usr/bin/test-world")},
+	}
+	if diff := cmp.Diff(expect, listExternalCommands(env, "test")); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestDiscoverExternalCommandsOnWindows(t *testing.T) {
+	setExecutablesGOOS(t, "windows")
+	environ := map[string]string{"PATH": "/opt/bin", "PATHEXT": ".COM;.EXE;.BAT"}
+	env := NewStdlibExecEnv()
+	env.OSLookupEnv = func(key string) (string, bool) {
+		value, found := environ[key]
+		return value, found
+	}
+	env.OSFileSystem = mapFS{
+		"opt/bin/test-hello.exe": {Mode: 0644},
+		"opt/bin/test-world.BAT": {Mode: 0644},
+		"opt/bin/test-data.txt":  {Mode: 0644},
+		"opt/bin/test-script":    {Mode: 0755},
+	}
+
+	cmd := lookupExternalCommand(env, "test", "hello")
+	if diff := cmp.Diff(&ExternalCommand[*StdlibExecEnv]{Path: filepath.FromSlash("/opt/bin/test-hello.exe")}, cmd); diff != "" {
+		t.Fatal(diff)
+	}
+	for _, name := range []string{"data", "script", "hello.EXE"} {
+		if cmd := lookupExternalCommand(env, "test", name); cmd != nil {
+			t.Fatal("expected not to find", name)
+		}
+	}
+
+	expect := map[string]Command[*StdlibExecEnv]{
+		"hello": &ExternalCommand[*StdlibExecEnv]{Path: filepath.FromSlash("/opt/bin/test-hello.exe")},
+		"world": &ExternalCommand[*StdlibExecEnv]{Path: filepath.FromSlash("/opt/bin/test-world.BAT")},
+	}
+	if diff := cmp.Diff(expect, listExternalCommands(env, "test")); diff != "" {
+		t.Fatal(diff)
+	}
+
+	t.Run("we use the default extensions without PATHEXT", func(t *testing.T) {
+		delete(environ, "PATHEXT")
+		if diff := cmp.Diff([]string{".com", ".exe", ".bat", ".cmd"}, executableExtensions(env)); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+}
+
+func TestExternalCommand(t *testing.T) {
+	cmd := &ExternalCommand[*StdlibExecEnv]{Path: "/usr/bin/test-foo"}
+
+	t.Run("BriefDescription", func(t *testing.T) {
+		if got := cmd.BriefDescription(); got != "Run the external command at /usr/bin/test-foo." {
+			t.Errorf("unexpected brief description: %q", got)
+		}
+		cmd := &ExternalCommand[*StdlibExecEnv]{BriefDescriptionText: "Foo.", Path: "/usr/bin/test-foo"}
+		if got := cmd.BriefDescription(); got != "Foo." {
+			t.Errorf("unexpected brief description: %q", got)
+		}
+	})
+
+	t.Run("HelpFlag", func(t *testing.T) {
+		if got := cmd.HelpFlag(); got != "--help" {
+			t.Errorf("unexpected help flag: %q", got)
+		}
+	})
+
+	t.Run("SupportsSubcommands", func(t *testing.T) {
+		if cmd.SupportsSubcommands() {
+			t.Error("expected SupportsSubcommands to be false")
+		}
+	})
+
+	t.Run("ExternalCommandError", func(t *testing.T) {
+		err := &ExternalCommandError{Path: "/usr/bin/test-foo", Status: 3}
+		if diff := cmp.Diff("external command /usr/bin/test-foo exited with status 3", err.Error()); diff != "" {
+			t.Error(diff)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+// fixture_test.go - command tree shared by the tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// testTreeResult contains what the `git clone` command of
+// the tree returned by newTestTree observed when running.
+type testTreeResult struct {
+	args       []string
+	config     string
+	persistent int
+	verbose    bool
+}
+
+// newTestTree creates the command tree shared by the tests:
+//
+//	tool (alias: g -> git)
+//	├── curl
+//	├── git (alias: cl -> clone)
+//	│   ├── clone
+//	│   └── init
+//	├── legacy (not using NewFlagSet)
+//	└── secret (hidden, not using NewFlagSet)
+//
+// The root also synthesizes the `completion` and `version` commands. When
+// it successfully parses its flags, `git clone` saves what it observed into
+// result, unless result is nil. Tests needing a different tree modify the
+// returned dispatcher (e.g., to add PersistentFlags).
+func newTestTree(result *testTreeResult) *DispatcherCommand[*StdlibExecEnv] {
+	newLeaf := func(brief string) *LeafCommand[*StdlibExecEnv] {
+		return &LeafCommand[*StdlibExecEnv]{
+			BriefDescriptionText: brief,
+			RunFunc: func(ctx context.Context, args *CommandArgs[*StdlibExecEnv]) error {
+				return nil
+			},
+		}
+	}
+	clone := &LeafCommand[*StdlibExecEnv]{
+		BriefDescriptionText: "Clone a repository.",
+		RunFunc: func(ctx context.Context, args *CommandArgs[*StdlibExecEnv]) error {
+			fset := NewFlagSet(args, nflag.ContinueOnError)
+			fset.AutoHelp("help", 'h', "Print this help message and exit.")
+			fset.StringFlag("branch", 'b', "Checkout the given `BRANCH`.")
+			fset.SetFlagCompletion("branch", 'b', nflag.CompleteChoices("main", "master", "develop"))
+			fset.StringFlag("template", 0, "Use the given template `DIR`.")
+			fset.BoolFlag("quiet", 'q', "Do not print progress.")
+			fset.PositionalArgumentsCompletion = []nflag.CompletionFunc{
+				nflag.CompleteChoices("https://", "git@"),
+				nflag.CompleteDirs,
+			}
+			if err := fset.Parse(args.Args); err != nil {
+				if errors.Is(err, nflag.ErrHelp) {
+					fset.PrintUsage(args.Env.Stdout())
+				}
+				return err
+			}
+			if result != nil {
+				result.args = args.Args
+				result.persistent = len(args.PersistentFlags)
+				if flag, found := args.LookupPersistentFlag("config"); found {
+					result.config = flag.Value.String()
+				}
+				if flag, found := args.LookupPersistentFlag("verbose"); found {
+					result.verbose = flag.Value.String() == "true"
+				}
+			}
+			return nil
+		},
+		UsesNewFlagSet: true,
+	}
+	legacy := &LeafCommand[*StdlibExecEnv]{
+		BriefDescriptionText: "Command not using NewFlagSet.",
+		RunFunc: func(ctx context.Context, args *CommandArgs[*StdlibExecEnv]) error {
+			fmt.Fprintln(args.Env.Stdout(), "legacy: running")
+			fset := nflag.NewFlagSet(args.CommandName, nflag.ExitOnError)
+			fset.Exit = args.Env.Exit
+			fset.Stdout = args.Env.Stdout()
+			return fset.Parse(args.Args)
+		},
+	}
+	return &DispatcherCommand[*StdlibExecEnv]{
+		Aliases:              map[string]string{"g": "git"},
+		BriefDescriptionText: "A collection of tools.",
+		Commands: map[string]Command[*StdlibExecEnv]{
+			"curl": newLeaf("Transfer a URL."),
+			"git": &DispatcherCommand[*StdlibExecEnv]{
+				Aliases:              map[string]string{"cl": "clone"},
+				BriefDescriptionText: "Utility to manage repositories.",
+				Commands: map[string]Command[*StdlibExecEnv]{
+					"clone": clone,
+					"init":  newLeaf("Create an empty repository."),
+				},
+				OptionPrefixes: []string{"-", "--"},
+			},
+			"legacy": legacy,
+			"secret": legacy,
+		},
+		Completion:     true,
+		Hidden:         map[string]bool{"secret": true},
+		OptionPrefixes: []string{"-", "--"},
+		Version:        "0.1.0",
+	}
+}
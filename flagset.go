@@ -0,0 +1,94 @@
+// flagset.go - FlagSet integration.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import (
+	"context"
+
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// NewFlagSet creates a new [*nflag.FlagSet] for the command invoked with
+// the given args, using args.CommandName as the program name. Compared to
+// calling [nflag.NewFlagSet] directly, the returned [*nflag.FlagSet] uses
+// the standard output, standard error, exit function, and environment
+// variables lookup function of args.Env, uses args.OutputFormat, lists
+// args.PersistentFlags in the "Global options" section of the usage, and
+// formats the usage according to the [Terminal] returned by [DetectTerminal],
+// using colors only when args.UsageColors is true.
+//
+// Using this function also allows the `__complete` command (see
+// [*CompleteCommand]) to inspect the flags defined by a command implementing
+// [InspectableCommand]. To this end, the command is invoked in inspection
+// mode, where the call to [*nflag.FlagSet.Parse] does not return.
+func NewFlagSet[T ExecEnv](args *CommandArgs[T], handling nflag.ErrorHandling) *nflag.FlagSet {
+	fset := nflag.NewFlagSet(args.CommandName, handling)
+	fset.Exit = args.Env.Exit
+	fset.GlobalFlagSets = args.PersistentFlags
+	fset.LookupEnv = args.Env.LookupEnv
+	fset.OutputFormat = args.OutputFormat
+	fset.Stderr = args.Env.Stderr()
+	fset.Stdout = args.Env.Stdout()
+	term := usageTerminal(args)
+	fset.UsageColors = term.Colors
+	fset.UsageWidth = term.UsageWidth()
+	if args.inspecting {
+		fset.BeforeParse = func(fx *nflag.FlagSet) error {
+			panic(inspectedFlagSet{fx})
+		}
+	}
+	return fset
+}
+
+// InspectableCommand is an optional extension of [Command] for the commands
+// that [InspectFlagSet] may run in inspection mode, because they create their
+// flags using [NewFlagSet] and parse them before performing any work.
+type InspectableCommand[T ExecEnv] interface {
+	Command[T]
+
+	// SupportsInspection returns true if we may run the command in inspection mode.
+	SupportsInspection() bool
+}
+
+// InspectFlagSet runs args.Command in inspection mode and returns the
+// [*nflag.FlagSet] it creates using [NewFlagSet], or nil if the command
+// does not use [NewFlagSet]. To avoid running commands performing work
+// or exiting, we only run the commands implementing [InspectableCommand]
+// and supporting inspection, and we return nil for the other commands. As
+// a safety net, we run them passing only the [Command] help flag as the
+// arguments.
+//
+// We use this function to complete the command line and to generate
+// documentation. The args.Args field is ignored.
+func InspectFlagSet[T ExecEnv](ctx context.Context, args *CommandArgs[T]) (fset *nflag.FlagSet) {
+	if cmd, ok := args.Command.(InspectableCommand[T]); !ok || !cmd.SupportsInspection() {
+		return nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			inspected, ok := r.(inspectedFlagSet)
+			if !ok {
+				panic(r)
+			}
+			fset = inspected.fset
+		}
+	}()
+	nargs := &CommandArgs[T]{
+		Args:                 []string{args.Command.HelpFlag()},
+		CanonicalCommandName: args.CanonicalCommandName,
+		Command:              args.Command,
+		CommandName:          args.CommandName,
+		Env:                  args.Env,
+		Parent:               args.Parent,
+		PersistentFlags:      args.PersistentFlags,
+		inspecting:           true,
+	}
+	_ = args.Command.Run(ctx, nargs)
+	return nil
+}
+
+// inspectedFlagSet is the value passed to panic in inspection mode.
+type inspectedFlagSet struct {
+	fset *nflag.FlagSet
+}
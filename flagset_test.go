@@ -0,0 +1,113 @@
+// flagset_test.go - FlagSet integration tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+func TestNewFlagSet(t *testing.T) {
+	t.Run("the FlagSet uses the ExecEnv", func(t *testing.T) {
+		var stderr bytes.Buffer
+		env := NewStdlibExecEnv()
+		env.OSStderr = &stderr
+		var exitcode int
+		env.OSExit = func(code int) {
+			exitcode = code
+		}
+		fset := NewFlagSet(&CommandArgs[*StdlibExecEnv]{CommandName: "tool", Env: env}, nflag.ExitOnError)
+		func() {
+			// Parse panics because our exit function returns
+			defer func() {
+				recover()
+			}()
+			fset.Parse([]string{"--nonexistent"})
+		}()
+		if exitcode != 2 {
+			t.Fatalf("expected exit code 2, got %d", exitcode)
+		}
+		if !strings.Contains(stderr.String(), "tool: ") {
+			t.Fatalf("unexpected stderr: %q", stderr.String())
+		}
+	})
+
+	t.Run("the FlagSet uses the ExecEnv environment variables", func(t *testing.T) {
+		env := NewStdlibExecEnv()
+		env.OSLookupEnv = func(key string) (string, bool) {
+			return map[string]string{"TOOL_BRANCH": "main"}[key], key == "TOOL_BRANCH"
+		}
+		fset := NewFlagSet(&CommandArgs[*StdlibExecEnv]{CommandName: "tool", Env: env}, nflag.ContinueOnError)
+		fset.EnvVarPrefix = "TOOL"
+		branch := fset.StringFlag("branch", 'b', "Branch name.")
+		if err := fset.Parse([]string{}); err != nil {
+			t.Fatal(err)
+		}
+		if *branch != "main" {
+			t.Fatalf("expected %q, got %q", "main", *branch)
+		}
+	})
+
+	for _, colors := range []bool{false, true} {
+		t.Run(fmt.Sprintf("the FlagSet uses the ExecEnv terminal with UsageColors=%v", colors), func(t *testing.T) {
+			env := &terminalExecEnv{StdlibExecEnv: NewStdlibExecEnv(), isatty: true, width: 100}
+			env.OSLookupEnv = func(key string) (string, bool) {
+				return "", false
+			}
+			args := &CommandArgs[*terminalExecEnv]{CommandName: "tool", Env: env, UsageColors: colors}
+			fset := NewFlagSet(args, nflag.ContinueOnError)
+			if fset.UsageColors != colors || fset.UsageWidth != 99 {
+				t.Fatalf("unexpected usage settings: %v, %d", fset.UsageColors, fset.UsageWidth)
+			}
+		})
+	}
+
+	t.Run("we do not inspect commands not supporting inspection", func(t *testing.T) {
+		cmd := &LeafCommand[*StdlibExecEnv]{
+			RunFunc: func(ctx context.Context, args *CommandArgs[*StdlibExecEnv]) error {
+				t.Fatal("the command should not run")
+				return nil
+			},
+		}
+		fset := InspectFlagSet(context.Background(), &CommandArgs[*StdlibExecEnv]{
+			Command:     cmd,
+			CommandName: "tool",
+			Env:         NewStdlibExecEnv(),
+		})
+		if fset != nil {
+			t.Fatal("expected a nil FlagSet")
+		}
+	})
+
+	t.Run("in inspection mode Parse does not return", func(t *testing.T) {
+		cmd := &LeafCommand[*StdlibExecEnv]{
+			RunFunc: func(ctx context.Context, args *CommandArgs[*StdlibExecEnv]) error {
+				fset := NewFlagSet(args, nflag.ContinueOnError)
+				fset.BoolFlag("verbose", 'v', "Run in verbose mode.")
+				if err := fset.Parse(args.Args); err != nil {
+					return err
+				}
+				return errors.New("should not happen")
+			},
+			UsesNewFlagSet: true,
+		}
+		fset := InspectFlagSet(context.Background(), &CommandArgs[*StdlibExecEnv]{
+			Command:     cmd,
+			CommandName: "tool",
+			Env:         NewStdlibExecEnv(),
+		})
+		if fset == nil {
+			t.Fatal("expected a non-nil FlagSet")
+		}
+		if _, found := fset.LookupFlagLong("verbose"); !found {
+			t.Fatal("expected the verbose flag")
+		}
+	})
+}
@@ -0,0 +1,197 @@
+// doctree.go - command tree for documentation generators.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+// Package doctree contains the command tree view shared by the
+// documentation generators (e.g., man pages and Markdown).
+package doctree
+
+import (
+	"context"
+	"strings"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// Node is a command inside the command tree.
+type Node[T clip.ExecEnv] struct {
+	// Children contains the subcommands sorted by name.
+	Children []*Node[T]
+
+	// Command is the command.
+	Command clip.Command[T]
+
+	// FlagSet is the inspected [*nflag.FlagSet] or nil.
+	FlagSet *nflag.FlagSet
+
+	// Parent is the parent node or nil.
+	Parent *Node[T]
+
+	// Path contains the program name followed by the subcommand names.
+	Path []string
+}
+
+// Collect walks the command tree rooted at cmd using [clip.Walk] and returns
+// its nodes in the same order in which [clip.Walk] visits them, skipping the
+// subcommands hidden according to [clip.CommandWithHiddenSubcommands]. We
+// inspect the flags of the commands not implementing [clip.CommandWithSubcommands]
+// using env and [clip.InspectFlagSet], which does not run the commands not
+// implementing [clip.InspectableCommand], whose FlagSet is therefore nil.
+func Collect[T clip.ExecEnv](ctx context.Context, env T, progname string, cmd clip.Command[T]) []*Node[T] {
+	var nodes []*Node[T]
+	index := map[string]*Node[T]{}
+	_ = clip.Walk(cmd, func(path []string, cmd clip.Command[T]) error {
+		nx := &Node[T]{Command: cmd, Path: append([]string{progname}, path...)}
+		if len(path) > 0 {
+			nx.Parent = index[strings.Join(path[:len(path)-1], " ")]
+			hx, ok := nx.Parent.Command.(clip.CommandWithHiddenSubcommands[T])
+			if ok && hx.SubcommandHidden(path[len(path)-1]) {
+				return clip.SkipSubcommands
+			}
+			nx.Parent.Children = append(nx.Parent.Children, nx)
+		}
+		if _, ok := cmd.(clip.CommandWithSubcommands[T]); !ok {
+			nx.FlagSet = clip.InspectFlagSet(ctx, &clip.CommandArgs[T]{
+				Command:     cmd,
+				CommandName: nx.Name(),
+				Env:         env,
+			})
+		}
+		index[strings.Join(path, " ")] = nx
+		nodes = append(nodes, nx)
+		return nil
+	})
+	return nodes
+}
+
+// Name returns the command name (e.g., `minirbmk git clone`).
+func (nx *Node[T]) Name() string {
+	return strings.Join(nx.Path, " ")
+}
+
+// PageName returns the name of the page documenting the command (e.g., `minirbmk-git-clone`).
+func (nx *Node[T]) PageName() string {
+	return strings.Join(nx.Path, "-")
+}
+
+// Synopsis returns the synopsis following the command name.
+func (nx *Node[T]) Synopsis() string {
+	// Dispatchers take a subcommand followed by its arguments
+	if _, ok := nx.Command.(clip.CommandWithSubcommands[T]); ok {
+		return "[command] [args]"
+	}
+
+	// Without a flag set, we do not know anything
+	fset := nx.FlagSet
+	if fset == nil {
+		return "[args]"
+	}
+
+	// Otherwise, mirror what [*nflag.FlagSet.PrintUsage] does
+	var words []string
+	if len(fset.Flags()) > 0 {
+		words = append(words, "[options]")
+	}
+	if minimum := fset.MinPositionalArgs; minimum >= 0 {
+		if maximum := fset.MaxPositionalArgs; maximum >= minimum {
+			words = append(words, fset.PositionalArgumentsUsage)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// Description returns the command description.
+func (nx *Node[T]) Description() string {
+	brief := nx.Command.BriefDescription()
+	text := ""
+	if nx.FlagSet != nil {
+		text = nx.FlagSet.Description
+	}
+
+	// Prefer the long description unless it falls back to the brief one
+	if lc, ok := nx.Command.(interface{ LongDescription() string }); ok {
+		if long := lc.LongDescription(); text == "" || long != brief {
+			text = long
+		}
+	}
+	if text == "" {
+		text = brief
+	}
+	return text
+}
+
+// Examples returns the examples without the `Examples:` heading, if
+// any, and without the indentation common to all lines.
+func (nx *Node[T]) Examples() string {
+	if nx.FlagSet == nil || nx.FlagSet.Examples == "" {
+		return ""
+	}
+	lines := strings.Split(strings.TrimRight(nx.FlagSet.Examples, "\n"), "\n")
+	if len(lines) > 0 && strings.EqualFold(strings.TrimSpace(lines[0]), "examples:") {
+		lines = lines[1:]
+	}
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if count := len(line) - len(strings.TrimLeft(line, " \t")); indent < 0 || count < indent {
+			indent = count
+		}
+	}
+	for idx, line := range lines {
+		if len(line) >= indent && indent > 0 {
+			lines[idx] = line[indent:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Group is a named group of subcommands.
+type Group[T clip.ExecEnv] struct {
+	// Title is the group title or empty when the command does not group its subcommands.
+	Title string
+
+	// Children contains the subcommands in the group.
+	Children []*Node[T]
+}
+
+// Groups returns the subcommands grouped as in the usage, if any. When the command
+// does not group its subcommands, there is a single untitled group with all of them.
+func (nx *Node[T]) Groups() []Group[T] {
+	if len(nx.Children) <= 0 {
+		return nil
+	}
+	gx, ok := nx.Command.(interface{ SubcommandGroups() []clip.CommandGroup })
+	if !ok || gx.SubcommandGroups() == nil {
+		return []Group[T]{{Title: "", Children: nx.Children}}
+	}
+	index := make(map[string]*Node[T])
+	for _, child := range nx.Children {
+		index[child.Path[len(child.Path)-1]] = child
+	}
+	var groups []Group[T]
+	for _, group := range gx.SubcommandGroups() {
+		gr := Group[T]{Title: group.Title}
+		for _, name := range group.Commands {
+			if child := index[name]; child != nil {
+				gr.Children = append(gr.Children, child)
+			}
+		}
+		groups = append(groups, gr)
+	}
+	return groups
+}
+
+// Flags returns the flags defined by the command, if any.
+func (nx *Node[T]) Flags() []nflag.LongShortFlag {
+	if nx.FlagSet == nil {
+		return nil
+	}
+	return nx.FlagSet.Flags()
+}
+
+// OneLine returns the given text on a single line.
+func OneLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
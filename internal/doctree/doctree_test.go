@@ -0,0 +1,109 @@
+// doctree_test.go - command tree for documentation generators tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package doctree
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+func TestCollect(t *testing.T) {
+	leaf := &clip.LeafCommand[*clip.StdlibExecEnv]{
+		BriefDescriptionText: "Brief.",
+		RunFunc: func(ctx context.Context, args *clip.CommandArgs[*clip.StdlibExecEnv]) error {
+			fset := clip.NewFlagSet(args, nflag.ContinueOnError)
+			fset.Description = "Description."
+			fset.Examples = "Examples:\n  tool a\n    tool b\n"
+			fset.BoolFlag("verbose", 'v', "Run in verbose mode.")
+			return fset.Parse(args.Args)
+		},
+		UsesNewFlagSet: true,
+	}
+	root := &clip.DispatcherCommand[*clip.StdlibExecEnv]{
+		BriefDescriptionText: "Root.",
+		Commands: map[string]clip.Command[*clip.StdlibExecEnv]{
+			"b": leaf,
+			"a": &clip.VersionCommand[*clip.StdlibExecEnv]{Version: "0.1.0"},
+		},
+	}
+
+	nodes := Collect(context.Background(), clip.NewStdlibExecEnv(), "tool", clip.Command[*clip.StdlibExecEnv](root))
+
+	t.Run("the nodes are in depth-first order", func(t *testing.T) {
+		if len(nodes) != 3 {
+			t.Fatalf("expected 3 nodes, got %d", len(nodes))
+		}
+		for idx, expect := range []string{"tool", "tool-a", "tool-b"} {
+			if got := nodes[idx].PageName(); got != expect {
+				t.Errorf("expected %q, got %q", expect, got)
+			}
+		}
+		if nodes[2].Parent != nodes[0] || len(nodes[0].Children) != 2 {
+			t.Fatal("unexpected tree structure")
+		}
+	})
+
+	t.Run("we skip the hidden commands", func(t *testing.T) {
+		root := &clip.DispatcherCommand[*clip.StdlibExecEnv]{
+			Commands: root.Commands,
+			Hidden:   map[string]bool{"a": true},
+		}
+		nodes := Collect(context.Background(), clip.NewStdlibExecEnv(), "tool", clip.Command[*clip.StdlibExecEnv](root))
+		if len(nodes) != 2 || nodes[1].PageName() != "tool-b" || len(nodes[0].Children) != 1 {
+			t.Fatalf("unexpected nodes: %+v", nodes)
+		}
+	})
+
+	t.Run("Synopsis", func(t *testing.T) {
+		if got := nodes[0].Synopsis(); got != "[command] [args]" {
+			t.Errorf("unexpected dispatcher synopsis: %q", got)
+		}
+		if got := nodes[2].Synopsis(); got != "[options] arg ..." {
+			t.Errorf("unexpected leaf synopsis: %q", got)
+		}
+	})
+
+	t.Run("Description", func(t *testing.T) {
+		if got := nodes[0].Description(); got != "Root." {
+			t.Errorf("unexpected dispatcher description: %q", got)
+		}
+		if got := nodes[2].Description(); got != "Description." {
+			t.Errorf("unexpected leaf description: %q", got)
+		}
+	})
+
+	t.Run("Groups", func(t *testing.T) {
+		groups := nodes[0].Groups()
+		if len(groups) != 1 || groups[0].Title != "" || len(groups[0].Children) != 2 {
+			t.Fatalf("unexpected groups: %+v", groups)
+		}
+		if got := nodes[2].Groups(); got != nil {
+			t.Errorf("unexpected leaf groups: %+v", got)
+		}
+
+		root.Groups = []clip.CommandGroup{{Title: "Leaves", Commands: []string{"b"}}}
+		nodes := Collect(context.Background(), clip.NewStdlibExecEnv(), "tool", clip.Command[*clip.StdlibExecEnv](root))
+		groups = nodes[0].Groups()
+		if len(groups) != 2 {
+			t.Fatalf("expected 2 groups, got %d", len(groups))
+		}
+		for idx, expect := range []string{"Leaves tool-b", "Other commands tool-a"} {
+			if got := groups[idx].Title + " " + groups[idx].Children[0].PageName(); got != expect {
+				t.Errorf("expected %q, got %q", expect, got)
+			}
+		}
+	})
+
+	t.Run("Examples", func(t *testing.T) {
+		if got := nodes[2].Examples(); got != "tool a\n  tool b" {
+			t.Errorf("unexpected examples: %q", got)
+		}
+		if got := nodes[0].Examples(); got != "" {
+			t.Errorf("unexpected dispatcher examples: %q", got)
+		}
+	})
+}
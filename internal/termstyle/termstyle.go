@@ -0,0 +1,34 @@
+// termstyle.go - ANSI styling of the usage.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+// Package termstyle styles the usage printed on terminals supporting
+// colors using ANSI escape sequences.
+package termstyle
+
+// Style styles text using ANSI escape sequences when true and
+// returns the text unmodified otherwise.
+type Style bool
+
+// Heading styles a section heading (e.g., `Options:`) using bold.
+func (s Style) Heading(text string) string {
+	return s.wrap("\x1b[1m", text)
+}
+
+// Flag styles a flag name (e.g., `--verbose`) using bold cyan.
+func (s Style) Flag(text string) string {
+	return s.wrap("\x1b[1;36m", text)
+}
+
+// Placeholder styles a placeholder (e.g., `VALUE`) using underline.
+func (s Style) Placeholder(text string) string {
+	return s.wrap("\x1b[4m", text)
+}
+
+// wrap surrounds the nonempty text with the given escape sequence
+// and the sequence resetting the style, when styling is enabled.
+func (s Style) wrap(sequence, text string) string {
+	if !s || text == "" {
+		return text
+	}
+	return sequence + text + "\x1b[0m"
+}
@@ -0,0 +1,35 @@
+// termstyle_test.go - ANSI styling of the usage tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package termstyle
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStyle(t *testing.T) {
+	cases := []struct {
+		name   string
+		style  Style
+		fn     func(s Style, text string) string
+		text   string
+		expect string
+	}{
+		{"heading", true, Style.Heading, "Options:", "\x1b[1mOptions:\x1b[0m"},
+		{"flag", true, Style.Flag, "--verbose", "\x1b[1;36m--verbose\x1b[0m"},
+		{"placeholder", true, Style.Placeholder, "VALUE", "\x1b[4mVALUE\x1b[0m"},
+		{"empty text", true, Style.Heading, "", ""},
+		{"disabled heading", false, Style.Heading, "Options:", "Options:"},
+		{"disabled flag", false, Style.Flag, "--verbose", "--verbose"},
+		{"disabled placeholder", false, Style.Placeholder, "VALUE", "VALUE"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.expect, tc.fn(tc.style, tc.text)); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
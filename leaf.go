@@ -27,9 +27,16 @@ type LeafCommand[T ExecEnv] struct {
 
 	// LongDescriptionText is the optional long description.
 	LongDescriptionText string
+
+	// UsesNewFlagSet optionally indicates that RunFunc creates its flags using
+	// [NewFlagSet] and parses them before performing any work, which allows
+	// shell completion and documentation generators to inspect the flags
+	// (see [InspectFlagSet]). Leave it false otherwise, since inspecting
+	// the flags requires running the command.
+	UsesNewFlagSet bool
 }
 
-var _ Command[*StdlibExecEnv] = &LeafCommand[*StdlibExecEnv]{}
+var _ InspectableCommand[*StdlibExecEnv] = &LeafCommand[*StdlibExecEnv]{}
 
 // BriefDescription implements [Command].
 func (c *LeafCommand[T]) BriefDescription() string {
@@ -55,6 +62,11 @@ func (c *LeafCommand[T]) LongDescription() string {
 	return text
 }
 
+// SupportsInspection implements [InspectableCommand].
+func (c *LeafCommand[T]) SupportsInspection() bool {
+	return c.UsesNewFlagSet
+}
+
 // SupportsSubcommands implements [Command].
 func (c *LeafCommand[T]) SupportsSubcommands() bool {
 	return false
@@ -0,0 +1,75 @@
+// middleware.go - middleware around commands.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import "context"
+
+// Runner is a function with the same signature of the [Command] Run method.
+type Runner[T ExecEnv] func(ctx context.Context, args *CommandArgs[T]) error
+
+// Middleware wraps a [Runner] to implement cross-cutting behavior, such as
+// timing, logging, capturing panics, and checking authorization. The returned
+// [Runner] should call next to run the command, and may run code before and
+// after it, inspect and modify the error, or avoid running the command.
+//
+// You can attach middleware to [*RootCommand] and [*DispatcherCommand]. The
+// middleware applies to the commands below, except [*DispatcherCommand]
+// instances, which pass it down to their own subcommands. Thus, the middleware
+// wraps the command that actually runs, which sees the full [CommandArgs].
+type Middleware[T ExecEnv] func(next Runner[T]) Runner[T]
+
+// Chain returns a [Middleware] composing the given middleware, where the
+// first one is the outermost, i.e., the first to run before the command
+// and the last to run after it.
+func Chain[T ExecEnv](middleware ...Middleware[T]) Middleware[T] {
+	return func(next Runner[T]) Runner[T] {
+		for idx := len(middleware) - 1; idx >= 0; idx-- {
+			next = middleware[idx](next)
+		}
+		return next
+	}
+}
+
+// PreRun returns a [Middleware] calling fn before running the command. When fn
+// returns an error, we do not run the command and we return such an error.
+func PreRun[T ExecEnv](fn func(ctx context.Context, args *CommandArgs[T]) error) Middleware[T] {
+	return func(next Runner[T]) Runner[T] {
+		return func(ctx context.Context, args *CommandArgs[T]) error {
+			if err := fn(ctx, args); err != nil {
+				return err
+			}
+			return next(ctx, args)
+		}
+	}
+}
+
+// PostRun returns a [Middleware] calling fn after running the command, even
+// when the command fails, passing it the error returned by the command, which
+// may be nil. We return the error returned by fn, which may therefore replace
+// or suppress the command error. Return the given error to preserve it.
+func PostRun[T ExecEnv](fn func(ctx context.Context, args *CommandArgs[T], err error) error) Middleware[T] {
+	return func(next Runner[T]) Runner[T] {
+		return func(ctx context.Context, args *CommandArgs[T]) error {
+			return fn(ctx, args, next(ctx, args))
+		}
+	}
+}
+
+// runCommand runs cmd using args, wrapping it with args.Middleware unless
+// cmd is a [*DispatcherCommand], which applies it to its subcommands.
+func runCommand[T ExecEnv](ctx context.Context, cmd Command[T], args *CommandArgs[T]) error {
+	if _, ok := cmd.(*DispatcherCommand[T]); ok || len(args.Middleware) <= 0 {
+		return cmd.Run(ctx, args)
+	}
+	return Chain(args.Middleware...)(cmd.Run)(ctx, args)
+}
+
+// appendMiddleware returns a new slice containing the inherited
+// middleware followed by the given middleware.
+func appendMiddleware[T ExecEnv](inherited []Middleware[T], middleware ...Middleware[T]) []Middleware[T] {
+	if len(middleware) <= 0 {
+		return inherited
+	}
+	return append(append([]Middleware[T]{}, inherited...), middleware...)
+}
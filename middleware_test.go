@@ -0,0 +1,222 @@
+// middleware_test.go - middleware around commands tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// newMiddlewareTestLogger returns a [Middleware] appending to the given log.
+func newMiddlewareTestLogger(log *[]string, name string) Middleware[*StdlibExecEnv] {
+	return func(next Runner[*StdlibExecEnv]) Runner[*StdlibExecEnv] {
+		return func(ctx context.Context, args *CommandArgs[*StdlibExecEnv]) error {
+			*log = append(*log, fmt.Sprintf("%s: enter %s %v", name, args.CommandName, args.Args))
+			err := next(ctx, args)
+			*log = append(*log, fmt.Sprintf("%s: leave %v", name, err))
+			return err
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	var log []string
+	chain := Chain(
+		newMiddlewareTestLogger(&log, "a"),
+		newMiddlewareTestLogger(&log, "b"),
+	)
+	expectErr := errors.New("mocked error")
+	err := chain(func(ctx context.Context, args *CommandArgs[*StdlibExecEnv]) error {
+		log = append(log, "run")
+		return expectErr
+	})(context.Background(), &CommandArgs[*StdlibExecEnv]{CommandName: "test"})
+	if !errors.Is(err, expectErr) {
+		t.Fatal("expected", expectErr, "got", err)
+	}
+	expect := []string{
+		"a: enter test []",
+		"b: enter test []",
+		"run",
+		"b: leave mocked error",
+		"a: leave mocked error",
+	}
+	if diff := cmp.Diff(expect, log); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestPreRunAndPostRun(t *testing.T) {
+	type testcase struct {
+		name      string
+		preErr    error
+		runErr    error
+		expectErr error
+		expectLog []string
+	}
+
+	errPre := errors.New("pre failed")
+	errRun := errors.New("run failed")
+
+	cases := []testcase{
+		{
+			name:      "all hooks run on success",
+			expectLog: []string{"pre", "run", "post <nil>"},
+		},
+
+		{
+			name:      "post hooks see the command error",
+			runErr:    errRun,
+			expectErr: errRun,
+			expectLog: []string{"pre", "run", "post run failed"},
+		},
+
+		{
+			name:      "pre hooks errors prevent running the command",
+			preErr:    errPre,
+			expectErr: errPre,
+			expectLog: []string{"pre", "post pre failed"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var log []string
+			chain := Chain(
+				PostRun(func(ctx context.Context, args *CommandArgs[*StdlibExecEnv], err error) error {
+					log = append(log, fmt.Sprintf("post %v", err))
+					return err
+				}),
+				PreRun(func(ctx context.Context, args *CommandArgs[*StdlibExecEnv]) error {
+					log = append(log, "pre")
+					return tc.preErr
+				}),
+			)
+			err := chain(func(ctx context.Context, args *CommandArgs[*StdlibExecEnv]) error {
+				log = append(log, "run")
+				return tc.runErr
+			})(context.Background(), &CommandArgs[*StdlibExecEnv]{})
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatal("expected", tc.expectErr, "got", err)
+			}
+			if diff := cmp.Diff(tc.expectLog, log); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestDispatchMiddleware(t *testing.T) {
+	// newDispatcher creates the dispatcher used by the test cases.
+	newDispatcher := func(log *[]string) (*DispatcherCommand[*StdlibExecEnv], *DispatcherCommand[*StdlibExecEnv]) {
+		clone := &LeafCommand[*StdlibExecEnv]{
+			BriefDescriptionText: "Clone a repository.",
+			RunFunc: func(ctx context.Context, args *CommandArgs[*StdlibExecEnv]) error {
+				*log = append(*log, "clone")
+				if len(args.Args) > 0 && args.Args[0] == "panic" {
+					panic("mocked panic")
+				}
+				return nil
+			},
+		}
+		git := &DispatcherCommand[*StdlibExecEnv]{
+			BriefDescriptionText: "Utility to manage repositories.",
+			Commands:             map[string]Command[*StdlibExecEnv]{"clone": clone},
+			Middleware:           []Middleware[*StdlibExecEnv]{newMiddlewareTestLogger(log, "git")},
+		}
+		root := &DispatcherCommand[*StdlibExecEnv]{
+			BriefDescriptionText: "A collection of tools.",
+			Commands:             map[string]Command[*StdlibExecEnv]{"git": git},
+			Middleware:           []Middleware[*StdlibExecEnv]{newMiddlewareTestLogger(log, "root")},
+			Version:              "0.1.0",
+		}
+		return root, git
+	}
+
+	t.Run("the middleware wraps the leaf command and is inherited", func(t *testing.T) {
+		var log []string
+		root, git := newDispatcher(&log)
+		var parent Command[*StdlibExecEnv]
+		root.Middleware = append(root.Middleware, PreRun(func(ctx context.Context, args *CommandArgs[*StdlibExecEnv]) error {
+			parent = args.Parent
+			return nil
+		}))
+		err := root.Run(context.Background(), &CommandArgs[*StdlibExecEnv]{
+			Args:        []string{"git", "clone", "repo"},
+			Command:     root,
+			CommandName: "test",
+			Env:         NewStdlibExecEnv(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := []string{
+			"root: enter test git clone [repo]",
+			"git: enter test git clone [repo]",
+			"clone",
+			"git: leave <nil>",
+			"root: leave <nil>",
+		}
+		if diff := cmp.Diff(expect, log); diff != "" {
+			t.Fatal(diff)
+		}
+		if parent != git {
+			t.Fatal("expected the parent to be the git dispatcher")
+		}
+	})
+
+	t.Run("the middleware wraps synthesized commands", func(t *testing.T) {
+		var log []string
+		root, _ := newDispatcher(&log)
+		err := root.Run(context.Background(), &CommandArgs[*StdlibExecEnv]{
+			Args:        []string{"version"},
+			Command:     root,
+			CommandName: "test",
+			Env:         NewStdlibExecEnv(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := []string{"root: enter test version []", "root: leave <nil>"}
+		if diff := cmp.Diff(expect, log); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("the middleware can capture panics", func(t *testing.T) {
+		var log []string
+		root, _ := newDispatcher(&log)
+		root.Middleware = append(root.Middleware, func(next Runner[*StdlibExecEnv]) Runner[*StdlibExecEnv] {
+			return func(ctx context.Context, args *CommandArgs[*StdlibExecEnv]) (err error) {
+				defer func() {
+					if r := recover(); r != nil {
+						err = fmt.Errorf("recovered: %v", r)
+					}
+				}()
+				return next(ctx, args)
+			}
+		})
+		err := root.Run(context.Background(), &CommandArgs[*StdlibExecEnv]{
+			Args:        []string{"git", "clone", "panic"},
+			Command:     root,
+			CommandName: "test",
+			Env:         NewStdlibExecEnv(),
+		})
+		if err == nil || err.Error() != "recovered: mocked panic" {
+			t.Fatal("unexpected error", err)
+		}
+		expect := []string{
+			"root: enter test git clone [panic]",
+			"git: enter test git clone [panic]",
+			"clone",
+			"root: leave recovered: mocked panic",
+		}
+		if diff := cmp.Diff(expect, log); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+}
@@ -3,9 +3,11 @@
 
 package clip
 
-// Must invokes exit with exit code 1 if err is not nil.
+// Must invokes exit if err is not nil, using the exit code returned
+// by [ExitCode], which is 1 unless the error is an [ExitCoder], a usage
+// error, or [nflag.ErrHelp]. This function does not print the error.
 func Must[T ExecEnv](env T, err error) {
 	if err != nil {
-		env.Exit(1)
+		env.Exit(ExitCode(err))
 	}
 }
@@ -0,0 +1,130 @@
+// output.go - machine-readable output.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// newErrorReport is like [nflag.NewErrorReport] but also handles the errors
+// returned by [*DispatcherCommand], using the following kinds:
+//
+//   - "no_such_command" for [*NoSuchCommandError];
+//
+//   - "ambiguous_command" for [*AmbiguousCommandError];
+//
+//   - "invalid_flags" for [ErrInvalidFlags].
+func newErrorReport(commandName string, err error) *nflag.ErrorReport {
+	report := nflag.NewErrorReport(commandName, err)
+	var (
+		noSuchCommand *NoSuchCommandError
+		ambiguous     *AmbiguousCommandError
+	)
+	switch {
+	case errors.As(err, &noSuchCommand):
+		report.Kind = "no_such_command"
+		report.Command = noSuchCommand.Name
+		report.Suggestions = noSuchCommand.Suggestions
+
+	case errors.As(err, &ambiguous):
+		report.Kind = "ambiguous_command"
+		report.Command = ambiguous.Name
+		report.Suggestions = ambiguous.Candidates
+
+	case errors.Is(err, ErrInvalidFlags):
+		report.Kind = "invalid_flags"
+	}
+	return report
+}
+
+// printError prints the error returned by the command, prefixed by the command
+// name, to the given [io.Writer] using the given [nflag.OutputFormat].
+func printError(w io.Writer, format nflag.OutputFormat, commandName string, err error) {
+	if format == nflag.OutputFormatJSON {
+		nflag.PrintJSON(w, newErrorReport(commandName, err))
+		return
+	}
+	fmt.Fprintf(w, "%s: %s\n", commandName, err.Error())
+}
+
+// printWarning prints the given warning, prefixed by its Program, to the given
+// [io.Writer] using the given [nflag.OutputFormat]. When printing text, we only
+// use the Program and Message fields of the [*nflag.ErrorReport].
+func printWarning(w io.Writer, format nflag.OutputFormat, report *nflag.ErrorReport) {
+	if format == nflag.OutputFormatJSON {
+		nflag.PrintJSON(w, report)
+		return
+	}
+	fmt.Fprintf(w, "%s: warning: %s\n", report.Program, report.Message)
+}
+
+// printInterruptMessage prints the InterruptMessage of [*RootCommand], prefixed
+// by the program name, to the given [io.Writer] using the given [nflag.OutputFormat].
+func printInterruptMessage(w io.Writer, format nflag.OutputFormat, progname, message string) {
+	if format == nflag.OutputFormatJSON {
+		nflag.PrintJSON(w, &nflag.ErrorReport{Kind: "interrupted", Program: progname, Message: message})
+		return
+	}
+	fmt.Fprintf(w, "%s: %s\n", progname, message)
+}
+
+// dispatcherUsageReport is the JSON representation of the [*DispatcherCommand] usage.
+type dispatcherUsageReport struct {
+	// Program is the program name.
+	Program string `json:"program"`
+
+	// Description is the dispatcher description.
+	Description string `json:"description"`
+
+	// Commands contains the subcommands.
+	Commands []commandUsageReport `json:"commands"`
+
+	// GlobalOptions contains the persistent flags, if any.
+	GlobalOptions []nflag.FlagUsage `json:"global_options,omitempty"`
+}
+
+// commandUsageReport is the JSON representation of a subcommand usage.
+type commandUsageReport struct {
+	// Name is the subcommand name.
+	Name string `json:"name"`
+
+	// Aliases contains the subcommand aliases, if any.
+	Aliases []string `json:"aliases,omitempty"`
+
+	// Description is the subcommand brief description.
+	Description string `json:"description"`
+
+	// Group is the title of the usage section listing the subcommand.
+	Group string `json:"group"`
+}
+
+// usageReport is like formatUsage but returns the usage JSON representation.
+func (dx *DispatcherCommand[T]) usageReport(
+	commandName string, external map[string]Command[T], persistent []*nflag.FlagSet) *dispatcherUsageReport {
+	report := &dispatcherUsageReport{
+		Program:     commandName,
+		Description: dx.BriefDescriptionText,
+		Commands:    []commandUsageReport{},
+	}
+	commands, groups := dx.usageGroups(commandName, external)
+	aliases := dx.aliasesByCommand()
+	for _, group := range groups {
+		for _, name := range group.Commands {
+			report.Commands = append(report.Commands, commandUsageReport{
+				Name:        name,
+				Aliases:     aliases[name],
+				Description: commands[name].BriefDescription(),
+				Group:       group.Title,
+			})
+		}
+	}
+	for _, fset := range persistent {
+		report.GlobalOptions = append(report.GlobalOptions, fset.FlagsUsageReport()...)
+	}
+	return report
+}
@@ -0,0 +1,215 @@
+// output_test.go - machine-readable output tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bassosimone/clip/pkg/nflag"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOutputFormat(t *testing.T) {
+	type testcase struct {
+		name         string
+		format       nflag.OutputFormat
+		environ      map[string]string
+		args         []string
+		leafErr      error
+		expectStatus int
+		expectStdout string
+		expectStderr string
+	}
+
+	cases := []testcase{
+		{
+			name:         "we print the usage as JSON using the flag",
+			args:         []string{"--output-format", "json"},
+			expectStatus: -1,
+			expectStdout: `{"program":"test","description":"Test dispatcher.","commands":[` +
+				`{"name":"clone","description":"Clone a repository.","group":"Commands"},` +
+				`{"name":"config","description":"Edit the configuration.","group":"Commands"},` +
+				`{"name":"pull","aliases":["p"],"description":"Pull changes.","group":"Commands"}],` +
+				`"global_options":[{"long":"--output-format","takes_arg":true,` +
+				`"usage":"Print errors and help using the given format (text or json)."}]}` + "\n",
+		},
+
+		{
+			name:         "we print errors as JSON using the environment variable",
+			environ:      map[string]string{"TEST_OUTPUT_FORMAT": "json"},
+			args:         []string{"pulx"},
+			expectStatus: 2,
+			expectStderr: `{"kind":"no_such_command","program":"test","message":"no such command: pulx",` +
+				`"command":"pulx","suggestions":["pull"]}` + "\n",
+		},
+
+		{
+			name:         "the flag overrides the environment variable",
+			environ:      map[string]string{"TEST_OUTPUT_FORMAT": "json"},
+			args:         []string{"pulx", "--output-format=text"},
+			expectStatus: 2,
+			expectStderr: "test: no such command: pulx\nDid you mean this?\n  pull\n" +
+				"Try 'test --help' for more information.\n",
+		},
+
+		{
+			name:         "we print ambiguous commands errors as JSON",
+			format:       nflag.OutputFormatJSON,
+			args:         []string{"c"},
+			expectStatus: 2,
+			expectStderr: `{"kind":"ambiguous_command","program":"test","message":"ambiguous command: c",` +
+				`"command":"c","suggestions":["clone","config"]}` + "\n",
+		},
+
+		{
+			name:         "we print invalid flags errors as JSON",
+			format:       nflag.OutputFormatJSON,
+			args:         []string{"--nope", "-x"},
+			expectStatus: 2,
+			expectStderr: `{"kind":"invalid_flags","program":"test","message":"invalid flags",` +
+				`"value":"--nope -x"}` + "\n",
+		},
+
+		{
+			name:         "we print deprecation warnings as JSON",
+			format:       nflag.OutputFormatJSON,
+			args:         []string{"conf"},
+			expectStatus: -1,
+			expectStderr: `{"kind":"deprecated_command","program":"test","message":"'config' is deprecated; ` +
+				`use 'clone' instead","command":"config","suggestions":["clone"]}` + "\n",
+		},
+
+		{
+			name:         "we print the leaf command flags errors as JSON",
+			format:       nflag.OutputFormatJSON,
+			args:         []string{"pull", "--depht", "1"},
+			expectStatus: 2,
+			expectStderr: `{"kind":"unknown_option","program":"test pull","message":"unknown option: --depht",` +
+				`"option":"--depht","token_index":1,"suggestions":["--depth"]}` + "\n",
+		},
+
+		{
+			name:         "we print the leaf command invalid values as JSON",
+			format:       nflag.OutputFormatJSON,
+			args:         []string{"pull", "--depth", "x"},
+			expectStatus: 2,
+			expectStderr: `{"kind":"invalid_value","program":"test pull","message":"invalid value \"x\" for flag --depth: ` +
+				`strconv.ParseInt: parsing \"x\": invalid syntax","option":"--depth","value":"x","token_index":1}` + "\n",
+		},
+
+		{
+			name:         "we print the leaf command usage as JSON",
+			format:       nflag.OutputFormatJSON,
+			args:         []string{"pull", "--help"},
+			expectStatus: 0,
+			expectStdout: `{"program":"test pull","positional_arguments":"arg ...","options":[` +
+				`{"short":"-h","long":"--help","takes_arg":false,"usage":"Print this help message and exit."},` +
+				`{"long":"--depth","takes_arg":true,"usage":"Create a shallow clone."}]}` + "\n",
+		},
+
+		{
+			name:         "we print the leaf command errors as JSON",
+			format:       nflag.OutputFormatJSON,
+			args:         []string{"clone"},
+			leafErr:      errors.New("mocked error"),
+			expectStatus: 1,
+			expectStderr: `{"kind":"error","program":"test","message":"mocked error"}` + "\n",
+		},
+
+		{
+			name:         "we reject invalid output formats in the environment",
+			environ:      map[string]string{"TEST_OUTPUT_FORMAT": "xml"},
+			args:         []string{"clone"},
+			expectStatus: 2,
+			expectStderr: `test: invalid value "xml" for environment variable TEST_OUTPUT_FORMAT: ` +
+				"invalid output format: xml (expected text or json)\n",
+		},
+
+		{
+			name:         "we reject invalid output formats on the command line",
+			args:         []string{"clone", "--output-format", "xml"},
+			expectStatus: 2,
+			expectStderr: "test: invalid output format: xml (expected text or json)\n",
+		},
+
+		{
+			name:         "we reject the flag without a value",
+			args:         []string{"clone", "--output-format"},
+			expectStatus: 2,
+			expectStderr: "test: option requires an argument: --output-format\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			status := -1
+			env := NewStdlibExecEnv()
+			env.OSArgs = append([]string{"test"}, tc.args...)
+			env.OSExit = func(code int) {
+				status = code
+				panic("mocked exit")
+			}
+			env.OSLookupEnv = func(key string) (string, bool) {
+				value, found := tc.environ[key]
+				return value, found
+			}
+			env.OSStderr = &stderr
+			env.OSStdout = &stdout
+
+			root := &RootCommand[*StdlibExecEnv]{
+				Command: &DispatcherCommand[*StdlibExecEnv]{
+					AllowAbbreviations:   true,
+					Aliases:              map[string]string{"p": "pull"},
+					BriefDescriptionText: "Test dispatcher.",
+					Commands: map[string]Command[*StdlibExecEnv]{
+						"clone": &LeafCommand[*StdlibExecEnv]{
+							BriefDescriptionText: "Clone a repository.",
+							RunFunc: func(ctx context.Context, args *CommandArgs[*StdlibExecEnv]) error {
+								return tc.leafErr
+							},
+						},
+						"config": &LeafCommand[*StdlibExecEnv]{
+							BriefDescriptionText: "Edit the configuration.",
+						},
+						"pull": &LeafCommand[*StdlibExecEnv]{
+							BriefDescriptionText: "Pull changes.",
+							RunFunc: func(ctx context.Context, args *CommandArgs[*StdlibExecEnv]) error {
+								fset := NewFlagSet(args, nflag.ExitOnError)
+								fset.GlobalFlagSets = nil
+								fset.AutoHelp("help", 'h', "Print this help message and exit.")
+								fset.Int64Flag("depth", 0, "Create a shallow clone.")
+								return fset.Parse(args.Args)
+							},
+						},
+					},
+					Deprecated:                map[string]Deprecation{"config": {Replacement: "clone"}},
+					OptionPrefixes:            []string{"-", "--"},
+					OptionsArgumentsSeparator: "--",
+				},
+				OutputFormat:       tc.format,
+				OutputFormatEnvVar: "TEST_OUTPUT_FORMAT",
+				OutputFormatFlag:   true,
+			}
+
+			func() {
+				defer func() { recover() }()
+				root.Main(env)
+			}()
+
+			if status != tc.expectStatus {
+				t.Fatal("expected", tc.expectStatus, "got", status)
+			}
+			if diff := cmp.Diff(tc.expectStdout, stdout.String()); diff != "" {
+				t.Fatal(diff)
+			}
+			if diff := cmp.Diff(tc.expectStderr, stderr.String()); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+// persistent.go - persistent flags.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bassosimone/clip/pkg/nflag"
+	"github.com/bassosimone/clip/pkg/nparser"
+)
+
+// parsePersistentFlags removes the PersistentFlags from the arguments, parses
+// them using a copy of the PersistentFlags wired to args.Env, like [NewFlagSet]
+// does, and using args.OutputFormat, and returns the [*CommandArgs] to use for
+// dispatching, whose PersistentFlags include such a copy. On failure, we print
+// the error like the other usage errors.
+func (dx *DispatcherCommand[T]) parsePersistentFlags(args *CommandArgs[T]) (*CommandArgs[T], error) {
+	if dx.PersistentFlags == nil {
+		return args, nil
+	}
+	fset := dx.PersistentFlags.Clone()
+	fset.ErrorHandling = nflag.ContinueOnError
+	fset.Exit = args.Env.Exit
+	fset.LookupEnv = args.Env.LookupEnv
+	fset.OutputFormat = args.OutputFormat
+	fset.Stderr = args.Env.Stderr()
+	fset.Stdout = args.Env.Stdout()
+	persistent, others := splitPersistentFlags(fset, args.Args)
+	if err := fset.Parse(persistent); err != nil {
+		return nil, dx.errorPersistentFlags(args, err)
+	}
+	return withPersistentFlags(args, fset, others), nil
+}
+
+// errorPersistentFlags prints the given error that occurred
+// parsing the PersistentFlags and returns a [reportedError].
+func (dx *DispatcherCommand[T]) errorPersistentFlags(args *CommandArgs[T], err error) error {
+	if args.OutputFormat == nflag.OutputFormatJSON {
+		nflag.PrintJSON(args.Env.Stderr(), newErrorReport(args.CommandName, err))
+		return reportedError{err}
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: %s\n", args.CommandName, err.Error())
+	fmt.Fprintf(&sb, "Try '%s --help' for more information.\n", args.CommandName)
+	fmt.Fprintln(args.Env.Stderr(), strings.TrimSpace(sb.String()))
+	return reportedError{err}
+}
+
+// withPersistentFlags returns a copy of args using the given arguments and
+// whose PersistentFlags include the given flag set, unless it is nil.
+func withPersistentFlags[T ExecEnv](args *CommandArgs[T], fset *nflag.FlagSet, newArgs []string) *CommandArgs[T] {
+	nargs := *args
+	nargs.Args = newArgs
+	if fset != nil {
+		nargs.PersistentFlags = append(append([]*nflag.FlagSet{}, args.PersistentFlags...), fset)
+	}
+	return &nargs
+}
+
+// splitPersistentFlags separates the arguments consumed by the flags in fset, including
+// the values of the flags requiring an argument, from the other arguments. We stop at
+// the options-arguments separator, which belongs to the other arguments along with
+// all the arguments following it.
+//
+// Because we only know the arity of the flags in fset, we consider a group of short
+// flags (e.g., `-vq`) only when all the flags in the group belong to fset.
+func splitPersistentFlags(fset *nflag.FlagSet, args []string) (persistent, others []string) {
+	for idx := 0; idx < len(args); idx++ {
+		arg := args[idx]
+		if sep := fset.OptionsArgumentsSeparator; sep != "" && arg == sep {
+			others = append(others, args[idx:]...)
+			break
+		}
+		count := persistentFlagArgsCount(fset, arg)
+		if count <= 0 {
+			others = append(others, arg)
+			continue
+		}
+		end := min(idx+count, len(args))
+		persistent = append(persistent, args[idx:end]...)
+		idx = end - 1
+	}
+	return
+}
+
+// persistentFlagArgsCount returns the number of arguments consumed by the flags in
+// fset given the current argument: zero when the argument is not one of such flags,
+// two when the flag requires an argument that follows, one otherwise.
+func persistentFlagArgsCount(fset *nflag.FlagSet, arg string) int {
+	// Handle the `--file FILE`, `--file=FILE`, and `--verbose` cases
+	for _, pair := range fset.Flags() {
+		long := pair.LongFlag
+		if long == nil {
+			continue
+		}
+		name := long.Option.Prefix + long.Option.Name
+		switch {
+		case arg == name && requiresSeparateArg(long):
+			return 2
+		case arg == name || (long.TakesArg && strings.HasPrefix(arg, name+"=")):
+			return 1
+		}
+	}
+
+	// Handle the `-vf FILE`, `-vfFILE`, and `-v` cases
+	for _, pair := range fset.Flags() {
+		short := pair.ShortFlag
+		if short == nil || !strings.HasPrefix(arg, short.Option.Prefix) {
+			continue
+		}
+		group := arg[len(short.Option.Prefix):]
+		if count := shortFlagsGroupArgsCount(fset, short.Option.Prefix, group); count > 0 {
+			return count
+		}
+	}
+	return 0
+}
+
+// shortFlagsGroupArgsCount is like [persistentFlagArgsCount] for a group of short flags.
+func shortFlagsGroupArgsCount(fset *nflag.FlagSet, prefix, group string) int {
+	for idx := 0; idx < len(group); idx++ {
+		flag, found := fset.LookupFlagShort(group[idx])
+		if !found || flag.Option.Prefix != prefix {
+			return 0
+		}
+		if flag.TakesArg {
+			if idx == len(group)-1 && requiresSeparateArg(flag) {
+				return 2
+			}
+			return 1
+		}
+	}
+	return min(len(group), 1)
+}
+
+// requiresSeparateArg returns whether the flag value may be the next argument.
+func requiresSeparateArg(flag *nflag.Flag) bool {
+	return flag.TakesArg && flag.Option.Type != nparser.OptionTypeStandaloneArgumentOptional
+}
@@ -0,0 +1,351 @@
+// persistent_test.go - persistent flags tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bassosimone/clip/pkg/assert"
+	"github.com/bassosimone/clip/pkg/nflag"
+	"github.com/bassosimone/clip/pkg/nparser"
+	"github.com/google/go-cmp/cmp"
+)
+
+// newPersistentTestFlagSet creates the persistent flags used by these tests.
+func newPersistentTestFlagSet() *nflag.FlagSet {
+	fset := nflag.NewFlagSet("test", nflag.ContinueOnError)
+	fset.StringFlag("config", 'c', "Read the configuration from `FILE`.")
+	fset.BoolFlag("verbose", 'v', "Run in verbose mode.")
+	fset.BoolFlag("", 'x', "Enable tracing.")
+	fset.StringFlag("color", 0, "Use colors.")
+	assert.True1(fset.LookupFlagLong("color")).Option.Type = nparser.OptionTypeStandaloneArgumentOptional
+	return fset
+}
+
+func Test_splitPersistentFlags(t *testing.T) {
+	type testcase struct {
+		name             string
+		args             []string
+		expectPersistent []string
+		expectOthers     []string
+	}
+
+	cases := []testcase{
+		{
+			name:         "no persistent flags",
+			args:         []string{"git", "clone", "-b", "main"},
+			expectOthers: []string{"git", "clone", "-b", "main"},
+		},
+
+		{
+			name:             "long flag with a separate value",
+			args:             []string{"--config", "foo", "git"},
+			expectPersistent: []string{"--config", "foo"},
+			expectOthers:     []string{"git"},
+		},
+
+		{
+			name:             "long flag with an attached value",
+			args:             []string{"git", "--config=foo", "clone"},
+			expectPersistent: []string{"--config=foo"},
+			expectOthers:     []string{"git", "clone"},
+		},
+
+		{
+			name:             "long flag with an optional value",
+			args:             []string{"--color", "git", "--color=always"},
+			expectPersistent: []string{"--color", "--color=always"},
+			expectOthers:     []string{"git"},
+		},
+
+		{
+			name:             "short flags with a separate value",
+			args:             []string{"-vc", "foo", "git"},
+			expectPersistent: []string{"-vc", "foo"},
+			expectOthers:     []string{"git"},
+		},
+
+		{
+			name:             "short flags with an attached value",
+			args:             []string{"-cfoo", "git", "-xv"},
+			expectPersistent: []string{"-cfoo", "-xv"},
+			expectOthers:     []string{"git"},
+		},
+
+		{
+			name:             "groups containing other flags",
+			args:             []string{"git", "init", "-qv", "-v"},
+			expectPersistent: []string{"-v"},
+			expectOthers:     []string{"git", "init", "-qv"},
+		},
+
+		{
+			name:             "missing value",
+			args:             []string{"git", "--config"},
+			expectPersistent: []string{"--config"},
+			expectOthers:     []string{"git"},
+		},
+
+		{
+			name:             "we stop at the separator",
+			args:             []string{"-v", "git", "--", "-v"},
+			expectPersistent: []string{"-v"},
+			expectOthers:     []string{"git", "--", "-v"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			persistent, others := splitPersistentFlags(newPersistentTestFlagSet(), tc.args)
+			if diff := cmp.Diff(tc.expectPersistent, persistent); diff != "" {
+				t.Errorf("persistent mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.expectOthers, others); diff != "" {
+				t.Errorf("others mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// newPersistentTestDispatcher returns the tree created by newTestTree
+// with persistent flags defined by the root and by `git`.
+func newPersistentTestDispatcher(result *testTreeResult) *DispatcherCommand[*StdlibExecEnv] {
+	dx := newTestTree(result)
+	dx.PersistentFlags = newPersistentTestFlagSet()
+	git := dx.Commands["git"].(*DispatcherCommand[*StdlibExecEnv])
+	git.PersistentFlags = nflag.NewFlagSet("test git", nflag.ContinueOnError)
+	git.PersistentFlags.BoolFlag("dry-run", 'n', "Do not change anything.")
+	return dx
+}
+
+func TestDispatchPersistentFlags(t *testing.T) {
+	type testcase struct {
+		name         string
+		args         []string
+		expect       testTreeResult
+		expectErr    bool
+		expectStderr string
+	}
+
+	cases := []testcase{
+		{
+			name:   "we skip the value of persistent flags when finding the subcommand",
+			args:   []string{"--config", "foo", "git", "clone", "repo"},
+			expect: testTreeResult{args: []string{"repo"}, config: "foo", persistent: 2},
+		},
+
+		{
+			name:   "we accept persistent flags at any level below",
+			args:   []string{"git", "-v", "clone", "-b", "main", "--config=foo", "repo"},
+			expect: testTreeResult{args: []string{"-b", "main", "repo"}, config: "foo", persistent: 2, verbose: true},
+		},
+
+		{
+			name:   "we accept the persistent flags of nested dispatchers",
+			args:   []string{"git", "clone", "-n", "-v", "repo"},
+			expect: testTreeResult{args: []string{"repo"}, persistent: 2, verbose: true},
+		},
+
+		{
+			name:   "we do not consider the arguments after the separator",
+			args:   []string{"git", "clone", "--", "-v"},
+			expect: testTreeResult{args: []string{"--", "-v"}, persistent: 2},
+		},
+
+		{
+			name:         "we report errors parsing the persistent flags",
+			args:         []string{"git", "clone", "--config"},
+			expectErr:    true,
+			expectStderr: "test: option requires an argument: --config\nTry 'test --help' for more information.\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				result testTreeResult
+				stderr bytes.Buffer
+			)
+			env := NewStdlibExecEnv()
+			env.OSStderr = &stderr
+			dx := newPersistentTestDispatcher(&result)
+			err := dx.Run(context.Background(), &CommandArgs[*StdlibExecEnv]{
+				Args:        tc.args,
+				Command:     dx,
+				CommandName: "test",
+				Env:         env,
+			})
+			if diff := cmp.Diff(tc.expectStderr, stderr.String()); diff != "" {
+				t.Fatalf("stderr mismatch (-want +got):\n%s", diff)
+			}
+			if tc.expectErr {
+				var expect nparser.ErrOptionRequiresArgument
+				if !errors.As(err, &expect) || !isReportedError(err) || ExitCode(err) != ExitUsageError {
+					t.Fatalf("expected reported %T, got %v", expect, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.expect, result, cmp.AllowUnexported(testTreeResult{})); diff != "" {
+				t.Fatalf("result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("we do not modify the PersistentFlags", func(t *testing.T) {
+		var result testTreeResult
+		dx := newPersistentTestDispatcher(&result)
+		for range 2 {
+			err := dx.Run(context.Background(), &CommandArgs[*StdlibExecEnv]{
+				Args:         []string{"--config", "foo", "git", "clone", "repo"},
+				Command:      dx,
+				CommandName:  "test",
+				Env:          NewStdlibExecEnv(),
+				OutputFormat: nflag.OutputFormatJSON,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if dx.PersistentFlags.OutputFormat != nflag.OutputFormatText || len(dx.PersistentFlags.Args()) != 0 {
+			t.Fatal("expected the PersistentFlags not to change")
+		}
+		if result.config != "foo" {
+			t.Fatalf("expected %q, got %q", "foo", result.config)
+		}
+	})
+
+	t.Run("we reject commands defining flags with the names of persistent flags", func(t *testing.T) {
+		var result testTreeResult
+		dx := newPersistentTestDispatcher(&result)
+		dx.Commands["shadow"] = &LeafCommand[*StdlibExecEnv]{
+			RunFunc: func(ctx context.Context, args *CommandArgs[*StdlibExecEnv]) error {
+				fset := NewFlagSet(args, nflag.ContinueOnError)
+				fset.BoolFlag("verbose", 0, "Shadow the persistent flag.")
+				return fset.Parse(args.Args)
+			},
+		}
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected a panic")
+			}
+		}()
+		dx.Run(context.Background(), &CommandArgs[*StdlibExecEnv]{
+			Args:        []string{"shadow"},
+			Command:     dx,
+			CommandName: "test",
+			Env:         NewStdlibExecEnv(),
+		})
+	})
+
+	t.Run("we apply the ExecEnv environment variables", func(t *testing.T) {
+		var result testTreeResult
+		dx := newPersistentTestDispatcher(&result)
+		dx.PersistentFlags.EnvVarPrefix = "TEST"
+		dx.PersistentFlags.LookupEnv = func(key string) (string, bool) {
+			t.Fatal("we should use the ExecEnv LookupEnv")
+			return "", false
+		}
+		env := NewStdlibExecEnv()
+		env.OSLookupEnv = func(key string) (string, bool) {
+			return "bar", key == "TEST_CONFIG"
+		}
+		err := dx.Run(context.Background(), &CommandArgs[*StdlibExecEnv]{
+			Args:        []string{"git", "clone", "repo"},
+			Command:     dx,
+			CommandName: "test",
+			Env:         env,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.config != "bar" {
+			t.Fatalf("expected %q, got %q", "bar", result.config)
+		}
+	})
+
+	t.Run("the usage lists the global options", func(t *testing.T) {
+		for _, tc := range []struct {
+			args   []string
+			expect []string
+		}{
+			{
+				args: []string{"--help"},
+				expect: []string{
+					"Usage: test [options] [command] [args]\n",
+					"\nGlobal options:\n  -c, --config=VALUE\n",
+				},
+			},
+			{
+				args: []string{"git", "clone", "--help"},
+				expect: []string{
+					"Usage: test git clone [options] arg ...\n",
+					"\n  -b, --branch=VALUE\n",
+					"\nGlobal options:\n  -c, --config=VALUE\n",
+					"\n  --color=VALUE\n    Use colors.\n\n  -n, --dry-run\n",
+				},
+			},
+		} {
+			var stdout bytes.Buffer
+			env := NewStdlibExecEnv()
+			env.OSStdout = &stdout
+			var result testTreeResult
+			dx := newPersistentTestDispatcher(&result)
+			err := dx.Run(context.Background(), &CommandArgs[*StdlibExecEnv]{
+				Args:        tc.args,
+				Command:     dx,
+				CommandName: "test",
+				Env:         env,
+			})
+			if err != nil && !errors.Is(err, nflag.ErrHelp) {
+				t.Fatal(err)
+			}
+			for _, expect := range tc.expect {
+				if !strings.Contains(stdout.String(), expect) {
+					t.Errorf("expected %q inside %q", expect, stdout.String())
+				}
+			}
+		}
+	})
+
+	t.Run("we complete the persistent flags", func(t *testing.T) {
+		for _, tc := range []struct {
+			args   []string
+			expect string
+		}{
+			{args: []string{"--conf"}, expect: "--config\n:1\n"},
+			{args: []string{"--config", "foo", "g"}, expect: "git\n:1\n"},
+			{args: []string{"git", "clone", "--dry"}, expect: "--dry-run\n:1\n"},
+			{args: []string{"git", "clone", "-c", "foo", "--br"}, expect: "--branch\n:1\n"},
+			{args: []string{"git", "--conf"}, expect: "--config\n:1\n"},
+			{args: []string{"git", "-c", ""}, expect: ":0\n"},
+		} {
+			var (
+				result testTreeResult
+				stdout bytes.Buffer
+			)
+			env := NewStdlibExecEnv()
+			env.OSStdout = &stdout
+			dx := newPersistentTestDispatcher(&result)
+			err := dx.Run(context.Background(), &CommandArgs[*StdlibExecEnv]{
+				Args:        append([]string{"__complete"}, tc.args...),
+				Command:     dx,
+				CommandName: "test",
+				Env:         env,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.expect, stdout.String()); diff != "" {
+				t.Errorf("%v: completion mismatch (-want +got):\n%s", tc.args, diff)
+			}
+		}
+	})
+}
@@ -0,0 +1,163 @@
+// config.go - configuration files.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// Format is the format of a configuration file.
+type Format int
+
+// These constants define the allowed [Format] values.
+const (
+	// TOML is the TOML format (see [ParseTOML] for the supported subset).
+	TOML = Format(iota)
+
+	// JSON is the JSON format.
+	JSON
+
+	// INI is the INI format.
+	INI
+)
+
+// FormatFromFilename returns the [Format] corresponding to the file
+// extension: [JSON] for `.json`, [INI] for `.ini` and `.conf`, and
+// [TOML] otherwise.
+func FormatFromFilename(filename string) Format {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return JSON
+	case ".ini", ".conf":
+		return INI
+	default:
+		return TOML
+	}
+}
+
+// Entry is a key-value pair inside a configuration [*File].
+type Entry struct {
+	// Section is the section containing the entry, which is the path of the
+	// command whose flags the section configures, excluding the program name,
+	// joined using dots (e.g., `git.clone`). The empty string indicates the
+	// top-level section, which configures the program itself.
+	Section string
+
+	// Key is the key, which is the long name of the flag.
+	Key string
+
+	// Value is the value as a string.
+	Value string
+
+	// Line is the one-based line number where the entry appears.
+	Line int
+}
+
+// File is a parsed configuration file.
+type File struct {
+	// Filename is the name of the file, used when reporting errors.
+	Filename string
+
+	// Entries contains the entries in the order in which they appear.
+	Entries []Entry
+}
+
+// Parse parses the given configuration file content using the format
+// returned by [FormatFromFilename]. The filename is only used to choose
+// the format and to report errors.
+func Parse(filename string, data []byte) (*File, error) {
+	var (
+		entries []Entry
+		err     error
+	)
+	switch FormatFromFilename(filename) {
+	case JSON:
+		entries, err = ParseJSON(data)
+	case INI:
+		entries, err = ParseINI(data)
+	default:
+		entries, err = ParseTOML(data)
+	}
+	var ex *Error
+	if errors.As(err, &ex) {
+		ex.Filename = filename
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &File{Filename: filename, Entries: entries}, nil
+}
+
+// ErrUnknownKey indicates that a configuration key does not
+// correspond to any flag. We wrap this error using [*Error].
+var ErrUnknownKey = errors.New("unknown key")
+
+// ErrInvalidValue indicates that a configuration value is not valid
+// for the corresponding flag. We wrap this error using [*Error].
+var ErrInvalidValue = errors.New("invalid value")
+
+// Error is an error occurring at a given position of a configuration file.
+type Error struct {
+	// Filename is the configuration file name.
+	Filename string
+
+	// Line is the one-based line number.
+	Line int
+
+	// Err is the underlying error.
+	Err error
+}
+
+var _ error = &Error{}
+
+// Error implements error.
+func (err *Error) Error() string {
+	return fmt.Sprintf("%s:%d: %s", err.Filename, err.Line, err.Err.Error())
+}
+
+// Unwrap returns the underlying error.
+func (err *Error) Unwrap() error {
+	return err.Err
+}
+
+// Apply sets the flags in fset using the entries in the given section, mapping
+// each key to the flag with the same long name, through [*nflag.FlagSet.Set].
+// Call this method before [*nflag.FlagSet.Parse], such that environment variables
+// and the command line take precedence over the configuration file.
+//
+// We return an [*Error] wrapping [ErrUnknownKey] when a key does not correspond
+// to any long flag, or [ErrInvalidValue] when we cannot set a flag value.
+func (f *File) Apply(fset *nflag.FlagSet, section string) error {
+	for _, entry := range f.Entries {
+		if entry.Section != section {
+			continue
+		}
+		if _, found := fset.LookupFlagLong(entry.Key); !found || len(entry.Key) <= 1 { // skip short flags
+			return &Error{
+				Filename: f.Filename,
+				Line:     entry.Line,
+				Err:      fmt.Errorf("%w %q in section [%s]", ErrUnknownKey, entry.Key, section),
+			}
+		}
+		if err := fset.Set(entry.Key, entry.Value); err != nil {
+			return &Error{
+				Filename: f.Filename,
+				Line:     entry.Line,
+				Err:      fmt.Errorf("%w %q for %q: %w", ErrInvalidValue, entry.Value, entry.Key, err),
+			}
+		}
+	}
+	return nil
+}
+
+// newError creates an [*Error] for the given line, without the file name,
+// which [Parse] fills in afterwards.
+func newError(line int, format string, args ...any) error {
+	return &Error{Line: line, Err: fmt.Errorf(format, args...)}
+}
@@ -0,0 +1,200 @@
+// config_test.go - configuration files tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bassosimone/clip/pkg/nflag"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFormatFromFilename(t *testing.T) {
+	cases := map[string]Format{
+		"config.toml":    TOML,
+		"config":         TOML,
+		"config.json":    JSON,
+		"CONFIG.JSON":    JSON,
+		"config.ini":     INI,
+		"/etc/tool.conf": INI,
+	}
+	for filename, expect := range cases {
+		t.Run(filename, func(t *testing.T) {
+			if got := FormatFromFilename(filename); got != expect {
+				t.Fatal("expected", expect, "got", got)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	type testcase struct {
+		name      string
+		filename  string
+		input     string
+		expect    *File
+		expectErr string
+	}
+
+	cases := []testcase{
+		{
+			name:     "TOML",
+			filename: "config.toml",
+			input:    "[git]\nverbose = true\n",
+			expect: &File{
+				Filename: "config.toml",
+				Entries:  []Entry{{Section: "git", Key: "verbose", Value: "true", Line: 2}},
+			},
+		},
+
+		{
+			name:     "JSON",
+			filename: "config.json",
+			input:    "{\"git\": {\"verbose\": true}}",
+			expect: &File{
+				Filename: "config.json",
+				Entries:  []Entry{{Section: "git", Key: "verbose", Value: "true", Line: 1}},
+			},
+		},
+
+		{
+			name:     "INI",
+			filename: "config.ini",
+			input:    "[git]\nverbose = true\n",
+			expect: &File{
+				Filename: "config.ini",
+				Entries:  []Entry{{Section: "git", Key: "verbose", Value: "true", Line: 2}},
+			},
+		},
+
+		{
+			name:      "errors include the file name",
+			filename:  "config.toml",
+			input:     "\nverbose\n",
+			expectErr: "config.toml:2: expected '=' after key",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			file, err := Parse(tc.filename, []byte(tc.input))
+			if err != nil {
+				if diff := cmp.Diff(tc.expectErr, err.Error()); diff != "" {
+					t.Fatal(diff)
+				}
+				return
+			}
+			if tc.expectErr != "" {
+				t.Fatal("expected", tc.expectErr, "got", nil)
+			}
+			if diff := cmp.Diff(tc.expect, file); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestFileApply(t *testing.T) {
+	type values struct {
+		Branch string
+		Depth  int64
+		Quiet  bool
+	}
+
+	type testcase struct {
+		name        string
+		input       string
+		section     string
+		args        []string
+		expect      values
+		expectErr   string
+		expectWraps error
+	}
+
+	cases := []testcase{
+		{
+			name:    "we apply the entries of the given section",
+			input:   "branch = \"x\"\n[git.clone]\nbranch = \"main\"\ndepth = 1\nquiet = true\n",
+			section: "git.clone",
+			expect:  values{Branch: "main", Depth: 1, Quiet: true},
+		},
+
+		{
+			name:    "we apply the entries of the top-level section",
+			input:   "branch = \"x\"\n[git.clone]\nbranch = \"main\"\n",
+			section: "",
+			expect:  values{Branch: "x"},
+		},
+
+		{
+			name:    "the command line overrides the configuration",
+			input:   "[git.clone]\nbranch = \"main\"\ndepth = 1\n",
+			section: "git.clone",
+			args:    []string{"--branch", "dev"},
+			expect:  values{Branch: "dev", Depth: 1},
+		},
+
+		{
+			name:        "we report unknown keys",
+			input:       "[git.clone]\nbranch = \"main\"\nverbose = true\n",
+			section:     "git.clone",
+			expectErr:   `config.toml:3: unknown key "verbose" in section [git.clone]`,
+			expectWraps: ErrUnknownKey,
+		},
+
+		{
+			name:        "we do not map keys to short flags",
+			input:       "[git.clone]\nb = \"main\"\n",
+			section:     "git.clone",
+			expectErr:   `config.toml:2: unknown key "b" in section [git.clone]`,
+			expectWraps: ErrUnknownKey,
+		},
+
+		{
+			name:        "we report invalid values",
+			input:       "[git.clone]\n\ndepth = \"deep\"\n",
+			section:     "git.clone",
+			expectErr:   `config.toml:3: invalid value "deep" for "depth": strconv.ParseInt: parsing "deep": invalid syntax`,
+			expectWraps: ErrInvalidValue,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var vx values
+			fset := nflag.NewFlagSet("clone", nflag.ContinueOnError)
+			fset.StringFlagVar(&vx.Branch, "branch", 'b', "Branch to checkout.")
+			fset.Int64FlagVar(&vx.Depth, "depth", 0, "Clone depth.")
+			fset.BoolFlagVar(&vx.Quiet, "quiet", 'q', "Do not print progress.")
+
+			file, err := Parse("config.toml", []byte(tc.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = file.Apply(fset, tc.section)
+			if err == nil {
+				err = fset.Parse(tc.args)
+			}
+			switch {
+			case err != nil && tc.expectErr == "":
+				t.Fatal(err)
+			case err == nil && tc.expectErr != "":
+				t.Fatal("expected", tc.expectErr, "got", nil)
+			case err != nil:
+				if diff := cmp.Diff(tc.expectErr, err.Error()); diff != "" {
+					t.Fatal(diff)
+				}
+				var ex *Error
+				if !errors.As(err, &ex) || !errors.Is(err, tc.expectWraps) {
+					t.Fatal("unexpected error type", err)
+				}
+				return
+			}
+			if diff := cmp.Diff(tc.expect, vx); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
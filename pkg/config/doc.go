@@ -0,0 +1,26 @@
+// doc.go - package documentation.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+// Package config loads flag defaults from configuration files.
+//
+// A configuration file contains key-value pairs grouped into sections. Each
+// section configures the flags of a command, identified by its path without
+// the program name, with components joined by dots. The top-level section
+// configures the program itself. Each key is the long name of a flag and
+// each value is the string that we pass to the flag [nflag.Value] Set method.
+// For example, the following TOML file configures `prog git clone`:
+//
+//	[git.clone]
+//	branch = "main"
+//	depth = 1
+//	quiet = true
+//
+// We support TOML (see [ParseTOML] for the supported subset), JSON (see
+// [ParseJSON]), and INI (see [ParseINI]) files. Use [Parse] to parse a file
+// choosing the format according to its extension. Then, use [*File.Apply]
+// to set the flags of an [*nflag.FlagSet] before parsing the command line,
+// such that the command line overrides the configuration file.
+//
+// We report unknown keys and invalid values using [*Error], which includes
+// the file name and line number of the offending entry.
+package config
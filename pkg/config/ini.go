@@ -0,0 +1,59 @@
+// ini.go - INI parser.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package config
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ParseINI parses an INI configuration file content.
+//
+// Lines contain either a `[section]` header or a `key = value` entry, where
+// we also accept `:` instead of `=`. Lines starting with `;` or `#` are
+// comments. We remove the quotes surrounding the values, if any. The
+// entries before the first section header belong to the top-level section.
+//
+// The returned errors are [*Error] instances without the file name.
+func ParseINI(data []byte) ([]Entry, error) {
+	var (
+		entries []Entry
+		section string
+	)
+	for idx, line := range strings.Split(string(data), "\n") {
+		lineno := idx + 1
+		line = strings.TrimSpace(line)
+
+		switch {
+		// skip empty lines and comments
+		case line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#"):
+			continue
+
+		// handle section headers
+		case strings.HasPrefix(line, "["):
+			if !strings.HasSuffix(line, "]") {
+				return nil, newError(lineno, "unterminated section header")
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+
+		// handle key-value pairs
+		default:
+			sep := strings.IndexAny(line, "=:")
+			if sep <= 0 {
+				return nil, newError(lineno, "expected key = value")
+			}
+			key := strings.TrimSpace(line[:sep])
+			value := strings.TrimSpace(line[sep+1:])
+			if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+				unquoted, err := strconv.Unquote(value)
+				if err != nil {
+					return nil, newError(lineno, "invalid quoted value: %s", value)
+				}
+				value = unquoted
+			}
+			entries = append(entries, Entry{Section: section, Key: key, Value: value, Line: lineno})
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,74 @@
+// ini_test.go - INI parser tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package config
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseINI(t *testing.T) {
+	type testcase struct {
+		name      string
+		input     string
+		expect    []Entry
+		expectErr string
+	}
+
+	cases := []testcase{
+		{
+			name: "sections, separators, comments, and quotes",
+			input: "; comment\n" +
+				"verbose = true\n" +
+				"# another comment\n" +
+				"[git.clone]\n" +
+				"branch: main\n" +
+				"  depth =  1  \r\n" +
+				"message = \"hello, world\"\n",
+			expect: []Entry{
+				{Section: "", Key: "verbose", Value: "true", Line: 2},
+				{Section: "git.clone", Key: "branch", Value: "main", Line: 5},
+				{Section: "git.clone", Key: "depth", Value: "1", Line: 6},
+				{Section: "git.clone", Key: "message", Value: "hello, world", Line: 7},
+			},
+		},
+
+		{
+			name:      "unterminated section headers",
+			input:     "\n[git\n",
+			expectErr: ":2: unterminated section header",
+		},
+
+		{
+			name:      "missing separator",
+			input:     "verbose\n",
+			expectErr: ":1: expected key = value",
+		},
+
+		{
+			name:      "invalid quoted values",
+			input:     "message = \"\\q\"\n",
+			expectErr: ":1: invalid quoted value: \"\\q\"",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			entries, err := ParseINI([]byte(tc.input))
+			if err != nil {
+				if diff := cmp.Diff(tc.expectErr, err.Error()); diff != "" {
+					t.Fatal(diff)
+				}
+				return
+			}
+			if tc.expectErr != "" {
+				t.Fatal("expected", tc.expectErr, "got", nil)
+			}
+			if diff := cmp.Diff(tc.expect, entries); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+// json.go - JSON parser.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ParseJSON parses a JSON configuration file content.
+//
+// The content must be an object. Nested objects are sections keyed by
+// the subcommand names. For example, `{"git": {"clone": {"branch": "main"}}}`
+// sets the `branch` key of the `git.clone` section. Scalar values become
+// strings, null values are ignored, and arrays of scalars produce an entry
+// for each element, which is useful for flags that may be repeated.
+//
+// The returned errors are [*Error] instances without the file name.
+func ParseJSON(data []byte) ([]Entry, error) {
+	px := &jsonParser{data: data, dec: json.NewDecoder(bytes.NewReader(data))}
+	px.dec.UseNumber()
+	tok, err := px.dec.Token()
+	if err != nil {
+		return nil, px.error(err)
+	}
+	if tok != json.Delim('{') {
+		return nil, newError(px.line(), "expected a JSON object")
+	}
+	if err := px.object(nil); err != nil {
+		return nil, err
+	}
+	if _, err := px.dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, newError(px.line(), "unexpected data after the JSON object")
+	}
+	return px.entries, nil
+}
+
+// jsonParser is the state of [ParseJSON].
+type jsonParser struct {
+	data    []byte
+	dec     *json.Decoder
+	entries []Entry
+}
+
+// line returns the line number of the current decoder position.
+func (px *jsonParser) line() int {
+	return 1 + bytes.Count(px.data[:px.dec.InputOffset()], []byte("\n"))
+}
+
+// error converts a decoding error into an [*Error].
+func (px *jsonParser) error(err error) error {
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return newError(px.line(), "%s", err.Error())
+}
+
+// object parses the members of an object whose opening delimiter we already consumed.
+func (px *jsonParser) object(path []string) error {
+	for px.dec.More() {
+		tok, err := px.dec.Token()
+		if err != nil {
+			return px.error(err)
+		}
+		key, _ := tok.(string) // the decoder guarantees that object keys are strings
+		line := px.line()
+		if err := px.value(path, key, line); err != nil {
+			return err
+		}
+	}
+	if _, err := px.dec.Token(); err != nil { // consume the closing delimiter
+		return px.error(err)
+	}
+	return nil
+}
+
+// value parses the value of the given key.
+func (px *jsonParser) value(path []string, key string, line int) error {
+	tok, err := px.dec.Token()
+	if err != nil {
+		return px.error(err)
+	}
+	switch tok {
+	case json.Delim('{'):
+		return px.object(append(append([]string{}, path...), key))
+
+	case json.Delim('['):
+		for px.dec.More() {
+			tok, err := px.dec.Token()
+			if err != nil {
+				return px.error(err)
+			}
+			if err := px.scalar(path, key, tok, px.line()); err != nil {
+				return err
+			}
+		}
+		if _, err := px.dec.Token(); err != nil { // consume the closing delimiter
+			return px.error(err)
+		}
+		return nil
+
+	default:
+		return px.scalar(path, key, tok, line)
+	}
+}
+
+// scalar adds the entry for the given scalar token.
+func (px *jsonParser) scalar(path []string, key string, tok json.Token, line int) error {
+	var value string
+	switch tok := tok.(type) {
+	case nil:
+		return nil
+	case bool:
+		value = map[bool]string{true: "true", false: "false"}[tok]
+	case json.Number:
+		value = tok.String()
+	case string:
+		value = tok
+	default:
+		return newError(line, "expected a scalar value for %q", key)
+	}
+	px.entries = append(px.entries, Entry{
+		Section: strings.Join(path, "."),
+		Key:     key,
+		Value:   value,
+		Line:    line,
+	})
+	return nil
+}
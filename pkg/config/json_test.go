@@ -0,0 +1,98 @@
+// json_test.go - JSON parser tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package config
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseJSON(t *testing.T) {
+	type testcase struct {
+		name      string
+		input     string
+		expect    []Entry
+		expectErr string
+	}
+
+	cases := []testcase{
+		{
+			name: "nested objects, scalars, and arrays",
+			input: "{\n" +
+				"  \"verbose\": true,\n" +
+				"  \"git\": {\n" +
+				"    \"clone\": {\n" +
+				"      \"branch\": \"main\",\n" +
+				"      \"depth\": 1e3,\n" +
+				"      \"reference\": null,\n" +
+				"      \"header\": [\"a\",\n" +
+				"        \"b\"]\n" +
+				"    }\n" +
+				"  }\n" +
+				"}\n",
+			expect: []Entry{
+				{Section: "", Key: "verbose", Value: "true", Line: 2},
+				{Section: "git.clone", Key: "branch", Value: "main", Line: 5},
+				{Section: "git.clone", Key: "depth", Value: "1e3", Line: 6},
+				{Section: "git.clone", Key: "header", Value: "a", Line: 8},
+				{Section: "git.clone", Key: "header", Value: "b", Line: 9},
+			},
+		},
+
+		{
+			name:      "empty input",
+			input:     "",
+			expectErr: ":1: unexpected EOF",
+		},
+
+		{
+			name:      "not an object",
+			input:     "[]",
+			expectErr: ":1: expected a JSON object",
+		},
+
+		{
+			name:      "objects inside arrays",
+			input:     "{\n\"header\": [{}]}",
+			expectErr: ":2: expected a scalar value for \"header\"",
+		},
+
+		{
+			name:      "trailing data",
+			input:     "{}\n{}",
+			expectErr: ":2: unexpected data after the JSON object",
+		},
+
+		{
+			name:      "syntax errors",
+			input:     "{\n\"verbose\" true}",
+			expectErr: ":2: invalid character 't' after object key",
+		},
+
+		{
+			name:      "truncated objects",
+			input:     "{\"git\": {",
+			expectErr: ":1: unexpected end of JSON input",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			entries, err := ParseJSON([]byte(tc.input))
+			if err != nil {
+				if diff := cmp.Diff(tc.expectErr, err.Error()); diff != "" {
+					t.Fatal(diff)
+				}
+				return
+			}
+			if tc.expectErr != "" {
+				t.Fatal("expected", tc.expectErr, "got", nil)
+			}
+			if diff := cmp.Diff(tc.expect, entries); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
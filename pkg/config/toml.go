@@ -0,0 +1,264 @@
+// toml.go - TOML parser.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package config
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ParseTOML parses a TOML configuration file content.
+//
+// We support the subset of TOML that is useful to configure flags: `[a.b]`
+// tables; bare, quoted, and dotted keys; basic and literal strings; integers;
+// floats; booleans; and single-line arrays of these values, which produce
+// an entry for each element. We reject array tables, inline tables, and
+// multi-line strings. Dotted keys extend the current table, therefore
+// `clone.branch = "main"` inside `[git]` sets the `branch` key of the
+// `git.clone` section. Dates and times are passed through unmodified.
+//
+// The returned errors are [*Error] instances without the file name.
+func ParseTOML(data []byte) ([]Entry, error) {
+	var (
+		entries []Entry
+		table   []string
+	)
+	for idx, line := range strings.Split(string(data), "\n") {
+		lineno := idx + 1
+		sx := &tomlScanner{input: strings.TrimSuffix(line, "\r"), line: lineno}
+		sx.skipSpace()
+
+		switch {
+		// skip empty lines and comments
+		case sx.atEnd():
+			continue
+
+		// reject array tables
+		case strings.HasPrefix(sx.rest(), "[["):
+			return nil, newError(lineno, "array tables are not supported")
+
+		// handle tables
+		case sx.peek() == '[':
+			sx.pos++
+			keys, err := sx.key()
+			if err != nil {
+				return nil, err
+			}
+			if !sx.consume(']') {
+				return nil, newError(lineno, "expected ']' after table name")
+			}
+			if err := sx.end(); err != nil {
+				return nil, err
+			}
+			table = keys
+
+		// handle key-value pairs
+		default:
+			keys, err := sx.key()
+			if err != nil {
+				return nil, err
+			}
+			if !sx.consume('=') {
+				return nil, newError(lineno, "expected '=' after key")
+			}
+			values, err := sx.value()
+			if err != nil {
+				return nil, err
+			}
+			if err := sx.end(); err != nil {
+				return nil, err
+			}
+			path := append(append([]string{}, table...), keys[:len(keys)-1]...)
+			for _, value := range values {
+				entries = append(entries, Entry{
+					Section: strings.Join(path, "."),
+					Key:     keys[len(keys)-1],
+					Value:   value,
+					Line:    lineno,
+				})
+			}
+		}
+	}
+	return entries, nil
+}
+
+// tomlScanner scans a single TOML line.
+type tomlScanner struct {
+	input string
+	line  int
+	pos   int
+}
+
+// rest returns the input that we have not consumed yet.
+func (sx *tomlScanner) rest() string {
+	return sx.input[sx.pos:]
+}
+
+// atEnd skips whitespace and returns whether we reached the end of
+// the line or the beginning of a comment.
+func (sx *tomlScanner) atEnd() bool {
+	sx.skipSpace()
+	return sx.pos >= len(sx.input) || sx.input[sx.pos] == '#'
+}
+
+// end returns an error if there is more content on the line.
+func (sx *tomlScanner) end() error {
+	if !sx.atEnd() {
+		return newError(sx.line, "unexpected content: %s", sx.rest())
+	}
+	return nil
+}
+
+// peek returns the current byte or zero at the end of the line.
+func (sx *tomlScanner) peek() byte {
+	if sx.pos >= len(sx.input) {
+		return 0
+	}
+	return sx.input[sx.pos]
+}
+
+// skipSpace skips spaces and tabs.
+func (sx *tomlScanner) skipSpace() {
+	for sx.peek() == ' ' || sx.peek() == '\t' {
+		sx.pos++
+	}
+}
+
+// consume skips whitespace and consumes the given byte, if present.
+func (sx *tomlScanner) consume(ch byte) bool {
+	sx.skipSpace()
+	if sx.peek() != ch {
+		return false
+	}
+	sx.pos++
+	return true
+}
+
+// key scans a possibly dotted key and returns its components.
+func (sx *tomlScanner) key() ([]string, error) {
+	var keys []string
+	for {
+		sx.skipSpace()
+		var (
+			key string
+			err error
+		)
+		switch sx.peek() {
+		case '"', '\'':
+			key, err = sx.str()
+		default:
+			start := sx.pos
+			for isBareKeyChar(sx.peek()) {
+				sx.pos++
+			}
+			key = sx.input[start:sx.pos]
+			if key == "" {
+				err = newError(sx.line, "expected a key")
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+		if !sx.consume('.') {
+			return keys, nil
+		}
+	}
+}
+
+// isBareKeyChar returns whether ch may appear inside a bare key.
+func isBareKeyChar(ch byte) bool {
+	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || ch == '_' || ch == '-'
+}
+
+// value scans a value and returns the corresponding strings, which
+// are more than one for arrays.
+func (sx *tomlScanner) value() ([]string, error) {
+	sx.skipSpace()
+	switch sx.peek() {
+	case '{':
+		return nil, newError(sx.line, "inline tables are not supported")
+
+	case '[':
+		sx.pos++
+		var values []string
+		for !sx.consume(']') { // allows for empty arrays and trailing commas
+			value, err := sx.scalar()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			if sx.consume(',') {
+				continue
+			}
+			if !sx.consume(']') {
+				return nil, newError(sx.line, "expected ',' or ']' in array")
+			}
+			break
+		}
+		return values, nil
+
+	default:
+		value, err := sx.scalar()
+		if err != nil {
+			return nil, err
+		}
+		return []string{value}, nil
+	}
+}
+
+// scalar scans a string, number, boolean, or date value.
+func (sx *tomlScanner) scalar() (string, error) {
+	sx.skipSpace()
+	switch sx.peek() {
+	case '"', '\'':
+		return sx.str()
+	case '[', '{':
+		return "", newError(sx.line, "nested arrays and tables are not supported")
+	}
+	start := sx.pos
+	for sx.pos < len(sx.input) && !strings.ContainsRune(" \t,]#", rune(sx.input[sx.pos])) {
+		sx.pos++
+	}
+	token := sx.input[start:sx.pos]
+	switch {
+	case token == "":
+		return "", newError(sx.line, "expected a value")
+	case token == "true" || token == "false":
+		return token, nil
+	case strings.ContainsAny(token[:1], "+-0123456789"), token == "inf", token == "nan":
+		return strings.ReplaceAll(token, "_", ""), nil
+	default:
+		return "", newError(sx.line, "invalid value: %s", token)
+	}
+}
+
+// str scans a basic or literal string.
+func (sx *tomlScanner) str() (string, error) {
+	quote := sx.peek()
+	if strings.HasPrefix(sx.rest(), strings.Repeat(string(quote), 3)) {
+		return "", newError(sx.line, "multi-line strings are not supported")
+	}
+	start := sx.pos
+	sx.pos++
+	for sx.pos < len(sx.input) && sx.input[sx.pos] != quote {
+		if quote == '"' && sx.input[sx.pos] == '\\' {
+			sx.pos++
+		}
+		sx.pos++
+	}
+	if sx.pos >= len(sx.input) {
+		return "", newError(sx.line, "unterminated string")
+	}
+	sx.pos++
+	literal := sx.input[start:sx.pos]
+	if quote == '\'' {
+		return literal[1 : len(literal)-1], nil
+	}
+	value, err := strconv.Unquote(literal)
+	if err != nil {
+		return "", newError(sx.line, "invalid string: %s", literal)
+	}
+	return value, nil
+}
@@ -0,0 +1,168 @@
+// toml_test.go - TOML parser tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package config
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseTOML(t *testing.T) {
+	type testcase struct {
+		name      string
+		input     string
+		expect    []Entry
+		expectErr string
+	}
+
+	cases := []testcase{
+		{
+			name:  "empty input",
+			input: "",
+		},
+
+		{
+			name: "top-level keys and tables",
+			input: "# comment\n" +
+				"verbose = true\n" +
+				"\n" +
+				"[git.clone]\n" +
+				"branch = \"main\" # trailing comment\n" +
+				"depth = 1_000\n",
+			expect: []Entry{
+				{Section: "", Key: "verbose", Value: "true", Line: 2},
+				{Section: "git.clone", Key: "branch", Value: "main", Line: 5},
+				{Section: "git.clone", Key: "depth", Value: "1000", Line: 6},
+			},
+		},
+
+		{
+			name: "dotted and quoted keys",
+			input: "[git]\n" +
+				"clone.branch = 'main'\n" +
+				"\"ca-cert\" = \"a\\tb\"\n" +
+				"[ \"git\" . init ]\n" +
+				"bare=false\n",
+			expect: []Entry{
+				{Section: "git.clone", Key: "branch", Value: "main", Line: 2},
+				{Section: "git", Key: "ca-cert", Value: "a\tb", Line: 3},
+				{Section: "git.init", Key: "bare", Value: "false", Line: 5},
+			},
+		},
+
+		{
+			name:  "arrays produce an entry for each element",
+			input: "header = [\"a: b\", 'c: d', ]\nempty = []\nratio = [1.5, -2]\n",
+			expect: []Entry{
+				{Section: "", Key: "header", Value: "a: b", Line: 1},
+				{Section: "", Key: "header", Value: "c: d", Line: 1},
+				{Section: "", Key: "ratio", Value: "1.5", Line: 3},
+				{Section: "", Key: "ratio", Value: "-2", Line: 3},
+			},
+		},
+
+		{
+			name:      "array tables",
+			input:     "[[git]]\n",
+			expectErr: ":1: array tables are not supported",
+		},
+
+		{
+			name:      "inline tables",
+			input:     "\ngit = {branch = \"main\"}\n",
+			expectErr: ":2: inline tables are not supported",
+		},
+
+		{
+			name:      "multi-line strings",
+			input:     "branch = \"\"\"main\n\"\"\"\n",
+			expectErr: ":1: multi-line strings are not supported",
+		},
+
+		{
+			name:      "unterminated strings",
+			input:     "branch = \"main\n",
+			expectErr: ":1: unterminated string",
+		},
+
+		{
+			name:      "invalid strings",
+			input:     "branch = \"\\q\"\n",
+			expectErr: ":1: invalid string: \"\\q\"",
+		},
+
+		{
+			name:      "bare words",
+			input:     "branch = main\n",
+			expectErr: ":1: invalid value: main",
+		},
+
+		{
+			name:      "missing values",
+			input:     "branch =\n",
+			expectErr: ":1: expected a value",
+		},
+
+		{
+			name:      "missing equal sign",
+			input:     "branch\n",
+			expectErr: ":1: expected '=' after key",
+		},
+
+		{
+			name:      "missing keys",
+			input:     "= 1\n",
+			expectErr: ":1: expected a key",
+		},
+
+		{
+			name:      "unterminated tables",
+			input:     "[git\n",
+			expectErr: ":1: expected ']' after table name",
+		},
+
+		{
+			name:      "trailing content after tables",
+			input:     "[git] x\n",
+			expectErr: ":1: unexpected content: x",
+		},
+
+		{
+			name:      "trailing content after values",
+			input:     "depth = 1 2\n",
+			expectErr: ":1: unexpected content: 2",
+		},
+
+		{
+			name:      "unterminated arrays",
+			input:     "depth = [1 2]\n",
+			expectErr: ":1: expected ',' or ']' in array",
+		},
+
+		{
+			name:      "nested arrays",
+			input:     "depth = [[1]]\n",
+			expectErr: ":1: nested arrays and tables are not supported",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			entries, err := ParseTOML([]byte(tc.input))
+			if err != nil {
+				if diff := cmp.Diff(tc.expectErr, err.Error()); diff != "" {
+					t.Fatal(diff)
+				}
+				return
+			}
+			if tc.expectErr != "" {
+				t.Fatal("expected", tc.expectErr, "got", nil)
+			}
+			if diff := cmp.Diff(tc.expect, entries); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
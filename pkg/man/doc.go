@@ -0,0 +1,19 @@
+// doc.go - package documentation.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+/*
+Package man generates roff man pages for a [clip] command tree.
+
+The [*Generator] type walks the command tree and emits a page for each
+command, named after the command path (e.g., `minirbmk-git-clone.1`),
+with the NAME, SYNOPSIS, DESCRIPTION, OPTIONS, EXAMPLES and SEE ALSO
+sections. The SEE ALSO section cross-links parents and children.
+
+To document the options of a leaf command, the generator inspects the
+[*nflag.FlagSet] the command creates using [clip.NewFlagSet]. Thus, the
+pages reuse the same text [*nflag.FlagSet.PrintUsage] uses.
+
+Use [*Generator.Generate] to obtain the pages in memory and
+[*Generator.WriteDir] to write them into a directory.
+*/
+package man
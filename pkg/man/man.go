@@ -0,0 +1,243 @@
+// man.go - roff man pages generator.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package man
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/internal/doctree"
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// Page is a man page generated by [*Generator].
+type Page struct {
+	// Name is the page name (e.g., `minirbmk-git-clone`).
+	Name string
+
+	// Section is the manual section (e.g., `1`).
+	Section string
+
+	// Content contains the roff source of the page.
+	Content string
+}
+
+// Filename returns the conventional page file name (e.g., `minirbmk-git-clone.1`).
+func (p *Page) Filename() string {
+	return p.Name + "." + p.Section
+}
+
+// Generator generates a man page for each command of a command tree.
+//
+// We traverse the tree using [clip.Walk]. For each command implementing
+// [clip.CommandWithSubcommands], the page lists the subcommands. For
+// other commands, we obtain the options, the positional arguments usage,
+// the description and the examples from the [*nflag.FlagSet] created
+// using [clip.NewFlagSet], through [clip.InspectFlagSet]. Because we
+// only run the commands implementing [clip.InspectableCommand] to this
+// end, the other commands only get a minimal page.
+//
+// The zero value is not ready to use. Initialize the mandatory fields.
+type Generator[T clip.ExecEnv] struct {
+	// --- mandatory fields ---
+
+	// Command is the mandatory root of the command tree.
+	Command clip.Command[T]
+
+	// Env is the mandatory [clip.ExecEnv] to use when inspecting commands.
+	Env T
+
+	// ProgramName is the mandatory name of the program (e.g., `minirbmk`).
+	ProgramName string
+
+	// --- optional fields ---
+
+	// Date is the optional date printed in the page footer.
+	Date string
+
+	// Manual is the optional manual name printed in the page header.
+	Manual string
+
+	// Section is the optional manual section. If empty, we use "1".
+	Section string
+
+	// Source is the optional source printed in the page footer. If empty
+	// and Command is a [*clip.DispatcherCommand] with a Version, we use the
+	// program name followed by the version.
+	Source string
+}
+
+// Generate walks the command tree and returns the man pages.
+//
+// The first page is the one of the root command. The other pages follow
+// in depth-first order, visiting subcommands sorted by name.
+func (g *Generator[T]) Generate(ctx context.Context) []*Page {
+	nodes := doctree.Collect(ctx, g.Env, g.ProgramName, g.Command)
+	pages := make([]*Page, 0, len(nodes))
+	for _, nx := range nodes {
+		pages = append(pages, &Page{
+			Name:    nx.PageName(),
+			Section: g.section(),
+			Content: g.render(nx),
+		})
+	}
+	return pages
+}
+
+// WriteDir generates the man pages and writes them into the given
+// directory, which must exist, using [*Page.Filename] as the file names.
+func (g *Generator[T]) WriteDir(ctx context.Context, dir string) error {
+	for _, page := range g.Generate(ctx) {
+		filename := filepath.Join(dir, page.Filename())
+		if err := os.WriteFile(filename, []byte(page.Content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// section returns the manual section.
+func (g *Generator[T]) section() string {
+	if g.Section != "" {
+		return g.Section
+	}
+	return "1"
+}
+
+// source returns the source printed in the page footer.
+func (g *Generator[T]) source() string {
+	if g.Source != "" {
+		return g.Source
+	}
+	if dx, ok := g.Command.(*clip.DispatcherCommand[T]); ok && dx.Version != "" {
+		return g.ProgramName + " " + dx.Version
+	}
+	return ""
+}
+
+// render returns the roff source of the page documenting the node.
+func (g *Generator[T]) render(nx *doctree.Node[T]) string {
+	var sb strings.Builder
+
+	// Header
+	fmt.Fprintf(&sb, ".TH %s %s %s %s %s\n", quote(strings.ToUpper(nx.PageName())),
+		quote(g.section()), quote(g.Date), quote(g.source()), quote(g.Manual))
+
+	// Name
+	fmt.Fprintf(&sb, ".SH NAME\n")
+	fmt.Fprintf(&sb, "%s \\- %s\n", escape(nx.PageName()), escape(doctree.OneLine(nx.Command.BriefDescription())))
+
+	// Synopsis
+	fmt.Fprintf(&sb, ".SH SYNOPSIS\n")
+	fmt.Fprintf(&sb, ".B %s\n", escape(nx.Name()))
+	if synopsis := nx.Synopsis(); synopsis != "" {
+		fmt.Fprintf(&sb, "%s\n", escape(synopsis))
+	}
+
+	// Description
+	fmt.Fprintf(&sb, ".SH DESCRIPTION\n")
+	writeParagraphs(&sb, nx.Description())
+
+	// Commands
+	if len(nx.Children) > 0 {
+		fmt.Fprintf(&sb, ".SH COMMANDS\n")
+		for _, group := range nx.Groups() {
+			if group.Title != "" {
+				fmt.Fprintf(&sb, ".SS %s\n", escape(group.Title))
+			}
+			for _, child := range group.Children {
+				fmt.Fprintf(&sb, ".TP\n")
+				fmt.Fprintf(&sb, "\\fB%s\\fR\n", escape(child.Path[len(child.Path)-1]))
+				fmt.Fprintf(&sb, "%s\n", escape(doctree.OneLine(child.Command.BriefDescription())))
+			}
+		}
+	}
+
+	// Options
+	if flags := nx.Flags(); len(flags) > 0 {
+		fmt.Fprintf(&sb, ".SH OPTIONS\n")
+		for _, pair := range flags {
+			fmt.Fprintf(&sb, ".TP\n")
+			fmt.Fprintf(&sb, "%s\n", formatFlag(pair))
+			fmt.Fprintf(&sb, "%s\n", escape(doctree.OneLine(pair.Usage)))
+		}
+	}
+
+	// Examples
+	if examples := nx.Examples(); examples != "" {
+		fmt.Fprintf(&sb, ".SH EXAMPLES\n")
+		fmt.Fprintf(&sb, ".PP\n")
+		fmt.Fprintf(&sb, ".RS\n")
+		fmt.Fprintf(&sb, ".nf\n")
+		fmt.Fprintf(&sb, "%s\n", escape(examples))
+		fmt.Fprintf(&sb, ".fi\n")
+		fmt.Fprintf(&sb, ".RE\n")
+	}
+
+	// See also
+	var related []string
+	if nx.Parent != nil {
+		related = append(related, nx.Parent.PageName())
+	}
+	for _, child := range nx.Children {
+		related = append(related, child.PageName())
+	}
+	if len(related) > 0 {
+		fmt.Fprintf(&sb, ".SH SEE ALSO\n")
+		for idx, name := range related {
+			separator := map[bool]string{true: ",", false: ""}[idx < len(related)-1]
+			fmt.Fprintf(&sb, "\\fB%s\\fR(%s)%s\n", escape(name), g.section(), separator)
+		}
+	}
+
+	return sb.String()
+}
+
+// formatFlag formats the given flags for the OPTIONS section.
+func formatFlag(pair nflag.LongShortFlag) string {
+	var names []string
+	for _, flag := range []*nflag.Flag{pair.ShortFlag, pair.LongFlag} {
+		if flag != nil {
+			names = append(names, "\\fB"+escape(flag.Option.Prefix+flag.Option.Name)+"\\fR")
+		}
+	}
+	text := strings.Join(names, ", ")
+	if pair.TakesArg {
+		separator := map[bool]string{true: "=", false: " "}[pair.LongFlag != nil]
+		text += separator + "\\fIVALUE\\fR"
+	}
+	return text
+}
+
+// writeParagraphs writes the given text as roff paragraphs separated by empty lines.
+func writeParagraphs(sb *strings.Builder, text string) {
+	for _, paragraph := range strings.Split(strings.TrimSpace(text), "\n\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			fmt.Fprintf(sb, ".PP\n")
+			fmt.Fprintf(sb, "%s\n", escape(paragraph))
+		}
+	}
+}
+
+// quote returns the given text as a quoted roff macro argument.
+func quote(text string) string {
+	return `"` + strings.ReplaceAll(escape(text), `"`, `\(dq`) + `"`
+}
+
+// escape escapes the given text for roff.
+func escape(text string) string {
+	text = strings.ReplaceAll(text, `\`, `\e`)
+	text = strings.ReplaceAll(text, "-", `\-`)
+	lines := strings.Split(text, "\n")
+	for idx, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[idx] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
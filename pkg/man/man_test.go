@@ -0,0 +1,142 @@
+// man_test.go - roff man pages generator tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package man
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// newTestGenerator creates the [*Generator] used by these tests.
+func newTestGenerator() *Generator[*clip.StdlibExecEnv] {
+	clone := &clip.LeafCommand[*clip.StdlibExecEnv]{
+		BriefDescriptionText: "Clone a repository.",
+		LongDescriptionText:  "Clone a repository into a new directory.\n\n.Second paragraph.",
+		RunFunc: func(ctx context.Context, args *clip.CommandArgs[*clip.StdlibExecEnv]) error {
+			fset := clip.NewFlagSet(args, nflag.ContinueOnError)
+			fset.PositionalArgumentsUsage = "<repository> [directory]"
+			fset.Examples = "Examples:\n  minirbmk git clone -b main https://example.com/repo\n"
+			fset.StringFlag("branch", 'b', "Branch name.")
+			fset.AutoHelp("help", 'h', "Print this help message and exit.")
+			return fset.Parse(args.Args)
+		},
+		UsesNewFlagSet: true,
+	}
+	legacy := &clip.LeafCommand[*clip.StdlibExecEnv]{
+		BriefDescriptionText: "Command not using NewFlagSet.",
+		RunFunc: func(ctx context.Context, args *clip.CommandArgs[*clip.StdlibExecEnv]) error {
+			fset := nflag.NewFlagSet(args.CommandName, nflag.ExitOnError)
+			fset.Exit = args.Env.Exit
+			fset.Stdout = args.Env.Stdout()
+			return fset.Parse(args.Args)
+		},
+	}
+	env := clip.NewStdlibExecEnv()
+	env.OSExit = func(exitcode int) {
+		panic(fmt.Sprintf("unexpected exit with status %d", exitcode))
+	}
+	return &Generator[*clip.StdlibExecEnv]{
+		Command: &clip.DispatcherCommand[*clip.StdlibExecEnv]{
+			BriefDescriptionText: "A collection of tools.",
+			Commands: map[string]clip.Command[*clip.StdlibExecEnv]{
+				"git": &clip.DispatcherCommand[*clip.StdlibExecEnv]{
+					BriefDescriptionText: "Utility to manage repositories.",
+					Commands: map[string]clip.Command[*clip.StdlibExecEnv]{
+						"clone": clone,
+					},
+				},
+				"legacy": legacy,
+			},
+			Groups:  []clip.CommandGroup{{Title: "Core commands", Commands: []string{"git"}}},
+			Version: "0.1.0",
+		},
+		Env:         env,
+		ProgramName: "minirbmk",
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	pages := newTestGenerator().Generate(context.Background())
+
+	t.Run("we generate a page per command", func(t *testing.T) {
+		var names []string
+		for _, page := range pages {
+			names = append(names, page.Filename())
+		}
+		expect := "minirbmk.1 minirbmk-git.1 minirbmk-git-clone.1 minirbmk-legacy.1 minirbmk-version.1"
+		if got := strings.Join(names, " "); got != expect {
+			t.Fatalf("expected %q, got %q", expect, got)
+		}
+	})
+
+	t.Run("the root page", func(t *testing.T) {
+		content := pages[0].Content
+		for _, expect := range []string{
+			".TH \"MINIRBMK\" \"1\" \"\" \"minirbmk 0.1.0\" \"\"\n",
+			"minirbmk \\- A collection of tools.\n",
+			".SH COMMANDS\n.SS Core commands\n.TP\n\\fBgit\\fR\nUtility to manage repositories.\n",
+			".SS Other commands\n.TP\n\\fBlegacy\\fR\n",
+			".SH SEE ALSO\n\\fBminirbmk\\-git\\fR(1),\n\\fBminirbmk\\-legacy\\fR(1),\n\\fBminirbmk\\-version\\fR(1)\n",
+		} {
+			if !strings.Contains(content, expect) {
+				t.Errorf("expected %q inside %q", expect, content)
+			}
+		}
+	})
+
+	t.Run("the leaf page", func(t *testing.T) {
+		content := pages[2].Content
+		for _, expect := range []string{
+			".SH SYNOPSIS\n.B minirbmk git clone\n[options] <repository> [directory]\n",
+			".SH DESCRIPTION\n.PP\nClone a repository into a new directory.\n.PP\n\\&.Second paragraph.\n",
+			".SH OPTIONS\n.TP\n\\fB\\-b\\fR, \\fB\\-\\-branch\\fR=\\fIVALUE\\fR\nBranch name.\n",
+			".nf\nminirbmk git clone \\-b main https://example.com/repo\n.fi\n",
+			".SH SEE ALSO\n\\fBminirbmk\\-git\\fR(1)\n",
+		} {
+			if !strings.Contains(content, expect) {
+				t.Errorf("expected %q inside %q", expect, content)
+			}
+		}
+	})
+
+	t.Run("the page of a command not using NewFlagSet", func(t *testing.T) {
+		content := pages[3].Content
+		if strings.Contains(content, ".SH OPTIONS") {
+			t.Errorf("unexpected OPTIONS inside %q", content)
+		}
+		if !strings.Contains(content, ".B minirbmk legacy\n[args]\n") {
+			t.Errorf("unexpected SYNOPSIS inside %q", content)
+		}
+	})
+}
+
+func TestGeneratorWriteDir(t *testing.T) {
+	t.Run("on success", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := newTestGenerator().WriteDir(context.Background(), dir); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "minirbmk-git-clone.1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(data), ".TH \"MINIRBMK\\-GIT\\-CLONE\"") {
+			t.Fatalf("unexpected content: %q", string(data))
+		}
+	})
+
+	t.Run("on failure", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "nonexistent")
+		if err := newTestGenerator().WriteDir(context.Background(), dir); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
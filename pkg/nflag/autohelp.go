@@ -49,6 +49,8 @@ func (fx *FlagSet) AutoHelp(longName string, shortName byte, usage string) {
 	// possibly create the long flag value
 	if longName != "" {
 		long = &Flag{
+			Complete: nil,
+			EnvVar:   "",
 			Option: &nparser.Option{
 				Type:   nparser.OptionTypeEarlyArgumentNone,
 				Prefix: fx.LongFlagPrefix,
@@ -63,6 +65,8 @@ func (fx *FlagSet) AutoHelp(longName string, shortName byte, usage string) {
 	// possibly create the short flag value
 	if shortName != 0 {
 		short = &Flag{
+			Complete: nil,
+			EnvVar:   "",
 			Option: &nparser.Option{
 				Type:   nparser.OptionTypeEarlyArgumentNone,
 				Prefix: fx.ShortFlagPrefix,
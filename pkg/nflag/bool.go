@@ -45,6 +45,8 @@ func (fx *FlagSet) BoolFlagVar(valuep *bool, longName string, shortName byte, us
 	// possibly create the long flag value
 	if longName != "" {
 		long = &Flag{
+			Complete: nil,
+			EnvVar:   "",
 			Option: &nparser.Option{
 				Type:   nparser.OptionTypeStandaloneArgumentNone,
 				Prefix: fx.LongFlagPrefix,
@@ -59,6 +61,8 @@ func (fx *FlagSet) BoolFlagVar(valuep *bool, longName string, shortName byte, us
 	// possibly create the short flag value
 	if shortName != 0 {
 		short = &Flag{
+			Complete: nil,
+			EnvVar:   "",
 			Option: &nparser.Option{
 				Type:   nparser.OptionTypeGroupableArgumentNone,
 				Prefix: fx.ShortFlagPrefix,
@@ -79,7 +83,7 @@ type boolValue struct {
 	valuep   *bool
 }
 
-var _ Value = &boolValue{}
+var _ BoolValue = &boolValue{}
 
 func (v *boolValue) Modified() bool {
 	return v.modified
@@ -91,6 +95,11 @@ func (v *boolValue) Set(value string) error {
 	return nil
 }
 
+func (v *boolValue) SetBool(enabled bool) {
+	*v.valuep = enabled
+	v.modified = true
+}
+
 func (v *boolValue) String() string {
 	return strconv.FormatBool(*v.valuep)
 }
@@ -0,0 +1,110 @@
+// completion.go - Shell completion providers.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package nflag
+
+import (
+	"context"
+	"strings"
+
+	"github.com/bassosimone/clip/pkg/assert"
+)
+
+// CompletionDirective tells the shell how to handle completion candidates.
+//
+// Directives are bit flags, so you can combine them.
+type CompletionDirective int
+
+// These constants define the allowed [CompletionDirective] values.
+const (
+	// CompletionDirectiveDefault allows the shell to fall back to
+	// completing file names when there are no candidates.
+	CompletionDirectiveDefault = CompletionDirective(0)
+
+	// CompletionDirectiveNoFileFallback prevents the shell from
+	// completing file names when there are no candidates.
+	CompletionDirectiveNoFileFallback = CompletionDirective(1 << 0)
+
+	// CompletionDirectiveFilterDirs causes the shell to complete
+	// directory names in addition to the candidates.
+	CompletionDirectiveFilterDirs = CompletionDirective(1 << 1)
+)
+
+// CompletionFunc returns the candidates for completing the given partial
+// word along with the [CompletionDirective] for the shell.
+//
+// The context is the one passed to the command being completed and
+// the function should honour its cancellation, e.g., when computing
+// candidates through network requests.
+//
+// The returned candidates should start with the given word.
+type CompletionFunc func(ctx context.Context, word string) ([]string, CompletionDirective)
+
+// CompleteFiles is a [CompletionFunc] completing file names.
+func CompleteFiles(ctx context.Context, word string) ([]string, CompletionDirective) {
+	return nil, CompletionDirectiveDefault
+}
+
+// CompleteDirs is a [CompletionFunc] completing directory names.
+func CompleteDirs(ctx context.Context, word string) ([]string, CompletionDirective) {
+	return nil, CompletionDirectiveNoFileFallback | CompletionDirectiveFilterDirs
+}
+
+// CompleteNothing is a [CompletionFunc] preventing any completion.
+func CompleteNothing(ctx context.Context, word string) ([]string, CompletionDirective) {
+	return nil, CompletionDirectiveNoFileFallback
+}
+
+// CompleteChoices returns a [CompletionFunc] completing the given choices.
+func CompleteChoices(choices ...string) CompletionFunc {
+	return func(ctx context.Context, word string) ([]string, CompletionDirective) {
+		var candidates []string
+		for _, choice := range choices {
+			if strings.HasPrefix(choice, word) {
+				candidates = append(candidates, choice)
+			}
+		}
+		return candidates, CompletionDirectiveNoFileFallback
+	}
+}
+
+// SetFlagCompletion sets the [CompletionFunc] for the value of the flags with
+// the given longName and shortName, which must have already been added.
+//
+// If longName and shortName are empty, this method will panic. If just one
+// of them is empty, this method skips the related flag. This method also
+// panics if a nonempty name does not refer to a flag.
+func (fx *FlagSet) SetFlagCompletion(longName string, shortName byte, fn CompletionFunc) {
+	// make sure at least one of the two names is set
+	assert.True(longName != "" || shortName != 0, "longName and shortName cannot be both zero values")
+
+	// possibly update the long flag
+	if longName != "" {
+		flag, found := fx.LookupFlagLong(longName)
+		assert.True(found, "longName does not refer to a flag")
+		flag.Complete = fn
+	}
+
+	// possibly update the short flag
+	if shortName != 0 {
+		flag, found := fx.LookupFlagShort(shortName)
+		assert.True(found, "shortName does not refer to a flag")
+		flag.Complete = fn
+	}
+}
+
+// PositionalArgumentCompletion returns the [CompletionFunc] for the
+// positional argument with the given zero-based index, if any, or nil.
+//
+// See the PositionalArgumentsCompletion field documentation.
+func (fx *FlagSet) PositionalArgumentCompletion(index int) CompletionFunc {
+	completions := fx.PositionalArgumentsCompletion
+	switch {
+	case index < 0 || len(completions) <= 0:
+		return nil
+	case index >= len(completions):
+		return completions[len(completions)-1]
+	default:
+		return completions[index]
+	}
+}
@@ -0,0 +1,135 @@
+// completion_test.go - Shell completion providers tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package nflag
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestCompletionFuncs(t *testing.T) {
+	type testcase struct {
+		name       string
+		fn         CompletionFunc
+		word       string
+		candidates []string
+		directive  CompletionDirective
+	}
+
+	cases := []testcase{
+		{
+			name:       "CompleteFiles",
+			fn:         CompleteFiles,
+			word:       "READ",
+			candidates: nil,
+			directive:  CompletionDirectiveDefault,
+		},
+
+		{
+			name:       "CompleteDirs",
+			fn:         CompleteDirs,
+			word:       "sr",
+			candidates: nil,
+			directive:  CompletionDirectiveNoFileFallback | CompletionDirectiveFilterDirs,
+		},
+
+		{
+			name:       "CompleteNothing",
+			fn:         CompleteNothing,
+			word:       "",
+			candidates: nil,
+			directive:  CompletionDirectiveNoFileFallback,
+		},
+
+		{
+			name:       "CompleteChoices with matching word",
+			fn:         CompleteChoices("main", "master", "develop"),
+			word:       "ma",
+			candidates: []string{"main", "master"},
+			directive:  CompletionDirectiveNoFileFallback,
+		},
+
+		{
+			name:       "CompleteChoices without matching word",
+			fn:         CompleteChoices("main", "master", "develop"),
+			word:       "x",
+			candidates: nil,
+			directive:  CompletionDirectiveNoFileFallback,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			candidates, directive := tc.fn(context.Background(), tc.word)
+			if !slices.Equal(candidates, tc.candidates) {
+				t.Errorf("expected %v, got %v", tc.candidates, candidates)
+			}
+			if directive != tc.directive {
+				t.Errorf("expected %d, got %d", tc.directive, directive)
+			}
+		})
+	}
+}
+
+func TestFlagSet_SetFlagCompletion(t *testing.T) {
+	t.Run("sets both flags", func(t *testing.T) {
+		fset := NewFlagSet("test", ContinueOnError)
+		fset.StringFlag("branch", 'b', "Branch name.")
+		fset.SetFlagCompletion("branch", 'b', CompleteNothing)
+		long, _ := fset.LookupFlagLong("branch")
+		short, _ := fset.LookupFlagShort('b')
+		if long.Complete == nil || short.Complete == nil {
+			t.Fatal("expected both flags to have a CompletionFunc")
+		}
+	})
+
+	t.Run("panics with an unknown flag", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("expected panic, but did not panic")
+			}
+		}()
+		fset := NewFlagSet("test", ContinueOnError)
+		fset.SetFlagCompletion("branch", 0, CompleteNothing)
+	})
+
+	t.Run("panics with empty names", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("expected panic, but did not panic")
+			}
+		}()
+		fset := NewFlagSet("test", ContinueOnError)
+		fset.SetFlagCompletion("", 0, CompleteNothing)
+	})
+}
+
+func TestFlagSet_PositionalArgumentCompletion(t *testing.T) {
+	fset := NewFlagSet("test", ContinueOnError)
+	if fset.PositionalArgumentCompletion(0) != nil {
+		t.Fatal("expected nil without PositionalArgumentsCompletion")
+	}
+
+	fset.PositionalArgumentsCompletion = []CompletionFunc{CompleteNothing, CompleteDirs}
+	for _, tc := range []struct {
+		index     int
+		directive CompletionDirective
+	}{
+		{0, CompletionDirectiveNoFileFallback},
+		{1, CompletionDirectiveNoFileFallback | CompletionDirectiveFilterDirs},
+		{7, CompletionDirectiveNoFileFallback | CompletionDirectiveFilterDirs},
+	} {
+		fn := fset.PositionalArgumentCompletion(tc.index)
+		if fn == nil {
+			t.Fatalf("expected non-nil CompletionFunc for index %d", tc.index)
+		}
+		if _, directive := fn(context.Background(), ""); directive != tc.directive {
+			t.Fatalf("index %d: expected %d, got %d", tc.index, tc.directive, directive)
+		}
+	}
+	if fset.PositionalArgumentCompletion(-1) != nil {
+		t.Fatal("expected nil with negative index")
+	}
+}
@@ -19,6 +19,23 @@ The package provides comprehensive flag definition methods including [*FlagSet.B
 accept existing pointers to variables rather than returning points. The [*FlagSet.AutoHelp]
 method helps to automatically generate and handle `--help` and `-h` like flags.
 
+Use [*FlagSet.SetFlagCompletion] and the PositionalArgumentsCompletion field
+to attach a [CompletionFunc] to flag values and positional arguments. Shell
+completion uses these functions to obtain the candidates. The package provides
+[CompleteFiles], [CompleteDirs], [CompleteNothing], and [CompleteChoices].
+
+Use [*FlagSet.SetFlagEnvVar] and the EnvVarPrefix field to bind flags to
+environment variables, which [*FlagSet.Parse] reads using the LookupEnv
+field before the command line, such that the command line takes precedence.
+
+Set the OutputFormat field to [OutputFormatJSON] to print errors and usage as
+JSON objects (see [ErrorReport] and [UsageReport]) with [ExitOnError], which
+allows programs running the command to parse its output.
+
+Set the UsageWidth field to wrap the usage at the terminal width, rather
+than at [DefaultUsageWidth] columns, and the UsageColors field to style
+the usage using ANSI colors when printing to a terminal.
+
 Use [github.com/bassosimone/clip/pkg/pflagcompat] to adapt a codebase using
 [github.com/spf13/pflag] to use this package instead.
 */
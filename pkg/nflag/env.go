@@ -0,0 +1,100 @@
+// env.go - Environment variables binding.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package nflag
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bassosimone/clip/pkg/assert"
+)
+
+// ErrInvalidEnvVar indicates that an environment variable
+// bound to a flag contains an invalid value.
+type ErrInvalidEnvVar struct {
+	// Name is the name of the environment variable.
+	Name string
+
+	// Value is the value of the environment variable.
+	Value string
+
+	// Err is the error that occurred setting the flag value.
+	Err error
+}
+
+var _ error = ErrInvalidEnvVar{}
+
+// Error returns a string representation of this error.
+func (err ErrInvalidEnvVar) Error() string {
+	return fmt.Sprintf("invalid value %q for environment variable %s: %s", err.Value, err.Name, err.Err.Error())
+}
+
+// Unwrap returns the underlying error.
+func (err ErrInvalidEnvVar) Unwrap() error {
+	return err.Err
+}
+
+// SetFlagEnvVar binds the flags with the given longName and shortName, which must
+// have already been added, to the environment variable with the given name, which
+// takes precedence over the name derived using the EnvVarPrefix field.
+//
+// If longName and shortName are empty, this method will panic. If just one
+// of them is empty, this method skips the related flag. This method also
+// panics if a nonempty name does not refer to a flag.
+func (fx *FlagSet) SetFlagEnvVar(longName string, shortName byte, name string) {
+	// make sure at least one of the two names is set
+	assert.True(longName != "" || shortName != 0, "longName and shortName cannot be both zero values")
+
+	// possibly update the long flag
+	if longName != "" {
+		flag, found := fx.LookupFlagLong(longName)
+		assert.True(found, "longName does not refer to a flag")
+		flag.EnvVar = name
+	}
+
+	// possibly update the short flag
+	if shortName != 0 {
+		flag, found := fx.LookupFlagShort(shortName)
+		assert.True(found, "shortName does not refer to a flag")
+		flag.EnvVar = name
+	}
+}
+
+// EnvVarName returns the name of the environment variable bound to the given
+// flags or an empty string. We use the name configured using [*FlagSet.SetFlagEnvVar],
+// if any. Otherwise, if EnvVarPrefix is not empty, we derive the name from the
+// prefix and the long flag name. For example, with the `MINIRBMK_CURL` prefix,
+// the `--ca-cert` flag is bound to `MINIRBMK_CURL_CA_CERT`. We never derive
+// names for help flags and flags without a long name.
+func (fx *FlagSet) EnvVarName(pair LongShortFlag) string {
+	for _, flag := range []*Flag{pair.LongFlag, pair.ShortFlag} {
+		if flag != nil && flag.EnvVar != "" {
+			return flag.EnvVar
+		}
+	}
+	if _, ok := pair.Value.(*helpValue); ok || fx.EnvVarPrefix == "" || pair.LongFlag == nil {
+		return ""
+	}
+	name := fx.EnvVarPrefix + "_" + pair.LongFlag.Option.Name
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+}
+
+// applyEnvVars sets the flags values using the bound environment variables.
+func (fx *FlagSet) applyEnvVars() error {
+	for _, pair := range fx.usageView {
+		name := fx.EnvVarName(pair)
+		if name == "" {
+			continue
+		}
+		value, found := fx.LookupEnv(name)
+		if !found {
+			continue
+		}
+
+		if err := setFlagValue(pair.Value, pair.TakesArg, value); err != nil {
+			return ErrInvalidEnvVar{Name: name, Value: value, Err: err}
+		}
+	}
+	return nil
+}
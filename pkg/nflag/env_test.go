@@ -0,0 +1,181 @@
+// env_test.go - Environment variables binding tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package nflag
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestErrInvalidEnvVar(t *testing.T) {
+	err := ErrInvalidEnvVar{Name: "TOOL_COUNT", Value: "x", Err: strconv.ErrSyntax}
+	expect := `invalid value "x" for environment variable TOOL_COUNT: invalid syntax`
+	if diff := cmp.Diff(expect, err.Error()); diff != "" {
+		t.Fatal(diff)
+	}
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Fatal("expected the error to wrap strconv.ErrSyntax")
+	}
+}
+
+func TestFlagSetEnvVars(t *testing.T) {
+	// newFlagSet creates the [*FlagSet] used by the test cases.
+	type values struct {
+		Branch  string
+		Count   int64
+		Quiet   bool
+		Verbose bool
+	}
+	newFlagSet := func(environ map[string]string) (*FlagSet, *values) {
+		vx := &values{}
+		fset := NewFlagSet("tool", ContinueOnError)
+		fset.EnvVarPrefix = "TOOL"
+		fset.LookupEnv = func(key string) (string, bool) {
+			value, found := environ[key]
+			return value, found
+		}
+		fset.AutoHelp("help", 'h', "Print this help message and exit.")
+		fset.StringFlagVar(&vx.Branch, "default-branch", 'b', "Branch name.")
+		fset.Int64FlagVar(&vx.Count, "count", 'c', "Number of repetitions.")
+		fset.BoolFlagVar(&vx.Quiet, "quiet", 'q', "Do not print progress.")
+		fset.BoolFlagVar(&vx.Verbose, "", 'v', "Run in verbose mode.")
+		fset.SetFlagEnvVar("", 'v', "VERBOSE")
+		return fset, vx
+	}
+
+	type testcase struct {
+		name      string
+		environ   map[string]string
+		args      []string
+		expect    values
+		expectErr string
+	}
+
+	cases := []testcase{
+		{
+			name:    "derived names",
+			environ: map[string]string{"TOOL_DEFAULT_BRANCH": "main", "TOOL_COUNT": "3"},
+			expect:  values{Branch: "main", Count: 3},
+		},
+
+		{
+			name:    "the command line takes precedence",
+			environ: map[string]string{"TOOL_DEFAULT_BRANCH": "main", "TOOL_COUNT": "3"},
+			args:    []string{"--default-branch", "develop"},
+			expect:  values{Branch: "develop", Count: 3},
+		},
+
+		{
+			name:    "boolean flags",
+			environ: map[string]string{"TOOL_QUIET": "1", "VERBOSE": "true"},
+			expect:  values{Quiet: true, Verbose: true},
+		},
+
+		{
+			name:    "false boolean flags",
+			environ: map[string]string{"TOOL_QUIET": "false", "VERBOSE": "0"},
+			expect:  values{},
+		},
+
+		{
+			name:      "invalid boolean value",
+			environ:   map[string]string{"TOOL_QUIET": "maybe"},
+			expectErr: `invalid value "maybe" for environment variable TOOL_QUIET`,
+		},
+
+		{
+			name:      "invalid value",
+			environ:   map[string]string{"TOOL_COUNT": "many"},
+			expectErr: `invalid value "many" for environment variable TOOL_COUNT`,
+		},
+
+		{
+			name:    "help flags are not bound",
+			environ: map[string]string{"TOOL_HELP": "1"},
+			expect:  values{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fset, vx := newFlagSet(tc.environ)
+			err := fset.Parse(tc.args)
+			switch {
+			case tc.expectErr != "":
+				var invalid ErrInvalidEnvVar
+				if !errors.As(err, &invalid) || !strings.Contains(err.Error(), tc.expectErr) {
+					t.Fatalf("expected %q, got %v", tc.expectErr, err)
+				}
+				return
+			case err != nil:
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.expect, *vx); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	t.Run("false boolean values override the previous values", func(t *testing.T) {
+		fset, vx := newFlagSet(map[string]string{"TOOL_QUIET": "0"})
+		if err := fset.Set("quiet", "true"); err != nil { // e.g., from a configuration file
+			t.Fatal(err)
+		}
+		if err := fset.Parse(nil); err != nil {
+			t.Fatal(err)
+		}
+		if vx.Quiet {
+			t.Fatal("expected the environment variable to disable the flag")
+		}
+	})
+
+	t.Run("we handle the help flags before reporting invalid values", func(t *testing.T) {
+		fset, _ := newFlagSet(map[string]string{"TOOL_COUNT": "many"})
+		if err := fset.Parse([]string{"--help"}); !errors.Is(err, ErrHelp) {
+			t.Fatalf("expected %v, got %v", ErrHelp, err)
+		}
+	})
+
+	t.Run("the usage shows the environment variables", func(t *testing.T) {
+		fset, _ := newFlagSet(nil)
+		var sb strings.Builder
+		fset.PrintUsage(&sb)
+		for _, expect := range []string{
+			"  -h, --help\n",
+			"  -b, --default-branch=VALUE [env: TOOL_DEFAULT_BRANCH]\n",
+			"  -q, --quiet [env: TOOL_QUIET]\n",
+			"  -v [env: VERBOSE]\n",
+		} {
+			if !strings.Contains(sb.String(), expect) {
+				t.Errorf("expected %q inside %q", expect, sb.String())
+			}
+		}
+	})
+
+	t.Run("without prefix we only use explicit names", func(t *testing.T) {
+		fset, _ := newFlagSet(nil)
+		fset.EnvVarPrefix = ""
+		var names []string
+		for _, pair := range fset.Flags() {
+			names = append(names, fset.EnvVarName(pair))
+		}
+		if diff := cmp.Diff([]string{"", "", "", "", "VERBOSE"}, names); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("SetFlagEnvVar panics with unknown flags", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected a panic")
+			}
+		}()
+		fset, _ := newFlagSet(nil)
+		fset.SetFlagEnvVar("nonexistent", 0, "NONEXISTENT")
+	})
+}
@@ -586,6 +586,6 @@ func ExampleFlagSet_goStrconvError() {
 	fset.Parse([]string{"-count", "not-a-number"})
 
 	// Output:
-	// go test: strconv.ParseInt: parsing "not-a-number": invalid syntax
+	// go test: invalid value "not-a-number" for flag -count: strconv.ParseInt: parsing "not-a-number": invalid syntax
 	// Try 'go test -h' for more help.
 }
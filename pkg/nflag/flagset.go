@@ -7,12 +7,16 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"math"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/bassosimone/clip/pkg/assert"
 	"github.com/bassosimone/clip/pkg/nparser"
+	"github.com/bassosimone/clip/pkg/scanner"
 )
 
 // --- types ---
@@ -26,6 +30,9 @@ const (
 	ContinueOnError = ErrorHandling(iota)
 
 	// ExitOnError causes [*Flagset] to call Exit with code 2 on error.
+	//
+	// Before exiting, we print the error, the similar options carried by
+	// [nparser.ErrUnknownOption] if any, and the help hint.
 	ExitOnError
 
 	// PanicOnError causes [*FlagSet] to panic on error.
@@ -43,6 +50,16 @@ const (
 // These methods usually add two flags per invocation: a long
 // flag and a short flag. See also their documentation.
 type Flag struct {
+	// Complete is the optional [CompletionFunc] for the flag value.
+	//
+	// Use [*FlagSet.SetFlagCompletion] to set it.
+	Complete CompletionFunc
+
+	// EnvVar is the optional name of the environment variable bound to the flag.
+	//
+	// Use [*FlagSet.SetFlagEnvVar] to set it.
+	EnvVar string
+
 	// Option is the related parser option.
 	Option *nparser.Option
 
@@ -94,11 +111,43 @@ type LongShortFlag struct {
 // The [*FlagSet] will recognize `--verbose` as a syntactically valid flag
 // that has not been configured and print an "unknown flag" error.
 type FlagSet struct {
+	// AfterParse is an optional hook invoked by [*FlagSet.Parse] after
+	// successfully parsing the command line. We do not invoke the hook
+	// when the command line requests help, therefore the hook can report
+	// errors (e.g., invalid defaults) without breaking the help flags. If
+	// the hook returns an error, [*FlagSet.Parse] handles it like a parse
+	// error.
+	//
+	// [NewFlagSet] initializes this field to nil.
+	AfterParse func(fx *FlagSet) error
+
+	// BeforeParse is an optional hook invoked by [*FlagSet.Parse] before
+	// parsing the command line. By then, the program has defined all the
+	// flags, therefore the hook can inspect them. If the hook returns an
+	// error, [*FlagSet.Parse] handles it like a parse error.
+	//
+	// [NewFlagSet] initializes this field to nil.
+	BeforeParse func(fx *FlagSet) error
+
 	// Description is the program description used when printing the usage.
 	//
 	// [NewFlagSet] initializes this field to "".
 	Description string
 
+	// EnvVarPrefix is the optional prefix used to derive the name of the
+	// environment variable bound to each flag, when not explicitly set using
+	// [*FlagSet.SetFlagEnvVar]. See [*FlagSet.EnvVarName] for details.
+	//
+	// [NewFlagSet] initializes this field to "".
+	//
+	// When a bound environment variable is set, [*FlagSet.Parse] assigns its
+	// value to the flag before assigning the command line values, such that
+	// the command line takes precedence. For flags not taking arguments, we
+	// interpret the value as a boolean (e.g., `1`, `true`, `0`, `false`), like
+	// [*FlagSet.Set] does. We report invalid values after handling the command
+	// line, such that the help flags work regardless of the environment.
+	EnvVarPrefix string
+
 	// DisablePermute disable the permutation of options and arguments.
 	//
 	// [NewFlagSet] initializes this field to false.
@@ -148,6 +197,21 @@ type FlagSet struct {
 	// [NewFlagSet] initializes this field to [ContinueOnError].
 	ErrorHandling ErrorHandling
 
+	// GlobalFlagSets optionally contains flag sets parsed by the enclosing
+	// commands (e.g., the persistent flags of a subcommands dispatcher), whose
+	// flags we list in the "Global options" section of the usage. Because the
+	// enclosing commands parse these flags, we panic when adding a flag with
+	// the same name of a flag in these flag sets. Therefore, set this field
+	// before adding flags.
+	//
+	// [NewFlagSet] initializes this field to nil.
+	GlobalFlagSets []*FlagSet
+
+	// LookupEnv is the function to lookup environment variables.
+	//
+	// [NewFlagSet] initializes this field to [os.LookupEnv].
+	LookupEnv func(key string) (string, bool)
+
 	// LongFlagPrefix is the prefix for parsing long flags.
 	//
 	// [NewFlagSet] initializes this field to "--".
@@ -186,6 +250,24 @@ type FlagSet struct {
 	// all the remaining entries as positional arguments.
 	OptionsArgumentsSeparator string
 
+	// OutputFormat is the [OutputFormat] used with the [ExitOnError] policy.
+	//
+	// [NewFlagSet] initializes this field to [OutputFormatText].
+	//
+	// With [OutputFormatJSON], we print the errors on the Stderr as a single
+	// line JSON [ErrorReport], without the help hint, and the usage on the
+	// Stdout as a JSON [UsageReport]. See [*FlagSet.PrintErrorJSON] and
+	// [*FlagSet.PrintUsageJSON] for details.
+	OutputFormat OutputFormat
+
+	// PositionalArgumentsCompletion contains the optional [CompletionFunc]
+	// for each positional argument, in order. The last entry also applies
+	// to all the subsequent positional arguments. A nil entry means that
+	// we do not know how to complete the corresponding argument.
+	//
+	// [NewFlagSet] initializes this field to nil.
+	PositionalArgumentsCompletion []CompletionFunc
+
 	// PositionalArgumentsUsage is the usage string for postional arguments.
 	//
 	// [NewFlagSet] initializes this field to "arg ..."
@@ -223,6 +305,19 @@ type FlagSet struct {
 	// We use this field with [ExitOnError] policy.
 	Stdout io.Writer
 
+	// UsageColors indicates whether [*FlagSet.PrintUsage] may style the
+	// headings, the flag names, and the placeholders using ANSI escape
+	// sequences, which only makes sense when printing to a terminal.
+	//
+	// [NewFlagSet] initializes this field to false.
+	UsageColors bool
+
+	// UsageWidth is the number of columns at which [*FlagSet.PrintUsage]
+	// wraps the descriptions, including their indentation.
+	//
+	// [NewFlagSet] initializes this field to [DefaultUsageWidth].
+	UsageWidth int
+
 	// parserView organizes flags for parsing.
 	parserView map[string]*Flag
 
@@ -248,26 +343,47 @@ func NewFlagSet(progname string, handling ErrorHandling) *FlagSet {
 
 	// create with default settings
 	return &FlagSet{
-		Description:               "",
-		DisablePermute:            false,
-		ErrorHandling:             handling,
-		Examples:                  "",
-		Exit:                      os.Exit,
-		LongFlagPrefix:            "--",
-		MaxPositionalArgs:         math.MaxInt,
-		MinPositionalArgs:         0,
-		ProgramName:               progname,
-		OptionsArgumentsSeparator: "--",
-		PositionalArgumentsUsage:  "arg ...",
-		ShortFlagPrefix:           "-",
-		Stderr:                    os.Stderr,
-		Stdout:                    os.Stdout,
-		parserView:                map[string]*Flag{},
-		positionals:               []string{},
-		usageView:                 []LongShortFlag{},
+		AfterParse:                    nil,
+		BeforeParse:                   nil,
+		Description:                   "",
+		DisablePermute:                false,
+		EnvVarPrefix:                  "",
+		ErrorHandling:                 handling,
+		Examples:                      "",
+		Exit:                          os.Exit,
+		GlobalFlagSets:                nil,
+		LookupEnv:                     os.LookupEnv,
+		LongFlagPrefix:                "--",
+		MaxPositionalArgs:             math.MaxInt,
+		MinPositionalArgs:             0,
+		ProgramName:                   progname,
+		OptionsArgumentsSeparator:     "--",
+		OutputFormat:                  OutputFormatText,
+		PositionalArgumentsCompletion: nil,
+		PositionalArgumentsUsage:      "arg ...",
+		ShortFlagPrefix:               "-",
+		Stderr:                        os.Stderr,
+		Stdout:                        os.Stdout,
+		UsageColors:                   false,
+		UsageWidth:                    DefaultUsageWidth,
+		parserView:                    map[string]*Flag{},
+		positionals:                   []string{},
+		usageView:                     []LongShortFlag{},
 	}
 }
 
+// Clone returns a copy of the [*FlagSet] without the positional arguments
+// collected by [*FlagSet.Parse]. The copy shares the flags, and hence their
+// values, with the original. Therefore, parsing using the copy does not modify
+// the original settings and positional arguments but sets the same values.
+func (fx *FlagSet) Clone() *FlagSet {
+	clone := *fx
+	clone.parserView = maps.Clone(fx.parserView)
+	clone.positionals = []string{}
+	clone.usageView = slices.Clone(fx.usageView)
+	return &clone
+}
+
 // --- getters ---
 
 // Args returns the positional arguments collected by [*FlagSet.Parse].
@@ -293,6 +409,55 @@ func (fx *FlagSet) Flags() []LongShortFlag {
 	return fx.usageView
 }
 
+// --- setters ---
+
+// ErrNoSuchFlag indicates that a flag does not exist.
+type ErrNoSuchFlag struct {
+	// Name is the name of the flag.
+	Name string
+}
+
+var _ error = ErrNoSuchFlag{}
+
+// Error returns a string representation of this error.
+func (err ErrNoSuchFlag) Error() string {
+	return fmt.Sprintf("no such flag: %s", err.Name)
+}
+
+// Set sets the value of the flag with the given long or short name as if the
+// flag appeared on the command line with the given value. Because flags without
+// argument are set regardless of the value, for them we interpret the value as
+// a boolean (e.g., `1`, `true`, `0`, `false`). When false, we set the value to
+// false if it implements [BoolValue], like the boolean flags do, and otherwise
+// we do nothing.
+//
+// This method returns [ErrNoSuchFlag] if there is no such flag.
+func (fx *FlagSet) Set(name, value string) error {
+	flag, found := fx.parserView[name]
+	if !found {
+		return ErrNoSuchFlag{Name: name}
+	}
+	return setFlagValue(flag.Value, flag.TakesArg, value)
+}
+
+// setFlagValue implements [*FlagSet.Set] for the given flag value.
+func setFlagValue(fv Value, takesArg bool, value string) error {
+	if !takesArg {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		if bv, ok := fv.(BoolValue); ok {
+			bv.SetBool(enabled)
+			return nil
+		}
+		if !enabled {
+			return nil
+		}
+	}
+	return fv.Set(value)
+}
+
 // --- parsing code ---
 
 // Parse parses the given command line arguments, It assigns positional arguments
@@ -307,12 +472,48 @@ func (fx *FlagSet) Parse(args []string) error {
 	return fx.maybeHandleError(fx.parse(args))
 }
 
+// ErrInvalidFlagValue indicates that the command line
+// contains an invalid value for a flag.
+type ErrInvalidFlagValue struct {
+	// Option is the option corresponding to the flag.
+	Option *nparser.Option
+
+	// Value is the invalid value.
+	Value string
+
+	// Token is the related token.
+	Token scanner.Token
+
+	// Err is the error that occurred setting the flag value.
+	Err error
+}
+
+var _ error = ErrInvalidFlagValue{}
+
+// Error returns a string representation of this error.
+func (err ErrInvalidFlagValue) Error() string {
+	return fmt.Sprintf("invalid value %q for flag %s%s: %s",
+		err.Value, err.Option.Prefix, err.Option.Name, err.Err.Error())
+}
+
+// Unwrap returns the underlying error.
+func (err ErrInvalidFlagValue) Unwrap() error {
+	return err.Err
+}
+
 // ErrHelp is the error returned in case the user requested for `help`.
 //
 // Use [*FlagSet.AutoHelp] to enable recognizing help flags.
 var ErrHelp = errors.New("help requested")
 
 func (fx *FlagSet) parse(args []string) error {
+	// give the hook a chance to run
+	if fx.BeforeParse != nil {
+		if err := fx.BeforeParse(fx); err != nil {
+			return err
+		}
+	}
+
 	// create an argument vector that includes the program name
 	argv := make([]string, 0, 1+len(args))
 	argv = append(argv, fx.ProgramName)
@@ -336,6 +537,10 @@ func (fx *FlagSet) parse(args []string) error {
 		return err
 	}
 
+	// assign the environment variables before the command line values, but
+	// report their errors after the command line, to honour the help flags
+	envErr := fx.applyEnvVars()
+
 	// map the parsed values back to options and positionals
 	for _, value := range values {
 		switch value := value.(type) {
@@ -353,7 +558,7 @@ func (fx *FlagSet) parse(args []string) error {
 
 			// assign a value to the flag
 			if err := flag.Value.Set(value.Value); err != nil {
-				return err
+				return ErrInvalidFlagValue{Option: value.Option, Value: value.Value, Token: value.Tok, Err: err}
 			}
 
 			// detect [helpValue] and transform it to [ErrHelp]
@@ -362,6 +567,14 @@ func (fx *FlagSet) parse(args []string) error {
 			}
 		}
 	}
+	if envErr != nil {
+		return envErr
+	}
+
+	// give the hook a chance to run
+	if fx.AfterParse != nil {
+		return fx.AfterParse(fx)
+	}
 	return nil
 }
 
@@ -375,13 +588,25 @@ func (fx *FlagSet) maybeHandleError(err error) error {
 
 	case fx.ErrorHandling == ExitOnError && errors.Is(err, ErrHelp):
 		var sb strings.Builder
-		fx.PrintUsage(&sb)
+		switch fx.OutputFormat {
+		case OutputFormatJSON:
+			fx.PrintUsageJSON(&sb)
+		default:
+			fx.PrintUsage(&sb)
+		}
 		fmt.Fprint(fx.Stdout, sb.String())
 		fx.Exit(0)
 
+	case fx.ErrorHandling == ExitOnError && fx.OutputFormat == OutputFormatJSON:
+		var sb strings.Builder
+		fx.PrintErrorJSON(&sb, err)
+		fmt.Fprint(fx.Stderr, sb.String())
+		fx.Exit(2)
+
 	case fx.ErrorHandling == ExitOnError:
 		fmt.Fprintf(fx.Stderr, "%s: %s\n", fx.ProgramName, err.Error())
 		var sb strings.Builder
+		fx.printSuggestions(&sb, err)
 		fx.PrintHelpHint(&sb)
 		if sb.Len() > 0 {
 			fmt.Fprint(fx.Stderr, sb.String())
@@ -393,6 +618,25 @@ func (fx *FlagSet) maybeHandleError(err error) error {
 	panic(err)
 }
 
+// printSuggestions prints the suggestions carried by an [nparser.ErrUnknownOption].
+func (fx *FlagSet) printSuggestions(w io.Writer, err error) {
+	var unknown nparser.ErrUnknownOption
+	if !errors.As(err, &unknown) {
+		return
+	}
+	switch len(unknown.Suggestions) {
+	case 0:
+		// nothing
+	case 1:
+		fmt.Fprintf(w, "Did you mean %s?\n", unknown.Suggestions[0])
+	default:
+		fmt.Fprintf(w, "Did you mean one of these?\n")
+		for _, name := range unknown.Suggestions {
+			fmt.Fprintf(w, "  %s\n", name)
+		}
+	}
+}
+
 // --- code to register flags ---
 
 func (fx *FlagSet) mustAddLongAndShortFlag(long, short *Flag) {
@@ -407,6 +651,10 @@ func (fx *FlagSet) mustAddLongAndShortFlag(long, short *Flag) {
 			fname := fpv.Option.Name
 			_, found := fx.parserView[fname]
 			assert.True(!found, fmt.Sprintf("flag %q already defined", fname))
+			for _, global := range fx.GlobalFlagSets {
+				_, found := global.parserView[fname]
+				assert.True(!found, fmt.Sprintf("flag %q already defined by a global flag set", fname))
+			}
 			fx.parserView[fname] = fpv
 			takesArg = fpv.TakesArg
 			usage = fpv.Usage
@@ -4,8 +4,12 @@
 package nflag
 
 import (
+	"bytes"
 	"errors"
+	"strings"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestFlagSet_LookupFlagShort(t *testing.T) {
@@ -53,4 +57,317 @@ func TestFlagSet_maybeHandleError(t *testing.T) {
 			t.Errorf("expected error to be %v, got %v", ErrHelp, err)
 		}
 	})
+
+	t.Run("ExitOnError with suggestions", func(t *testing.T) {
+		// configure to exit on error and capture the exit code
+		fset := NewFlagSet("test", ExitOnError)
+		var stderr bytes.Buffer
+		fset.Stderr = &stderr
+		exitcode := -1
+		fset.Exit = func(code int) {
+			exitcode = code
+			panic("exit")
+		}
+
+		// add the flags we would like to suggest
+		fset.AutoHelp("help", 'h', "Show this help message and exit.")
+		fset.BoolFlag("verbose", 'v', "Run in verbose mode.")
+
+		// invoke with a misspelled flag
+		func() {
+			defer func() { recover() }()
+			fset.Parse([]string{"--verbsoe"})
+		}()
+
+		// make sure we exited with the expected output
+		if exitcode != 2 {
+			t.Fatal("expected 2, got", exitcode)
+		}
+		expect := "test: unknown option: --verbsoe\n" +
+			"Did you mean --verbose?\n" +
+			"Try 'test --help' for more help.\n"
+		if diff := cmp.Diff(expect, stderr.String()); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+}
+
+func TestFlagSet_BeforeParse(t *testing.T) {
+	t.Run("the hook can inspect the flags", func(t *testing.T) {
+		fset := NewFlagSet("test", ContinueOnError)
+		fset.BoolFlag("verbose", 'v', "Run in verbose mode.")
+		var names []string
+		fset.BeforeParse = func(fx *FlagSet) error {
+			for _, pair := range fx.Flags() {
+				names = append(names, pair.LongFlag.Option.Name)
+			}
+			return nil
+		}
+		if err := fset.Parse([]string{"-v"}); err != nil {
+			t.Fatal(err)
+		}
+		if len(names) != 1 || names[0] != "verbose" {
+			t.Fatalf("unexpected flag names: %v", names)
+		}
+	})
+
+	t.Run("the hook error is handled like a parse error", func(t *testing.T) {
+		expect := errors.New("mocked error")
+		fset := NewFlagSet("test", ContinueOnError)
+		fset.BeforeParse = func(fx *FlagSet) error {
+			return expect
+		}
+		if err := fset.Parse(nil); !errors.Is(err, expect) {
+			t.Fatalf("expected %v, got %v", expect, err)
+		}
+	})
+}
+
+func TestFlagSet_AfterParse(t *testing.T) {
+	t.Run("the hook can inspect the parsed flags", func(t *testing.T) {
+		fset := NewFlagSet("test", ContinueOnError)
+		verbose := fset.BoolFlag("verbose", 'v', "Run in verbose mode.")
+		var seen bool
+		fset.AfterParse = func(fx *FlagSet) error {
+			seen = *verbose
+			return nil
+		}
+		if err := fset.Parse([]string{"-v"}); err != nil {
+			t.Fatal(err)
+		}
+		if !seen {
+			t.Fatal("expected the hook to see the parsed flags")
+		}
+	})
+
+	t.Run("the hook error is handled like a parse error", func(t *testing.T) {
+		expect := errors.New("mocked error")
+		fset := NewFlagSet("test", ContinueOnError)
+		fset.AfterParse = func(fx *FlagSet) error {
+			return expect
+		}
+		if err := fset.Parse(nil); !errors.Is(err, expect) {
+			t.Fatalf("expected %v, got %v", expect, err)
+		}
+	})
+
+	t.Run("we do not invoke the hook when requesting help", func(t *testing.T) {
+		fset := NewFlagSet("test", ContinueOnError)
+		fset.AutoHelp("help", 'h', "Print this help message and exit.")
+		fset.AfterParse = func(fx *FlagSet) error {
+			return errors.New("mocked error")
+		}
+		if err := fset.Parse([]string{"--help"}); !errors.Is(err, ErrHelp) {
+			t.Fatalf("expected %v, got %v", ErrHelp, err)
+		}
+	})
+}
+
+func TestFlagSetSet(t *testing.T) {
+	type testcase struct {
+		name        string
+		flag        string
+		value       string
+		expectErr   string
+		expectCount int64
+		expectQuiet bool
+	}
+
+	cases := []testcase{
+		{
+			name:        "we set a flag taking an argument using its long name",
+			flag:        "count",
+			value:       "7",
+			expectCount: 7,
+		},
+
+		{
+			name:        "we set a flag taking an argument using its short name",
+			flag:        "c",
+			value:       "11",
+			expectCount: 11,
+		},
+
+		{
+			name:        "we enable a boolean flag",
+			flag:        "quiet",
+			value:       "true",
+			expectQuiet: true,
+		},
+
+		{
+			name:  "we do not enable a boolean flag set to false",
+			flag:  "quiet",
+			value: "false",
+		},
+
+		{
+			name:      "we reject invalid boolean values",
+			flag:      "quiet",
+			value:     "maybe",
+			expectErr: `strconv.ParseBool: parsing "maybe": invalid syntax`,
+		},
+
+		{
+			name:      "we reject invalid values",
+			flag:      "count",
+			value:     "x",
+			expectErr: `strconv.ParseInt: parsing "x": invalid syntax`,
+		},
+
+		{
+			name:      "we reject unknown flags",
+			flag:      "verbose",
+			value:     "true",
+			expectErr: "no such flag: verbose",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				count int64
+				quiet bool
+			)
+			fset := NewFlagSet("tool", ContinueOnError)
+			fset.Int64FlagVar(&count, "count", 'c', "Number of repetitions.")
+			fset.BoolFlagVar(&quiet, "quiet", 'q', "Do not print progress.")
+
+			err := fset.Set(tc.flag, tc.value)
+			switch {
+			case err != nil && tc.expectErr == "":
+				t.Fatal(err)
+			case err == nil && tc.expectErr != "":
+				t.Fatal("expected", tc.expectErr, "got", nil)
+			case err != nil && err.Error() != tc.expectErr:
+				t.Fatal("expected", tc.expectErr, "got", err.Error())
+			}
+			if count != tc.expectCount || quiet != tc.expectQuiet {
+				t.Fatal("unexpected values", count, quiet)
+			}
+		})
+	}
+}
+
+func TestFlagSetGlobalFlagSets(t *testing.T) {
+	global := NewFlagSet("tool", ContinueOnError)
+	global.EnvVarPrefix = "TOOL"
+	global.StringFlag("config", 0, "Read the configuration from the given file.")
+	empty := NewFlagSet("tool sub", ContinueOnError)
+
+	fset := NewFlagSet("tool sub", ContinueOnError)
+	fset.GlobalFlagSets = []*FlagSet{empty, global}
+	fset.BoolFlag("quiet", 'q', "Do not print progress.")
+
+	var sb strings.Builder
+	fset.PrintUsage(&sb)
+	expect := "Usage: tool sub [options] arg ...\n\n" +
+		"Options:\n" +
+		"  -q, --quiet\n" +
+		"    Do not print progress.\n\n" +
+		"Global options:\n" +
+		"  --config=VALUE [env: TOOL_CONFIG]\n" +
+		"    Read the configuration from the given file.\n\n"
+	if diff := cmp.Diff(expect, sb.String()); diff != "" {
+		t.Fatal(diff)
+	}
+
+	t.Run("we panic when adding a flag defined by a global flag set", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected a panic")
+			}
+		}()
+		fset.StringFlag("config", 'c', "Shadow the global flag.")
+	})
+}
+
+func TestFlagSetClone(t *testing.T) {
+	fset := NewFlagSet("tool", ContinueOnError)
+	verbose := fset.BoolFlag("verbose", 'v', "Run in verbose mode.")
+	if err := fset.Parse([]string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	clone := fset.Clone()
+	clone.OutputFormat = OutputFormatJSON
+	if err := clone.Parse([]string{"-v", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	clone.StringFlag("config", 0, "Read the configuration from the given file.")
+
+	if !*verbose {
+		t.Fatal("expected the clone to share the flags values")
+	}
+	if diff := cmp.Diff([]string{"b"}, clone.Args()); diff != "" {
+		t.Fatal(diff)
+	}
+	if diff := cmp.Diff([]string{"a"}, fset.Args()); diff != "" {
+		t.Fatal(diff)
+	}
+	if _, found := fset.LookupFlagLong("config"); found || len(fset.Flags()) != 1 {
+		t.Fatal("expected the original flags not to change")
+	}
+	if fset.OutputFormat != OutputFormatText {
+		t.Fatal("expected the original settings not to change")
+	}
+}
+
+func TestFlagSetUsageWidthAndColors(t *testing.T) {
+	newFlagSet := func() *FlagSet {
+		global := NewFlagSet("tool", ContinueOnError)
+		global.StringFlag("config", 'c', "Read the configuration from the given file.")
+		fset := NewFlagSet("tool sub", ContinueOnError)
+		fset.Description = "Synchronize the local copy with the remote repository."
+		fset.GlobalFlagSets = []*FlagSet{global}
+		fset.BoolFlag("quiet", 'q', "Do not print progress.")
+		return fset
+	}
+
+	t.Run("we wrap using the UsageWidth", func(t *testing.T) {
+		fset := newFlagSet()
+		fset.UsageWidth = 30
+		var sb strings.Builder
+		fset.PrintUsage(&sb)
+		expect := "Usage: tool sub [options] arg ...\n\n" +
+			"Synchronize the local copy\nwith the remote repository.\n\n" +
+			"Options:\n" +
+			"  -q, --quiet\n" +
+			"    Do not print progress.\n\n" +
+			"Global options:\n" +
+			"  -c, --config=VALUE\n" +
+			"    Read the configuration\n    from the given file.\n\n"
+		if diff := cmp.Diff(expect, sb.String()); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("we use the DefaultUsageWidth when the UsageWidth is not positive", func(t *testing.T) {
+		fset, reference := newFlagSet(), newFlagSet()
+		fset.UsageWidth = 0
+		var sb, expect strings.Builder
+		fset.PrintUsage(&sb)
+		reference.PrintUsage(&expect)
+		if diff := cmp.Diff(expect.String(), sb.String()); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("we style the headings, the flag names, and the placeholders", func(t *testing.T) {
+		fset := newFlagSet()
+		fset.UsageColors = true
+		var sb strings.Builder
+		fset.PrintUsage(&sb)
+		expect := "\x1b[1mUsage:\x1b[0m tool sub [options] arg ...\n\n" +
+			"Synchronize the local copy with the remote repository.\n\n" +
+			"\x1b[1mOptions:\x1b[0m\n" +
+			"  \x1b[1;36m-q\x1b[0m, \x1b[1;36m--quiet\x1b[0m\n" +
+			"    Do not print progress.\n\n" +
+			"\x1b[1mGlobal options:\x1b[0m\n" +
+			"  \x1b[1;36m-c\x1b[0m, \x1b[1;36m--config\x1b[0m=\x1b[4mVALUE\x1b[0m\n" +
+			"    Read the configuration from the given file.\n\n"
+		if diff := cmp.Diff(expect, sb.String()); diff != "" {
+			t.Fatal(diff)
+		}
+	})
 }
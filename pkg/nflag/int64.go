@@ -46,6 +46,8 @@ func (fx *FlagSet) Int64FlagVar(valuep *int64, longName string, shortName byte,
 	// possibly create the long flag value
 	if longName != "" {
 		long = &Flag{
+			Complete: nil,
+			EnvVar:   "",
 			Option: &nparser.Option{
 				Type:   nparser.OptionTypeStandaloneArgumentRequired,
 				Prefix: fx.LongFlagPrefix,
@@ -60,6 +62,8 @@ func (fx *FlagSet) Int64FlagVar(valuep *int64, longName string, shortName byte,
 	// possibly create the short flag value
 	if shortName != 0 {
 		short = &Flag{
+			Complete: nil,
+			EnvVar:   "",
 			Option: &nparser.Option{
 				Type:   nparser.OptionTypeGroupableArgumentRequired,
 				Prefix: fx.ShortFlagPrefix,
@@ -0,0 +1,279 @@
+// output.go - Machine-readable output format.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package nflag
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/bassosimone/clip/pkg/assert"
+	"github.com/bassosimone/clip/pkg/nparser"
+	"github.com/bassosimone/clip/pkg/scanner"
+)
+
+// OutputFormat controls how [*FlagSet] prints errors and usage.
+type OutputFormat int
+
+// These constants define the allowed [OutputFormat] values.
+const (
+	// OutputFormatText causes [*FlagSet] to print human-readable text.
+	OutputFormatText = OutputFormat(iota)
+
+	// OutputFormatJSON causes [*FlagSet] to print JSON.
+	OutputFormatJSON
+)
+
+// String returns the name of the output format (e.g., `json`).
+func (format OutputFormat) String() string {
+	switch format {
+	case OutputFormatText:
+		return "text"
+	case OutputFormatJSON:
+		return "json"
+	default:
+		return fmt.Sprintf("OutputFormat(%d)", int(format))
+	}
+}
+
+// ErrInvalidOutputFormat indicates that an output format name is invalid.
+type ErrInvalidOutputFormat struct {
+	// Name is the invalid output format name.
+	Name string
+}
+
+var _ error = ErrInvalidOutputFormat{}
+
+// Error returns a string representation of this error.
+func (err ErrInvalidOutputFormat) Error() string {
+	return fmt.Sprintf("invalid output format: %s (expected text or json)", err.Name)
+}
+
+// ParseOutputFormat returns the [OutputFormat] with the given name, which
+// is either `text` or `json`, or an [ErrInvalidOutputFormat] error.
+func ParseOutputFormat(name string) (OutputFormat, error) {
+	switch name {
+	case "text":
+		return OutputFormatText, nil
+	case "json":
+		return OutputFormatJSON, nil
+	default:
+		return 0, ErrInvalidOutputFormat{Name: name}
+	}
+}
+
+// ErrorReport is the JSON representation of an error.
+type ErrorReport struct {
+	// Kind is the error kind, which is one of the following:
+	//
+	//	- "unknown_option" for [nparser.ErrUnknownOption];
+	//
+	//	- "option_requires_argument" for [nparser.ErrOptionRequiresArgument];
+	//
+	//	- "option_requires_no_argument" for [nparser.ErrOptionRequiresNoArgument];
+	//
+	//	- "too_few_arguments" for [nparser.ErrTooFewPositionalArguments];
+	//
+	//	- "too_many_arguments" for [nparser.ErrTooManyPositionalArguments];
+	//
+	//	- "invalid_value" for [ErrInvalidFlagValue];
+	//
+	//	- "invalid_env_var" for [ErrInvalidEnvVar];
+	//
+	//	- "error" for any other error.
+	//
+	// Packages building on this package may define additional kinds.
+	Kind string `json:"kind"`
+
+	// Program is the program name.
+	Program string `json:"program"`
+
+	// Message is the error message.
+	Message string `json:"message"`
+
+	// Option is the offending option including its prefix (e.g., `--verbose`), if any.
+	Option string `json:"option,omitempty"`
+
+	// Value is the offending value, if any.
+	Value string `json:"value,omitempty"`
+
+	// EnvVar is the offending environment variable, if any.
+	EnvVar string `json:"env_var,omitempty"`
+
+	// Command is the offending subcommand name, if any.
+	Command string `json:"command,omitempty"`
+
+	// TokenIndex is the index of the offending argument among the arguments
+	// passed to [*FlagSet.Parse], starting from 1, or zero when unknown.
+	TokenIndex int `json:"token_index,omitempty"`
+
+	// Suggestions contains suggestions for fixing the error, if any.
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// NewErrorReport returns the [*ErrorReport] describing the given error
+// returned by [*FlagSet.Parse] for the program with the given name.
+func NewErrorReport(program string, err error) *ErrorReport {
+	report := &ErrorReport{Kind: "error", Program: program, Message: err.Error()}
+	var (
+		unknown       nparser.ErrUnknownOption
+		requiresArg   nparser.ErrOptionRequiresArgument
+		requiresNoArg nparser.ErrOptionRequiresNoArgument
+		tooFew        nparser.ErrTooFewPositionalArguments
+		tooMany       nparser.ErrTooManyPositionalArguments
+		invalidEnvVar ErrInvalidEnvVar
+		invalidValue  ErrInvalidFlagValue
+	)
+	switch {
+	case errors.As(err, &unknown):
+		report.Kind = "unknown_option"
+		report.Option = unknown.Prefix + unknown.Name
+		report.TokenIndex = tokenIndex(unknown.Token)
+		report.Suggestions = unknown.Suggestions
+
+	case errors.As(err, &requiresArg):
+		report.Kind = "option_requires_argument"
+		report.Option = requiresArg.Option.Prefix + requiresArg.Option.Name
+		report.TokenIndex = tokenIndex(requiresArg.Token)
+
+	case errors.As(err, &requiresNoArg):
+		report.Kind = "option_requires_no_argument"
+		report.Option = requiresNoArg.Option.Prefix + requiresNoArg.Option.Name
+		report.TokenIndex = tokenIndex(requiresNoArg.Token)
+
+	case errors.As(err, &tooFew):
+		report.Kind = "too_few_arguments"
+
+	case errors.As(err, &tooMany):
+		report.Kind = "too_many_arguments"
+
+	case errors.As(err, &invalidEnvVar):
+		report.Kind = "invalid_env_var"
+		report.EnvVar = invalidEnvVar.Name
+		report.Value = invalidEnvVar.Value
+
+	case errors.As(err, &invalidValue):
+		report.Kind = "invalid_value"
+		report.Option = invalidValue.Option.Prefix + invalidValue.Option.Name
+		report.Value = invalidValue.Value
+		report.TokenIndex = tokenIndex(invalidValue.Token)
+	}
+	return report
+}
+
+// tokenIndex returns the index of the given token, or zero if it is nil.
+func tokenIndex(tok scanner.Token) int {
+	if tok == nil {
+		return 0
+	}
+	return tok.Index()
+}
+
+// PrintErrorJSON prints the [*ErrorReport] describing the given error to
+// the given [io.Writer] as a single line JSON object. For example:
+//
+//	{"kind":"unknown_option","program":"curl","message":"unknown option: --verbos",
+//	"option":"--verbos","token_index":1,"suggestions":["--verbose"]}
+//
+// (We wrapped the above line for readability.)
+//
+// This method panics in case of I/O error.
+func (fx *FlagSet) PrintErrorJSON(w io.Writer, err error) {
+	PrintJSON(w, NewErrorReport(fx.ProgramName, err))
+}
+
+// UsageReport is the JSON representation of the usage.
+type UsageReport struct {
+	// Program is the program name.
+	Program string `json:"program"`
+
+	// Description is the program description, if any.
+	Description string `json:"description,omitempty"`
+
+	// PositionalArguments is the usage string for the positional arguments, if any.
+	PositionalArguments string `json:"positional_arguments,omitempty"`
+
+	// Options contains the options.
+	Options []FlagUsage `json:"options"`
+
+	// GlobalOptions contains the options of the GlobalFlagSets, if any.
+	GlobalOptions []FlagUsage `json:"global_options,omitempty"`
+
+	// Examples contains the examples, if any.
+	Examples string `json:"examples,omitempty"`
+}
+
+// FlagUsage is the JSON representation of the usage of a [LongShortFlag].
+type FlagUsage struct {
+	// Short is the short flag including its prefix (e.g., `-v`), if any.
+	Short string `json:"short,omitempty"`
+
+	// Long is the long flag including its prefix (e.g., `--verbose`), if any.
+	Long string `json:"long,omitempty"`
+
+	// TakesArg is true if the flag takes an argument.
+	TakesArg bool `json:"takes_arg"`
+
+	// EnvVar is the environment variable bound to the flag, if any.
+	EnvVar string `json:"env_var,omitempty"`
+
+	// Usage is the usage string.
+	Usage string `json:"usage"`
+}
+
+// UsageReport returns the [*UsageReport] describing the usage printed by [*FlagSet.PrintUsage].
+func (fx *FlagSet) UsageReport() *UsageReport {
+	report := &UsageReport{
+		Program:     fx.ProgramName,
+		Description: fx.Description,
+		Options:     fx.FlagsUsageReport(),
+		Examples:    fx.Examples,
+	}
+	if minimum := fx.MinPositionalArgs; minimum >= 0 {
+		if maximum := fx.MaxPositionalArgs; maximum >= minimum {
+			report.PositionalArguments = fx.PositionalArgumentsUsage
+		}
+	}
+	for _, global := range fx.GlobalFlagSets {
+		report.GlobalOptions = append(report.GlobalOptions, global.FlagsUsageReport()...)
+	}
+	return report
+}
+
+// FlagsUsageReport returns the [FlagUsage] of each flag, in the same order used
+// by [*FlagSet.PrintFlagsUsage]. The return value is never nil.
+func (fx *FlagSet) FlagsUsageReport() []FlagUsage {
+	output := []FlagUsage{}
+	for _, pair := range fx.usageView {
+		entry := FlagUsage{TakesArg: pair.TakesArg, EnvVar: fx.EnvVarName(pair), Usage: pair.Usage}
+		if short := pair.ShortFlag; short != nil {
+			entry.Short = short.Option.Prefix + short.Option.Name
+		}
+		if long := pair.LongFlag; long != nil {
+			entry.Long = long.Option.Prefix + long.Option.Name
+		}
+		output = append(output, entry)
+	}
+	return output
+}
+
+// PrintUsageJSON prints the [*UsageReport] to the given [io.Writer]
+// as a single line JSON object.
+//
+// This method panics in case of I/O error.
+func (fx *FlagSet) PrintUsageJSON(w io.Writer) {
+	PrintJSON(w, fx.UsageReport())
+}
+
+// PrintJSON prints the given value to the given [io.Writer] as a single line
+// JSON object. We use this function to print [*ErrorReport] and [*UsageReport].
+// Unlike [json.Marshal], we do not escape HTML characters (e.g., `<`).
+//
+// This function panics in case of I/O error or if the value cannot be marshalled.
+func PrintJSON(w io.Writer, value any) {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	assert.NotError(encoder.Encode(value))
+}
@@ -0,0 +1,256 @@
+// output_test.go - Machine-readable output format tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package nflag
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOutputFormat(t *testing.T) {
+	for _, format := range []OutputFormat{OutputFormatText, OutputFormatJSON} {
+		parsed, err := ParseOutputFormat(format.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed != format {
+			t.Fatal("expected", format, "got", parsed)
+		}
+	}
+
+	if _, err := ParseOutputFormat("xml"); !errors.Is(err, ErrInvalidOutputFormat{Name: "xml"}) {
+		t.Fatal("unexpected error", err)
+	}
+	expect := "invalid output format: xml (expected text or json)"
+	if diff := cmp.Diff(expect, ErrInvalidOutputFormat{Name: "xml"}.Error()); diff != "" {
+		t.Fatal(diff)
+	}
+
+	if diff := cmp.Diff("OutputFormat(7)", OutputFormat(7).String()); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestNewErrorReport(t *testing.T) {
+	type testcase struct {
+		name    string
+		environ map[string]string
+		args    []string
+		expect  *ErrorReport
+	}
+
+	cases := []testcase{
+		{
+			name: "unknown option",
+			args: []string{"file.txt", "--verbos"},
+			expect: &ErrorReport{
+				Kind:        "unknown_option",
+				Program:     "tool",
+				Message:     "unknown option: --verbos",
+				Option:      "--verbos",
+				TokenIndex:  2,
+				Suggestions: []string{"--verbose"},
+			},
+		},
+
+		{
+			name: "option requiring an argument",
+			args: []string{"--count"},
+			expect: &ErrorReport{
+				Kind:       "option_requires_argument",
+				Program:    "tool",
+				Message:    "option requires an argument: --count",
+				Option:     "--count",
+				TokenIndex: 1,
+			},
+		},
+
+		{
+			name: "option requiring no argument",
+			args: []string{"--verbose=yes"},
+			expect: &ErrorReport{
+				Kind:       "option_requires_no_argument",
+				Program:    "tool",
+				Message:    "option requires no argument: --verbose",
+				Option:     "--verbose",
+				TokenIndex: 1,
+			},
+		},
+
+		{
+			name: "too few positional arguments",
+			args: []string{},
+			expect: &ErrorReport{
+				Kind:    "too_few_arguments",
+				Program: "tool",
+				Message: "too few positional arguments: expected at least 1, got 0",
+			},
+		},
+
+		{
+			name: "too many positional arguments",
+			args: []string{"a", "b", "c"},
+			expect: &ErrorReport{
+				Kind:    "too_many_arguments",
+				Program: "tool",
+				Message: "too many positional arguments: expected at most 2, got 3",
+			},
+		},
+
+		{
+			name: "invalid value",
+			args: []string{"file.txt", "-c", "x"},
+			expect: &ErrorReport{
+				Kind:       "invalid_value",
+				Program:    "tool",
+				Message:    `invalid value "x" for flag -c: strconv.ParseInt: parsing "x": invalid syntax`,
+				Option:     "-c",
+				Value:      "x",
+				TokenIndex: 2,
+			},
+		},
+
+		{
+			name:    "invalid environment variable",
+			environ: map[string]string{"TOOL_COUNT": "x"},
+			args:    []string{"file.txt"},
+			expect: &ErrorReport{
+				Kind:    "invalid_env_var",
+				Program: "tool",
+				Message: `invalid value "x" for environment variable TOOL_COUNT: strconv.ParseInt: parsing "x": invalid syntax`,
+				Value:   "x",
+				EnvVar:  "TOOL_COUNT",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fset := NewFlagSet("tool", ContinueOnError)
+			fset.EnvVarPrefix = "TOOL"
+			fset.LookupEnv = func(key string) (string, bool) {
+				value, found := tc.environ[key]
+				return value, found
+			}
+			fset.MinPositionalArgs = 1
+			fset.MaxPositionalArgs = 2
+			fset.Int64Flag("count", 'c', "Number of repetitions.")
+			fset.BoolFlag("verbose", 'v', "Run in verbose mode.")
+
+			err := fset.Parse(tc.args)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if diff := cmp.Diff(tc.expect, NewErrorReport(fset.ProgramName, err)); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	t.Run("other errors", func(t *testing.T) {
+		expect := &ErrorReport{Kind: "error", Program: "tool", Message: "mocked error"}
+		if diff := cmp.Diff(expect, NewErrorReport("tool", errors.New("mocked error"))); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+}
+
+func TestFlagSetUsageReport(t *testing.T) {
+	global := NewFlagSet("tool", ContinueOnError)
+	global.EnvVarPrefix = "TOOL"
+	global.StringFlag("config", 0, "Read the configuration from the given file.")
+
+	fset := NewFlagSet("tool sub", ContinueOnError)
+	fset.Description = "Run the subcommand."
+	fset.Examples = "Examples:\n  tool sub file.txt\n"
+	fset.GlobalFlagSets = []*FlagSet{global}
+	fset.PositionalArgumentsUsage = "file ..."
+	fset.AutoHelp("help", 'h', "Print this help message and exit.")
+	fset.Int64Flag("", 'c', "Number of repetitions.")
+
+	var sb strings.Builder
+	fset.PrintUsageJSON(&sb)
+	var report *UsageReport
+	if err := json.Unmarshal([]byte(sb.String()), &report); err != nil {
+		t.Fatal(err)
+	}
+	expect := &UsageReport{
+		Program:             "tool sub",
+		Description:         "Run the subcommand.",
+		PositionalArguments: "file ...",
+		Options: []FlagUsage{
+			{Short: "-h", Long: "--help", Usage: "Print this help message and exit."},
+			{Short: "-c", TakesArg: true, Usage: "Number of repetitions."},
+		},
+		GlobalOptions: []FlagUsage{
+			{Long: "--config", TakesArg: true, EnvVar: "TOOL_CONFIG", Usage: "Read the configuration from the given file."},
+		},
+		Examples: "Examples:\n  tool sub file.txt\n",
+	}
+	if diff := cmp.Diff(expect, report); diff != "" {
+		t.Fatal(diff)
+	}
+	if strings.Count(sb.String(), "\n") != 1 {
+		t.Fatal("expected a single line", sb.String())
+	}
+}
+
+func TestFlagSetOutputFormatJSON(t *testing.T) {
+	newFlagSet := func() (*FlagSet, *bytes.Buffer, *bytes.Buffer, *int) {
+		var stdout, stderr bytes.Buffer
+		status := -1
+		fset := NewFlagSet("tool", ExitOnError)
+		fset.OutputFormat = OutputFormatJSON
+		fset.Exit = func(code int) {
+			status = code
+		}
+		fset.Stderr = &stderr
+		fset.Stdout = &stdout
+		fset.AutoHelp("help", 'h', "Print this help message and exit.")
+		return fset, &stdout, &stderr, &status
+	}
+
+	t.Run("we print errors as JSON without the help hint", func(t *testing.T) {
+		fset, stdout, stderr, status := newFlagSet()
+		func() {
+			defer func() { recover() }()
+			fset.Parse([]string{"--hlep"})
+		}()
+		if *status != 2 {
+			t.Fatal("expected 2, got", *status)
+		}
+		expect := `{"kind":"unknown_option","program":"tool","message":"unknown option: --hlep",` +
+			`"option":"--hlep","token_index":1,"suggestions":["--help"]}` + "\n"
+		if diff := cmp.Diff(expect, stderr.String()); diff != "" {
+			t.Fatal(diff)
+		}
+		if stdout.Len() != 0 {
+			t.Fatal("expected empty stdout", stdout.String())
+		}
+	})
+
+	t.Run("we print the usage as JSON", func(t *testing.T) {
+		fset, stdout, stderr, status := newFlagSet()
+		func() {
+			defer func() { recover() }()
+			fset.Parse([]string{"--help"})
+		}()
+		if *status != 0 {
+			t.Fatal("expected 0, got", *status)
+		}
+		expect := `{"program":"tool","positional_arguments":"arg ...","options":` +
+			`[{"short":"-h","long":"--help","takes_arg":false,"usage":"Print this help message and exit."}]}` + "\n"
+		if diff := cmp.Diff(expect, stdout.String()); diff != "" {
+			t.Fatal(diff)
+		}
+		if stderr.Len() != 0 {
+			t.Fatal("expected empty stderr", stderr.String())
+		}
+	})
+}
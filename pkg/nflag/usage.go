@@ -7,10 +7,15 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/bassosimone/clip/internal/termstyle"
 	"github.com/bassosimone/clip/pkg/assert"
 	"github.com/bassosimone/textwrap"
 )
 
+// DefaultUsageWidth is the default number of columns at which
+// [*FlagSet.PrintUsage] wraps the descriptions.
+const DefaultUsageWidth = 72
+
 // PrintUsage prints the usage string to the given [io.Writer].
 //
 // The template we use is roughly this:
@@ -20,20 +25,27 @@ import (
 //	<description>
 //
 //	Options:
-//	  <shortPrefix><shortName>, <longPrefix><longName> <argument>
+//	  <shortPrefix><shortName>, <longPrefix><longName> <argument> [env: <name>]
+//	    <description>
+//
+//	Global options:
+//	  <shortPrefix><shortName>, <longPrefix><longName> <argument> [env: <name>]
 //	    <description>
 //
 //	<examples>
 //
 // We adapt it depending on the [*FlagSet] configuration. For example,
-// we don't print the separator if none is defined.
+// we don't print the separator if none is defined. We wrap the descriptions
+// using the UsageWidth and, when UsageColors is true, we style the headings,
+// the flag names, and the placeholders using ANSI escape sequences.
 //
 // This method panics in case of I/O error.
 func (fx *FlagSet) PrintUsage(w io.Writer) {
+	style := termstyle.Style(fx.UsageColors)
 
 	// construct the synopsis line
-	assert.NotError1(fmt.Fprintf(w, "Usage: %s", fx.ProgramName))
-	if len(fx.usageView) > 0 {
+	assert.NotError1(fmt.Fprintf(w, "%s %s", style.Heading("Usage:"), fx.ProgramName))
+	if len(fx.usageView) > 0 || len(fx.GlobalFlagSets) > 0 {
 		assert.NotError1(fmt.Fprint(w, " [options]"))
 	}
 	if minimum := fx.MinPositionalArgs; minimum >= 0 {
@@ -46,34 +58,24 @@ func (fx *FlagSet) PrintUsage(w io.Writer) {
 
 	// optionally print the description
 	if descr := fx.Description; descr != "" {
-		descr = textwrap.Do(descr, 72, "")
+		descr = textwrap.Do(descr, fx.usageWidth(), "")
 		assert.NotError1(fmt.Fprintf(w, "%s\n\n", descr))
 	}
 
 	// optionally print the options
 	if len(fx.usageView) > 0 {
-		assert.NotError1(fmt.Fprintf(w, "Options:\n"))
-		for _, pair := range fx.usageView {
-			long, short := pair.LongFlag, pair.ShortFlag
-			assert.NotError1(fmt.Fprint(w, "  "))
-			if short != nil {
-				assert.NotError1(fmt.Fprintf(w, "%s%s", short.Option.Prefix, short.Option.Name))
-			}
-			if short != nil && long != nil {
-				assert.NotError1(fmt.Fprint(w, ", "))
-			}
-			if long != nil {
-				assert.NotError1(fmt.Fprintf(w, "%s%s", long.Option.Prefix, long.Option.Name))
-			}
-			if pair.TakesArg {
-				space := map[bool]string{true: "=", false: " "}
-				assert.NotError1(fmt.Fprintf(w, "%sVALUE", space[long != nil]))
-			}
-			assert.NotError1(fmt.Fprintf(w, "\n"))
-			usage := textwrap.Do(pair.Usage, 72, "    ")
-			assert.NotError1(fmt.Fprint(w, usage))
-			assert.NotError1(fmt.Fprintf(w, "\n\n"))
+		assert.NotError1(fmt.Fprintf(w, "%s\n", style.Heading("Options:")))
+		fx.PrintFlagsUsage(w)
+	}
+
+	// optionally print the global options
+	var globals bool
+	for _, global := range fx.GlobalFlagSets {
+		if !globals && len(global.usageView) > 0 {
+			assert.NotError1(fmt.Fprintf(w, "%s\n", style.Heading("Global options:")))
+			globals = true
 		}
+		global.printFlagsUsage(w, fx.usageWidth(), style)
 	}
 
 	// optionally print the examples
@@ -82,6 +84,54 @@ func (fx *FlagSet) PrintUsage(w io.Writer) {
 	}
 }
 
+// PrintFlagsUsage prints the usage of each flag to the given [io.Writer]
+// using the same format of the "Options" section printed by [*FlagSet.PrintUsage],
+// but without any heading. Use this method to list the flags when
+// printing a custom usage message.
+//
+// This method panics in case of I/O error.
+func (fx *FlagSet) PrintFlagsUsage(w io.Writer) {
+	fx.printFlagsUsage(w, fx.usageWidth(), termstyle.Style(fx.UsageColors))
+}
+
+// printFlagsUsage is like [*FlagSet.PrintFlagsUsage] but uses the given width
+// and style, such that [*FlagSet.PrintUsage] formats the global flag sets
+// consistently with its own flags.
+func (fx *FlagSet) printFlagsUsage(w io.Writer, width int, style termstyle.Style) {
+	for _, pair := range fx.usageView {
+		long, short := pair.LongFlag, pair.ShortFlag
+		assert.NotError1(fmt.Fprint(w, "  "))
+		if short != nil {
+			assert.NotError1(fmt.Fprint(w, style.Flag(short.Option.Prefix+short.Option.Name)))
+		}
+		if short != nil && long != nil {
+			assert.NotError1(fmt.Fprint(w, ", "))
+		}
+		if long != nil {
+			assert.NotError1(fmt.Fprint(w, style.Flag(long.Option.Prefix+long.Option.Name)))
+		}
+		if pair.TakesArg {
+			space := map[bool]string{true: "=", false: " "}
+			assert.NotError1(fmt.Fprintf(w, "%s%s", space[long != nil], style.Placeholder("VALUE")))
+		}
+		if name := fx.EnvVarName(pair); name != "" {
+			assert.NotError1(fmt.Fprintf(w, " [env: %s]", name))
+		}
+		assert.NotError1(fmt.Fprintf(w, "\n"))
+		usage := textwrap.Do(pair.Usage, width, "    ")
+		assert.NotError1(fmt.Fprint(w, usage))
+		assert.NotError1(fmt.Fprintf(w, "\n\n"))
+	}
+}
+
+// usageWidth returns the UsageWidth or, when not positive, the [DefaultUsageWidth].
+func (fx *FlagSet) usageWidth() int {
+	if fx.UsageWidth <= 0 {
+		return DefaultUsageWidth
+	}
+	return fx.UsageWidth
+}
+
 // PrintHelpHint prints the help hint to the given [io.Writer].
 //
 // The template is roughly:
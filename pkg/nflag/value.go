@@ -18,3 +18,13 @@ type Value interface {
 	// String returns the string representation of the value.
 	String() string
 }
+
+// BoolValue is an optional extension of [Value] implemented by the values
+// of the boolean flags, which allows [*FlagSet.Set] and the environment
+// variables to set them to false as well as to true.
+type BoolValue interface {
+	Value
+
+	// SetBool sets the value to the given boolean.
+	SetBool(enabled bool)
+}
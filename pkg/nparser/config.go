@@ -5,8 +5,11 @@ package nparser
 
 import (
 	"fmt"
+	"slices"
+	"unicode/utf8"
 
 	"github.com/bassosimone/clip/pkg/scanner"
+	"github.com/bassosimone/clip/pkg/suggest"
 )
 
 // ErrAmbiguousPrefix indicates that the options contain ambiguous prefixes.
@@ -84,6 +87,12 @@ type ErrUnknownOption struct {
 	// Prefix is the prefix of the unknown option.
 	Prefix string
 
+	// Suggestions contains the configured options most similar to the
+	// unknown option, including their prefix (e.g., `--verbose`), sorted
+	// from the most to the least similar. It is empty when there are
+	// no sufficiently similar options.
+	Suggestions []string
+
 	// Token is the token of the unknown option.
 	Token scanner.Token
 }
@@ -187,8 +196,34 @@ func (cfg config) disablePermute() bool {
 func (cfg *config) findOption(tok scanner.OptionToken, optname string, kind OptionType) (*Option, error) {
 	option := cfg.options[optname]
 	if option == nil || option.Prefix != tok.Prefix || (option.Type&kind) == 0 {
-		err := ErrUnknownOption{Name: optname, Prefix: tok.Prefix, Token: tok}
+		err := ErrUnknownOption{
+			Name:        optname,
+			Prefix:      tok.Prefix,
+			Suggestions: cfg.suggestOptions(tok.Prefix, optname),
+			Token:       tok,
+		}
 		return nil, err
 	}
 	return option, nil
 }
+
+// suggestOptionsMaxDistance is the maximum edit distance between an
+// unknown option and the configured options we suggest instead.
+const suggestOptionsMaxDistance = 2
+
+// suggestOptionsMaxCount is the maximum number of suggested options.
+const suggestOptionsMaxCount = 3
+
+// suggestOptions returns the configured options most similar to the given
+// unknown option. We compare options including their prefix, such that
+// `-verbose` leads to suggesting `--verbose`. To avoid suggesting unrelated
+// single-letter options, we allow at most an edit every two letters of name.
+func (cfg *config) suggestOptions(prefix, name string) []string {
+	var candidates []string
+	for _, option := range cfg.options {
+		candidates = append(candidates, option.Prefix+option.Name)
+	}
+	slices.Sort(candidates)
+	maxDistance := min(suggestOptionsMaxDistance, utf8.RuneCountInString(name)/2)
+	return suggest.Suggest(prefix+name, candidates, maxDistance, suggestOptionsMaxCount)
+}
@@ -208,6 +208,75 @@ func Test_config_findOption(t *testing.T) {
 	}
 }
 
+func Test_config_suggestOptions(t *testing.T) {
+	// Create a parser with both long and short options
+	cfg := config{
+		options: map[string]*Option{
+			"verbose": {Prefix: "--", Name: "verbose", Type: OptionTypeStandaloneArgumentNone},
+			"version": {Prefix: "--", Name: "version", Type: OptionTypeStandaloneArgumentNone},
+			"output":  {Prefix: "--", Name: "output", Type: OptionTypeStandaloneArgumentRequired},
+			"v":       {Prefix: "-", Name: "v", Type: OptionTypeGroupableArgumentNone},
+		},
+	}
+
+	// Define the test cases
+	type testcase struct {
+		caseName string
+		tok      scanner.OptionToken
+		optName  string
+		expect   []string
+	}
+	cases := []testcase{
+		{
+			caseName: "transposed letters",
+			tok:      scanner.OptionToken{Idx: 1, Prefix: "--", Name: "verbsoe"},
+			optName:  "verbsoe",
+			expect:   []string{"--verbose"},
+		},
+
+		{
+			caseName: "multiple similar options",
+			tok:      scanner.OptionToken{Idx: 1, Prefix: "--", Name: "versoe"},
+			optName:  "versoe",
+			expect:   []string{"--verbose", "--version"},
+		},
+
+		{
+			caseName: "wrong prefix",
+			tok:      scanner.OptionToken{Idx: 1, Prefix: "-", Name: "output"},
+			optName:  "output",
+			expect:   []string{"--output"},
+		},
+
+		{
+			caseName: "no similar options",
+			tok:      scanner.OptionToken{Idx: 1, Prefix: "--", Name: "file"},
+			optName:  "file",
+			expect:   nil,
+		},
+
+		{
+			caseName: "no suggestions for single-letter options",
+			tok:      scanner.OptionToken{Idx: 1, Prefix: "-", Name: "x"},
+			optName:  "x",
+			expect:   nil,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			_, err := cfg.findOption(tc.tok, tc.optName, optionKindStandalone)
+			var errval ErrUnknownOption
+			if !errors.As(err, &errval) {
+				t.Fatalf("cannot convert error to ErrUnknownOption: %T", err)
+			}
+			if diff := cmp.Diff(tc.expect, errval.Suggestions); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
 func Test_newConfig(t *testing.T) {
 	// Define the structure of the test cases
 	type testcase struct {
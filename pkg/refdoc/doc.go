@@ -0,0 +1,21 @@
+// doc.go - package documentation.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+/*
+Package refdoc generates Markdown or HTML reference documentation for a
+[clip] command tree.
+
+The [*Generator] type walks the command tree and emits a page for each
+command, named after the command path (e.g., `minirbmk-git-clone.md`).
+Each page has an anchor and links to the pages of the parent command and
+of the subcommands. Each option also has an anchor.
+
+The pages reuse the metadata used when printing the usage, including the
+[*nflag.FlagSet] that leaf commands create using [clip.NewFlagSet]. We
+render the pages using a template, which you can override. The template
+receives a [*Command] describing the command to document.
+
+Use [*Generator.Generate] to obtain the pages in memory and
+[*Generator.WriteDir] to write them into a directory, e.g., from CI.
+*/
+package refdoc
@@ -0,0 +1,293 @@
+// refdoc.go - Markdown and HTML reference documentation generator.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package refdoc
+
+import (
+	"context"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/internal/doctree"
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// Format is the format of the generated pages.
+type Format int
+
+// These constants define the allowed [Format] values.
+const (
+	// Markdown causes [*Generator] to emit Markdown pages.
+	Markdown = Format(iota)
+
+	// HTML causes [*Generator] to emit HTML pages.
+	HTML
+)
+
+// Extension returns the file extension for the format including the dot.
+func (f Format) Extension() string {
+	switch f {
+	case HTML:
+		return ".html"
+	default:
+		return ".md"
+	}
+}
+
+// Page is a reference documentation page generated by [*Generator].
+type Page struct {
+	// Name is the page name (e.g., `minirbmk-git-clone`).
+	Name string
+
+	// Filename is the page file name (e.g., `minirbmk-git-clone.md`).
+	Filename string
+
+	// Content contains the page source.
+	Content string
+}
+
+// Command is the data passed to the template to render the page of a command.
+type Command struct {
+	// Anchor is the anchor identifying the command (e.g., `minirbmk-git-clone`).
+	Anchor string
+
+	// Brief is the brief description on a single line.
+	Brief string
+
+	// Commands contains the links to the subcommands sorted by name.
+	Commands []Link
+
+	// Groups contains the links to the subcommands grouped as in the usage.
+	Groups []Group
+
+	// Description contains the paragraphs of the description.
+	Description []string
+
+	// Examples contains the examples without heading and common indentation.
+	Examples string
+
+	// Name is the command name (e.g., `minirbmk git clone`).
+	Name string
+
+	// Options contains the command options.
+	Options []Option
+
+	// Parent is the link to the parent command or nil.
+	Parent *Link
+
+	// Synopsis is the command synopsis including the command name.
+	Synopsis string
+}
+
+// Group is a named group of subcommands.
+type Group struct {
+	// Title is the group title or empty when the command does not group its subcommands.
+	Title string
+
+	// Commands contains the links to the subcommands in the group.
+	Commands []Link
+}
+
+// Link is a link to the page of another command.
+type Link struct {
+	// Brief is the brief description on a single line.
+	Brief string
+
+	// Href is the link target (e.g., `minirbmk-git-clone.md`).
+	Href string
+
+	// Name is the last word of the command name (e.g., `clone`).
+	Name string
+
+	// Path is the command name (e.g., `minirbmk git clone`).
+	Path string
+}
+
+// Option is a command option.
+type Option struct {
+	// Anchor is the anchor identifying the option (e.g., `minirbmk-git-clone-branch`).
+	Anchor string
+
+	// Names contains the option names (e.g., `-b, --branch=VALUE`).
+	Names string
+
+	// Usage is the usage string on a single line.
+	Usage string
+}
+
+// Generator generates a reference page for each command of a command tree.
+//
+// We traverse the tree using [clip.Walk]. The pages use the same metadata
+// used when printing the usage: brief descriptions and subcommands for each
+// command implementing [clip.CommandWithSubcommands], and the [*nflag.FlagSet]
+// created through [clip.NewFlagSet], which we obtain using
+// [clip.InspectFlagSet], for the other commands. Commands not
+// implementing [clip.InspectableCommand] only get a minimal page.
+//
+// The zero value is not ready to use. Initialize the mandatory fields.
+type Generator[T clip.ExecEnv] struct {
+	// --- mandatory fields ---
+
+	// Command is the mandatory root of the command tree.
+	Command clip.Command[T]
+
+	// Env is the mandatory [clip.ExecEnv] to use when inspecting commands.
+	Env T
+
+	// ProgramName is the mandatory name of the program (e.g., `minirbmk`).
+	ProgramName string
+
+	// --- optional fields ---
+
+	// Format is the optional page [Format]. The default is [Markdown].
+	Format Format
+
+	// Template optionally overrides the default template for Format. We
+	// execute the template with a [*Command] argument, and we parse it
+	// using [html/template] for [HTML] and [text/template] otherwise.
+	Template string
+}
+
+// Generate walks the command tree and returns the pages.
+//
+// The first page is the one of the root command. The other pages follow
+// in depth-first order, visiting subcommands sorted by name.
+func (g *Generator[T]) Generate(ctx context.Context) ([]*Page, error) {
+	tmpl, err := g.parse()
+	if err != nil {
+		return nil, err
+	}
+	nodes := doctree.Collect(ctx, g.Env, g.ProgramName, g.Command)
+	pages := make([]*Page, 0, len(nodes))
+	for _, nx := range nodes {
+		var sb strings.Builder
+		if err := tmpl.Execute(&sb, g.newCommand(nx)); err != nil {
+			return nil, err
+		}
+		pages = append(pages, &Page{
+			Name:     nx.PageName(),
+			Filename: nx.PageName() + g.Format.Extension(),
+			Content:  sb.String(),
+		})
+	}
+	return pages, nil
+}
+
+// WriteDir generates the pages and writes them into the given
+// directory, which must exist, using [*Page.Filename] as the file names.
+func (g *Generator[T]) WriteDir(ctx context.Context, dir string) error {
+	pages, err := g.Generate(ctx)
+	if err != nil {
+		return err
+	}
+	for _, page := range pages {
+		filename := filepath.Join(dir, page.Filename)
+		if err := os.WriteFile(filename, []byte(page.Content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// executor abstracts over [html/template] and [text/template].
+type executor interface {
+	Execute(w io.Writer, data any) error
+}
+
+// parse parses the template to use.
+func (g *Generator[T]) parse() (executor, error) {
+	switch g.Format {
+	case HTML:
+		source := g.Template
+		if source == "" {
+			source = htmlTemplate
+		}
+		return htmltemplate.New("refdoc").Parse(source)
+
+	default:
+		source := g.Template
+		if source == "" {
+			source = markdownTemplate
+		}
+		funcs := texttemplate.FuncMap{"cell": markdownCell}
+		return texttemplate.New("refdoc").Funcs(funcs).Parse(source)
+	}
+}
+
+// newCommand creates the [*Command] describing the given node.
+func (g *Generator[T]) newCommand(nx *doctree.Node[T]) *Command {
+	cmd := &Command{
+		Anchor:   nx.PageName(),
+		Brief:    doctree.OneLine(nx.Command.BriefDescription()),
+		Examples: nx.Examples(),
+		Name:     nx.Name(),
+		Synopsis: strings.TrimSpace(nx.Name() + " " + nx.Synopsis()),
+	}
+	for _, paragraph := range strings.Split(strings.TrimSpace(nx.Description()), "\n\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			cmd.Description = append(cmd.Description, paragraph)
+		}
+	}
+	if nx.Parent != nil {
+		link := g.newLink(nx.Parent)
+		cmd.Parent = &link
+	}
+	for _, child := range nx.Children {
+		cmd.Commands = append(cmd.Commands, g.newLink(child))
+	}
+	for _, group := range nx.Groups() {
+		gr := Group{Title: group.Title}
+		for _, child := range group.Children {
+			gr.Commands = append(gr.Commands, g.newLink(child))
+		}
+		cmd.Groups = append(cmd.Groups, gr)
+	}
+	for _, pair := range nx.Flags() {
+		cmd.Options = append(cmd.Options, newOption(nx.PageName(), pair))
+	}
+	return cmd
+}
+
+// newLink creates the [Link] pointing to the given node.
+func (g *Generator[T]) newLink(nx *doctree.Node[T]) Link {
+	return Link{
+		Brief: doctree.OneLine(nx.Command.BriefDescription()),
+		Href:  nx.PageName() + g.Format.Extension(),
+		Name:  nx.Path[len(nx.Path)-1],
+		Path:  nx.Name(),
+	}
+}
+
+// newOption creates the [Option] describing the given flags. The anchor
+// uses the long flag name, if available, and otherwise the short one.
+func newOption(anchor string, pair nflag.LongShortFlag) Option {
+	var names []string
+	for _, flag := range []*nflag.Flag{pair.ShortFlag, pair.LongFlag} {
+		if flag != nil {
+			names = append(names, flag.Option.Prefix+flag.Option.Name)
+		}
+	}
+	name := ""
+	switch {
+	case pair.LongFlag != nil:
+		name = pair.LongFlag.Option.Name
+	case pair.ShortFlag != nil:
+		name = pair.ShortFlag.Option.Name
+	}
+	text := strings.Join(names, ", ")
+	if pair.TakesArg {
+		separator := map[bool]string{true: "=", false: " "}[pair.LongFlag != nil]
+		text += separator + "VALUE"
+	}
+	return Option{Anchor: anchor + "-" + name, Names: text, Usage: doctree.OneLine(pair.Usage)}
+}
+
+// markdownCell escapes the given text for use inside a Markdown table cell.
+func markdownCell(text string) string {
+	return strings.ReplaceAll(text, "|", `\|`)
+}
@@ -0,0 +1,217 @@
+// refdoc_test.go - Markdown and HTML reference documentation generator tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package refdoc
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bassosimone/clip"
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// newTestGenerator creates the [*Generator] used by these tests.
+func newTestGenerator(format Format) *Generator[*clip.StdlibExecEnv] {
+	clone := &clip.LeafCommand[*clip.StdlibExecEnv]{
+		BriefDescriptionText: "Clone a repository.",
+		LongDescriptionText:  "Clone a repository into a new directory.\n\nUse <repository> | <directory>.",
+		RunFunc: func(ctx context.Context, args *clip.CommandArgs[*clip.StdlibExecEnv]) error {
+			fset := clip.NewFlagSet(args, nflag.ContinueOnError)
+			fset.PositionalArgumentsUsage = "<repository> [directory]"
+			fset.Examples = "Examples:\n  minirbmk git clone -b main https://example.com/repo\n"
+			fset.StringFlag("branch", 'b', "Branch name.")
+			fset.BoolFlag("", 'q', "Run in quiet mode.")
+			return fset.Parse(args.Args)
+		},
+		UsesNewFlagSet: true,
+	}
+	legacy := &clip.LeafCommand[*clip.StdlibExecEnv]{
+		BriefDescriptionText: "Command not using NewFlagSet.",
+		RunFunc: func(ctx context.Context, args *clip.CommandArgs[*clip.StdlibExecEnv]) error {
+			fset := nflag.NewFlagSet(args.CommandName, nflag.ExitOnError)
+			fset.Exit = args.Env.Exit
+			fset.Stdout = args.Env.Stdout()
+			return fset.Parse(args.Args)
+		},
+	}
+	env := clip.NewStdlibExecEnv()
+	env.OSExit = func(exitcode int) {
+		panic(fmt.Sprintf("unexpected exit with status %d", exitcode))
+	}
+	return &Generator[*clip.StdlibExecEnv]{
+		Command: &clip.DispatcherCommand[*clip.StdlibExecEnv]{
+			BriefDescriptionText: "A collection of tools.",
+			Commands: map[string]clip.Command[*clip.StdlibExecEnv]{
+				"git": &clip.DispatcherCommand[*clip.StdlibExecEnv]{
+					BriefDescriptionText: "Utility to manage | repositories.",
+					Commands: map[string]clip.Command[*clip.StdlibExecEnv]{
+						"clone": clone,
+					},
+				},
+				"legacy": legacy,
+			},
+			Groups: []clip.CommandGroup{{Title: "Core commands", Commands: []string{"git"}}},
+		},
+		Env:         env,
+		Format:      format,
+		ProgramName: "minirbmk",
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	type testcase struct {
+		name   string
+		format Format
+		page   int
+		expect []string
+	}
+
+	cases := []testcase{
+		{
+			name:   "Markdown root page",
+			format: Markdown,
+			page:   0,
+			expect: []string{
+				"<a id=\"minirbmk\"></a>\n\n# minirbmk\n",
+				"```\nminirbmk [command] [args]\n```\n",
+				"## Commands\n\n### Core commands\n\n| Command | Description |\n",
+				"| [git](minirbmk-git.md) | Utility to manage \\| repositories. |\n",
+				"## See also\n\n- [minirbmk git](minirbmk-git.md) - Utility to manage | repositories.\n",
+			},
+		},
+
+		{
+			name:   "Markdown leaf page",
+			format: Markdown,
+			page:   2,
+			expect: []string{
+				"```\nminirbmk git clone [options] <repository> [directory]\n```\n",
+				"Clone a repository into a new directory.\n\nUse <repository> | <directory>.\n",
+				"<a id=\"minirbmk-git-clone-branch\"></a>\n`-b, --branch=VALUE`\n\nBranch name.\n",
+				"<a id=\"minirbmk-git-clone-q\"></a>\n`-q`\n",
+				"```\nminirbmk git clone -b main https://example.com/repo\n```\n",
+				"- [minirbmk git](minirbmk-git.md) - Utility to manage | repositories.\n",
+			},
+		},
+
+		{
+			name:   "Markdown page without groups",
+			format: Markdown,
+			page:   1,
+			expect: []string{
+				"## Commands\n\n| Command | Description |\n",
+			},
+		},
+
+		{
+			name:   "Markdown page of a command not using NewFlagSet",
+			format: Markdown,
+			page:   3,
+			expect: []string{
+				"```\nminirbmk legacy [args]\n```\n",
+				"Command not using NewFlagSet.\n",
+			},
+		},
+
+		{
+			name:   "HTML root page",
+			format: HTML,
+			page:   0,
+			expect: []string{
+				"<h2>Commands</h2>\n<h3>Core commands</h3>\n<table>\n",
+			},
+		},
+
+		{
+			name:   "HTML leaf page",
+			format: HTML,
+			page:   2,
+			expect: []string{
+				"<h1 id=\"minirbmk-git-clone\">minirbmk git clone</h1>\n",
+				"<pre><code>minirbmk git clone [options] &lt;repository&gt; [directory]</code></pre>\n",
+				"<dt id=\"minirbmk-git-clone-branch\"><code>-b, --branch=VALUE</code></dt>\n",
+				"<li><a href=\"minirbmk-git.html\">minirbmk git</a> - Utility to manage | repositories.</li>\n",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			pages, err := newTestGenerator(tc.format).Generate(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pages) != 4 {
+				t.Fatalf("expected 4 pages, got %d", len(pages))
+			}
+			content := pages[tc.page].Content
+			for _, expect := range tc.expect {
+				if !strings.Contains(content, expect) {
+					t.Errorf("expected %q inside %q", expect, content)
+				}
+			}
+		})
+	}
+
+	t.Run("with custom template", func(t *testing.T) {
+		g := newTestGenerator(Markdown)
+		g.Template = "{{.Name}}:{{range .Options}} {{.Names}}{{end}}"
+		pages, err := g.Generate(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := pages[2].Content; got != "minirbmk git clone: -b, --branch=VALUE -q" {
+			t.Fatalf("unexpected content: %q", got)
+		}
+	})
+
+	t.Run("with invalid template", func(t *testing.T) {
+		g := newTestGenerator(HTML)
+		g.Template = "{{.Name"
+		if _, err := g.Generate(context.Background()); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("with failing template", func(t *testing.T) {
+		g := newTestGenerator(Markdown)
+		g.Template = "{{.Nonexistent}}"
+		if _, err := g.Generate(context.Background()); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestGeneratorWriteDir(t *testing.T) {
+	t.Run("on success", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := newTestGenerator(HTML).WriteDir(context.Background(), dir); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"minirbmk.html", "minirbmk-git.html", "minirbmk-git-clone.html"} {
+			if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+
+	t.Run("on template failure", func(t *testing.T) {
+		g := newTestGenerator(Markdown)
+		g.Template = "{{.Name"
+		if err := g.WriteDir(context.Background(), t.TempDir()); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("on write failure", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "nonexistent")
+		if err := newTestGenerator(Markdown).WriteDir(context.Background(), dir); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
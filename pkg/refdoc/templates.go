@@ -0,0 +1,98 @@
+// templates.go - default templates.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package refdoc
+
+// markdownTemplate is the default template for [Markdown].
+const markdownTemplate = `<a id="{{.Anchor}}"></a>
+
+# {{.Name}}
+
+{{.Brief}}
+
+## Synopsis
+
+` + "```" + `
+{{.Synopsis}}
+` + "```" + `
+
+## Description
+{{range .Description}}
+{{.}}
+{{end}}
+{{- if .Commands}}
+## Commands
+{{range .Groups}}{{if .Title}}
+### {{.Title}}
+{{end}}
+| Command | Description |
+| --- | --- |
+{{range .Commands}}| [{{.Name}}]({{.Href}}) | {{cell .Brief}} |
+{{end}}{{end}}{{end}}
+{{- if .Options}}
+## Options
+{{range .Options}}
+<a id="{{.Anchor}}"></a>
+` + "`{{.Names}}`" + `
+
+{{.Usage}}
+{{end}}{{end}}
+{{- if .Examples}}
+## Examples
+
+` + "```" + `
+{{.Examples}}
+` + "```" + `
+{{end}}
+{{- if or .Parent .Commands}}
+## See also
+{{with .Parent}}
+- [{{.Path}}]({{.Href}}) - {{.Brief}}
+{{- end}}
+{{- range .Commands}}
+- [{{.Path}}]({{.Href}}) - {{.Brief}}
+{{- end}}
+{{end}}`
+
+// htmlTemplate is the default template for [HTML].
+const htmlTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}</title>
+</head>
+<body>
+<h1 id="{{.Anchor}}">{{.Name}}</h1>
+<p>{{.Brief}}</p>
+<h2>Synopsis</h2>
+<pre><code>{{.Synopsis}}</code></pre>
+<h2>Description</h2>
+{{range .Description}}<p>{{.}}</p>
+{{end}}
+{{- if .Commands}}<h2>Commands</h2>
+{{range .Groups}}{{if .Title}}<h3>{{.Title}}</h3>
+{{end}}<table>
+<tr><th>Command</th><th>Description</th></tr>
+{{range .Commands}}<tr><td><a href="{{.Href}}">{{.Name}}</a></td><td>{{.Brief}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}
+{{- if .Options}}<h2>Options</h2>
+<dl>
+{{range .Options}}<dt id="{{.Anchor}}"><code>{{.Names}}</code></dt>
+<dd>{{.Usage}}</dd>
+{{end}}</dl>
+{{end}}
+{{- if .Examples}}<h2>Examples</h2>
+<pre><code>{{.Examples}}</code></pre>
+{{end}}
+{{- if or .Parent .Commands}}<h2>See also</h2>
+<ul>
+{{with .Parent}}<li><a href="{{.Href}}">{{.Path}}</a> - {{.Brief}}</li>
+{{end}}
+{{- range .Commands}}<li><a href="{{.Href}}">{{.Path}}</a> - {{.Brief}}</li>
+{{end}}</ul>
+{{end}}
+{{- "" -}}
+</body>
+</html>
+`
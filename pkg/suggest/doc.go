@@ -0,0 +1,10 @@
+// doc.go - package documentation.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+/*
+Package suggest implements "did you mean?" suggestions.
+
+Use [Suggest] to select, among the valid names (e.g., subcommands or
+flags), the ones closest to a misspelled name according to [Distance].
+*/
+package suggest
@@ -0,0 +1,67 @@
+// suggest.go - Suggestions based on the edit distance.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package suggest
+
+import "sort"
+
+// Distance returns the edit distance between a and b, i.e., the minimum
+// number of rune insertions, deletions, substitutions, and transpositions
+// of adjacent runes required to transform a into b.
+//
+// We compute the optimal string alignment distance, where each substring
+// is edited at most once, so that `gti` is at distance one from `git`.
+func Distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	// Use three rows of the classic dynamic programming matrix
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				curr[j] = min(curr[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+	return prev[len(rb)]
+}
+
+// Suggest returns the candidates whose [Distance] from word is at most
+// maxDistance, sorted by distance and then by name, keeping at most
+// maxCount of them. We never suggest a candidate equal to word.
+func Suggest(word string, candidates []string, maxDistance, maxCount int) []string {
+	type entry struct {
+		distance int
+		name     string
+	}
+	var entries []entry
+	for _, candidate := range candidates {
+		if distance := Distance(word, candidate); distance > 0 && distance <= maxDistance {
+			entries = append(entries, entry{distance, candidate})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].distance != entries[j].distance {
+			return entries[i].distance < entries[j].distance
+		}
+		return entries[i].name < entries[j].name
+	})
+	var output []string
+	for idx := 0; idx < len(entries) && idx < maxCount; idx++ {
+		output = append(output, entries[idx].name)
+	}
+	return output
+}
@@ -0,0 +1,69 @@
+// suggest_test.go - Suggestions based on the edit distance tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package suggest
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	type testcase struct {
+		a, b   string
+		expect int
+	}
+
+	cases := []testcase{
+		{"", "", 0},
+		{"git", "", 3},
+		{"", "git", 3},
+		{"git", "git", 0},
+		{"gti", "git", 1},
+		{"gt", "git", 1},
+		{"gitt", "git", 1},
+		{"got", "git", 1},
+		{"clnoe", "clone", 1},
+		{"kitten", "sitting", 3},
+		{"ça", "ca", 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.a+"/"+tc.b, func(t *testing.T) {
+			if got := Distance(tc.a, tc.b); got != tc.expect {
+				t.Fatalf("expected %d, got %d", tc.expect, got)
+			}
+		})
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"curl", "dig", "git", "help", "version", "gist"}
+
+	type testcase struct {
+		name        string
+		word        string
+		maxDistance int
+		maxCount    int
+		expect      []string
+	}
+
+	cases := []testcase{
+		{"closest first", "gti", 3, 5, []string{"git", "dig", "gist"}},
+		{"same distance sorted by name", "gsit", 1, 5, []string{"gist", "git"}},
+		{"limited count", "gti", 3, 1, []string{"git"}},
+		{"limited distance", "gti", 1, 5, []string{"git"}},
+		{"no match", "nonexistent", 2, 5, nil},
+		{"exact match is not suggested", "git", 2, 5, []string{"gist", "dig"}},
+		{"zero count", "gti", 2, 0, nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Suggest(tc.word, candidates, tc.maxDistance, tc.maxCount)
+			if !slices.Equal(got, tc.expect) {
+				t.Fatalf("expected %v, got %v", tc.expect, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+// doc.go - package documentation.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+/*
+Package txtar implements the txtar archive format.
+
+A txtar archive is a plain text file containing a comment followed by
+zero or more files. Each file starts with a marker line containing the
+file name, and its content extends until the next marker line:
+
+	This is the comment.
+	-- hello.txt --
+	Hello, world!
+	-- dir/config.toml --
+	[git.clone]
+	branch = "main"
+
+The format is the one used by the Go toolchain for its tests. Because it
+is human readable and easy to edit, we use it to describe the files used
+by the scripted tests (see [github.com/bassosimone/clip/cliptest/testscript]).
+
+Use [Parse] to parse an [*Archive] and [Format] to serialize it.
+*/
+package txtar
@@ -0,0 +1,97 @@
+// txtar.go - txtar archives.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package txtar
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Archive is a txtar archive.
+type Archive struct {
+	// Comment is the text preceding the first file.
+	Comment []byte
+
+	// Files contains the files in the archive.
+	Files []File
+}
+
+// File is a file inside an [*Archive].
+type File struct {
+	// Name is the file name.
+	Name string
+
+	// Data is the file content.
+	Data []byte
+}
+
+// Parse parses the given data as an [*Archive]. Parsing never fails, since
+// any data is a valid archive, possibly consisting only of a comment. We
+// terminate the comment and each file with a newline, if missing.
+func Parse(data []byte) *Archive {
+	archive := &Archive{}
+	var name string
+	archive.Comment, name, data = nextFile(data)
+	for name != "" {
+		file := File{Name: name}
+		file.Data, name, data = nextFile(data)
+		archive.Files = append(archive.Files, file)
+	}
+	return archive
+}
+
+// Format returns the serialized archive. Because we terminate the comment
+// and each file with a newline, if missing, parsing the returned data
+// yields an [*Archive] equal to the given one, provided that the content
+// of the files does not contain file markers.
+func Format(archive *Archive) []byte {
+	var buf bytes.Buffer
+	buf.Write(withNewline(archive.Comment))
+	for _, file := range archive.Files {
+		buf.WriteString("-- " + file.Name + " --\n")
+		buf.Write(withNewline(file.Data))
+	}
+	return buf.Bytes()
+}
+
+// nextFile returns the data before the next file marker, the name
+// of the next file, and the data after the marker. The name is
+// empty when there are no more file markers.
+func nextFile(data []byte) (before []byte, name string, after []byte) {
+	var offset int
+	for offset < len(data) {
+		line := data[offset:]
+		end := bytes.IndexByte(line, '\n')
+		if end >= 0 {
+			line = line[:end+1]
+		}
+		if name, found := parseMarker(line); found {
+			return withNewline(data[:offset]), name, data[offset+len(line):]
+		}
+		offset += len(line)
+	}
+	return withNewline(data), "", nil
+}
+
+// parseMarker returns the file name if the given line is a file marker.
+func parseMarker(line []byte) (string, bool) {
+	text := strings.TrimRight(string(line), "\r\n")
+	if !strings.HasPrefix(text, "-- ") || !strings.HasSuffix(text, " --") || len(text) < len("-- x --") {
+		return "", false
+	}
+	name := strings.TrimSpace(text[len("-- ") : len(text)-len(" --")])
+	return name, name != ""
+}
+
+// withNewline returns the data terminated by a newline, or nil if it is empty.
+func withNewline(data []byte) []byte {
+	switch {
+	case len(data) <= 0:
+		return nil
+	case data[len(data)-1] != '\n':
+		return append(bytes.Clone(data), '\n')
+	default:
+		return data
+	}
+}
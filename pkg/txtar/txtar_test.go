@@ -0,0 +1,99 @@
+// txtar_test.go - txtar archives tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package txtar
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParse(t *testing.T) {
+	type testcase struct {
+		name   string
+		input  string
+		expect *Archive
+	}
+
+	cases := []testcase{
+		{
+			name:   "empty archive",
+			input:  "",
+			expect: &Archive{},
+		},
+
+		{
+			name:   "comment only",
+			input:  "exec prog --help\nstdout Usage:",
+			expect: &Archive{Comment: []byte("exec prog --help\nstdout Usage:\n")},
+		},
+
+		{
+			name: "comment and files",
+			input: "# comment\n" +
+				"-- hello.txt --\n" +
+				"Hello, world!\n" +
+				"-- dir/empty.txt --\n" +
+				"--  spaced.txt  --\r\n" +
+				"no newline",
+			expect: &Archive{
+				Comment: []byte("# comment\n"),
+				Files: []File{
+					{Name: "hello.txt", Data: []byte("Hello, world!\n")},
+					{Name: "dir/empty.txt", Data: nil},
+					{Name: "spaced.txt", Data: []byte("no newline\n")},
+				},
+			},
+		},
+
+		{
+			name: "we ignore invalid markers",
+			input: "-- --\n" +
+				"--x --\n" +
+				"-- a.txt\n" +
+				"-- b.txt --\n" +
+				" -- c.txt --\n",
+			expect: &Archive{
+				Comment: []byte("-- --\n--x --\n-- a.txt\n"),
+				Files: []File{
+					{Name: "b.txt", Data: []byte(" -- c.txt --\n")},
+				},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			archive := Parse([]byte(tc.input))
+			if diff := cmp.Diff(tc.expect, archive); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	archive := &Archive{
+		Comment: []byte("# comment"),
+		Files: []File{
+			{Name: "hello.txt", Data: []byte("Hello, world!")},
+			{Name: "empty.txt", Data: nil},
+		},
+	}
+	data := Format(archive)
+	expect := "# comment\n-- hello.txt --\nHello, world!\n-- empty.txt --\n"
+	if diff := cmp.Diff(expect, string(data)); diff != "" {
+		t.Fatal(diff)
+	}
+	roundtrip := &Archive{
+		Comment: []byte("# comment\n"),
+		Files: []File{
+			{Name: "hello.txt", Data: []byte("Hello, world!\n")},
+			{Name: "empty.txt", Data: nil},
+		},
+	}
+	if diff := cmp.Diff(roundtrip, Parse(data)); diff != "" {
+		t.Fatal(diff)
+	}
+}
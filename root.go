@@ -5,12 +5,20 @@ package clip
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/bassosimone/clip/pkg/assert"
+	"github.com/bassosimone/clip/pkg/nflag"
 )
 
 // RootCommand is the root [Command] of the application.
 //
+// When the command fails, Main prints the error on the standard error, prefixed
+// by the program name, unless the error does not need printing (e.g., because
+// the [*DispatcherCommand] has already printed it), and exits using the
+// status returned by [ExitCode]. Return an [*ExitError] to control the status.
+//
 // The zero value is not ready to use. Initialize the mandatory fields.
 type RootCommand[T ExecEnv] struct {
 	// --- mandatory fields ---
@@ -23,24 +31,67 @@ type RootCommand[T ExecEnv] struct {
 
 	// AutoCancel optionally cancels the command if the user interrupts
 	// its execution using signals (e.g., SIGINT, SIGTERM).
+	//
+	// The cause of the cancellation is a [*SignalError] containing the
+	// signal, which commands may obtain using [context.Cause]. When the
+	// command fails with an error wrapping [context.Canceled], we exit
+	// using the status returned by the [*SignalError], unless the error
+	// already carries an exit status (see [ExitCoder]).
+	//
+	// When the command does not terminate after the first signal, a second
+	// signal, or the expiry of the GracePeriod, forces the program to exit
+	// with the status indicating that the signal terminated it (e.g., 130
+	// for SIGINT and 143 for SIGTERM, see [ExitSignalBase]).
 	AutoCancel bool
+
+	// GracePeriod optionally limits the time that AutoCancel waits for the
+	// command to terminate after the first signal. When zero, we wait until
+	// the command terminates or the user sends a second signal.
+	GracePeriod time.Duration
+
+	// InterruptMessage optionally contains the message that AutoCancel prints on
+	// the standard error, prefixed by the program name, when receiving the first
+	// signal (e.g., "interrupted, press Ctrl-C again to force"). When printing
+	// JSON, we use the "interrupted" kind (see [nflag.ErrorReport]).
+	InterruptMessage string
+
+	// Middleware optionally contains [Middleware] wrapping the Command or,
+	// when the Command is a [*DispatcherCommand], the commands below it,
+	// with the first entry being the outermost.
+	Middleware []Middleware[T]
+
+	// OutputFormat optionally selects the default [nflag.OutputFormat] used
+	// to print errors and usage. With [nflag.OutputFormatJSON], we print each
+	// error on the standard error as a single line JSON [nflag.ErrorReport]
+	// and the usage on the standard output as a JSON object. We pass the
+	// output format to the commands using the OutputFormat field of
+	// [CommandArgs], and [NewFlagSet] honours it.
+	OutputFormat nflag.OutputFormat
+
+	// OutputFormatEnvVar optionally contains the name of the environment
+	// variable (e.g., `MINIRBMK_OUTPUT_FORMAT`) overriding OutputFormat, whose
+	// value must be either `text` or `json` (see [nflag.ParseOutputFormat]).
+	OutputFormatEnvVar string
+
+	// OutputFormatFlag optionally enables the `--output-format FORMAT` flag,
+	// overriding OutputFormatEnvVar, which the user may specify anywhere before
+	// the `--` separator. Like for the PersistentFlags of [*DispatcherCommand],
+	// we remove the flag and its value from the command line, we list the
+	// flag in the "Global options" section of the usage, and the `__complete`
+	// command (see [*CompleteCommand]) completes the flag and its values.
+	OutputFormatFlag bool
+
+	// UsageColors optionally allows styling the usage using ANSI colors when
+	// the [Terminal] returned by [DetectTerminal] supports them. We pass this
+	// setting to the commands using the UsageColors field of [CommandArgs].
+	UsageColors bool
 }
 
 // Main is the root command entry point.
 func (rx *RootCommand[T]) Main(env T) {
 	// start with creating a cancellable context
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// possibly allow interrupting the command
-	if rx.AutoCancel {
-		sch := make(chan Signal, 1)
-		env.SignalNotify(sch, interruptSignals...)
-		go func() {
-			defer cancel()
-			<-sch
-		}()
-	}
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
 
 	// create the command arguments
 	argv := env.Args()
@@ -50,10 +101,124 @@ func (rx *RootCommand[T]) Main(env T) {
 		Args:        argv[1:],
 		Command:     rx.Command,
 		CommandName: argv[0],
+		Middleware:  rx.Middleware,
 		Parent:      nil,
+		UsageColors: rx.UsageColors,
+		root:        rx.Command,
 	}
 
-	// run the command
+	// select the output format, possibly allow interrupting
+	// the command, and run the command
 	assert.True(rx.Command != nil, "the command to execute is required")
-	Must(env, rx.Command.Run(ctx, args))
+	err := rx.parseOutputFormat(args)
+	if err == nil && rx.AutoCancel {
+		sch := make(chan Signal, 2) // room for the forcing signal
+		env.SignalNotify(sch, interruptSignals...)
+		done := make(chan struct{})
+		defer close(done)
+		go rx.handleSignals(env, args.CommandName, args.OutputFormat, sch, cancel, done)
+	}
+	if err == nil {
+		err = runCommand(ctx, rx.Command, args)
+	}
+	err = signalExitError(ctx, err)
+
+	// report the error and exit
+	if !isReportedError(err) {
+		printError(env.Stderr(), args.OutputFormat, args.CommandName, err)
+	}
+	Must(env, err)
+}
+
+// handleSignals implements AutoCancel by canceling the context when receiving the
+// first signal from sch and by exiting when receiving the second signal or when
+// the GracePeriod expires. We print the InterruptMessage using the given format.
+// We stop handling signals when done is closed.
+func (rx *RootCommand[T]) handleSignals(env T, progname string, format nflag.OutputFormat,
+	sch <-chan Signal, cancel context.CancelCauseFunc, done <-chan struct{}) {
+	// wait for the first signal and cancel the context
+	var sig Signal
+	select {
+	case sig = <-sch:
+	case <-done:
+		return
+	}
+	cancel(&SignalError{Signal: sig})
+	if rx.InterruptMessage != "" {
+		printInterruptMessage(env.Stderr(), format, progname, rx.InterruptMessage)
+	}
+
+	// possibly start the grace period timer
+	var expired <-chan time.Time
+	if rx.GracePeriod > 0 {
+		timer := time.NewTimer(rx.GracePeriod)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	// wait for the second signal or the timer and force exiting
+	select {
+	case sig = <-sch:
+	case <-expired:
+	case <-done:
+		return
+	}
+	env.Exit((&SignalError{Signal: sig}).ExitCode())
+}
+
+// signalExitError wraps the given error using an [*ExitError] with the status
+// returned by the [*SignalError] that canceled the context, if any, when the
+// error wraps [context.Canceled] and does not already carry an exit status.
+func signalExitError(ctx context.Context, err error) error {
+	var sigerr *SignalError
+	if !errors.Is(err, context.Canceled) || errorsAs[ExitCoder](err) || !errors.As(context.Cause(ctx), &sigerr) {
+		return err
+	}
+	return &ExitError{Code: sigerr.ExitCode(), Err: err}
+}
+
+// parseOutputFormat initializes args.OutputFormat using the OutputFormat, the
+// OutputFormatEnvVar, and the OutputFormatFlag, possibly removing the flag
+// from args.Args and adding it to args.PersistentFlags.
+func (rx *RootCommand[T]) parseOutputFormat(args *CommandArgs[T]) error {
+	args.OutputFormat = rx.OutputFormat
+
+	// honour the environment variable
+	if name := rx.OutputFormatEnvVar; name != "" {
+		if value, found := args.Env.LookupEnv(name); found {
+			format, err := nflag.ParseOutputFormat(value)
+			if err != nil {
+				return nflag.ErrInvalidEnvVar{Name: name, Value: value, Err: err}
+			}
+			args.OutputFormat = format
+		}
+	}
+
+	// honour the command line flag
+	if !rx.OutputFormatFlag {
+		return nil
+	}
+	fset := nflag.NewFlagSet(args.CommandName, nflag.ContinueOnError)
+	fset.LookupEnv = args.Env.LookupEnv
+	value := fset.StringFlag("output-format", 0, "Print errors and help using the given format (text or json).")
+	fset.SetFlagCompletion("output-format", 0, nflag.CompleteChoices("json", "text"))
+	if isCompleteCommandLine(rx.Command, args.Args) {
+		// leave the flag on the command line to complete (see [*CompleteCommand])
+		args.PersistentFlags = append(args.PersistentFlags, fset)
+		return nil
+	}
+	persistent, others := splitPersistentFlags(fset, args.Args)
+	if err := fset.Parse(persistent); err != nil {
+		return err
+	}
+	args.Args = others
+	args.PersistentFlags = append(args.PersistentFlags, fset)
+	if flag, _ := fset.LookupFlagLong("output-format"); flag.Value.Modified() {
+		format, err := nflag.ParseOutputFormat(*value)
+		if err != nil {
+			return err
+		}
+		args.OutputFormat = format
+	}
+	return nil
 }
@@ -4,10 +4,20 @@
 package clip
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
 	"sync"
 	"syscall"
 	"testing"
+	"time"
+
+	"github.com/bassosimone/clip/pkg/nflag"
+	"github.com/bassosimone/clip/pkg/nparser"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestRoot_Main(t *testing.T) {
@@ -51,4 +61,337 @@ func TestRoot_Main(t *testing.T) {
 		// Call the Main method with the mockExecEnv
 		root.Main(env)
 	})
+
+	t.Run("forced exit after interrupt", func(t *testing.T) {
+		type testcase struct {
+			name             string
+			format           nflag.OutputFormat
+			gracePeriod      time.Duration
+			interruptMessage string
+			signals          []Signal
+			ignoreCancel     bool
+			expectStatus     int
+			expectStderr     string
+		}
+
+		cases := []testcase{
+			{
+				name:             "the second signal forces exit",
+				interruptMessage: "interrupted, press Ctrl-C again to force",
+				signals:          []Signal{syscall.SIGINT, syscall.SIGTERM},
+				ignoreCancel:     true,
+				expectStatus:     143,
+				expectStderr:     "test: interrupted, press Ctrl-C again to force\n",
+			},
+
+			{
+				name:             "we print the interrupt message as JSON",
+				format:           nflag.OutputFormatJSON,
+				interruptMessage: "interrupted, press Ctrl-C again to force",
+				signals:          []Signal{syscall.SIGINT, syscall.SIGTERM},
+				ignoreCancel:     true,
+				expectStatus:     143,
+				expectStderr: `{"kind":"interrupted","program":"test",` +
+					`"message":"interrupted, press Ctrl-C again to force"}` + "\n",
+			},
+
+			{
+				name:         "the grace period expiry forces exit",
+				gracePeriod:  time.Millisecond,
+				signals:      []Signal{syscall.SIGINT},
+				ignoreCancel: true,
+				expectStatus: 130,
+			},
+
+			{
+				name:         "we do not force exit when the command terminates",
+				gracePeriod:  time.Hour,
+				signals:      []Signal{syscall.SIGINT},
+				ignoreCancel: false,
+				expectStatus: -1,
+			},
+		}
+
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				// Create a mockable environment whose exit terminates the calling goroutine
+				var stderr bytes.Buffer
+				env := NewStdlibExecEnv()
+				env.OSArgs = []string{"test"}
+				env.OSStderr = &stderr
+				sigch := make(chan chan<- Signal, 1)
+				env.SignalNotifyFunc = func(c chan<- Signal, sig ...Signal) {
+					sigch <- c
+				}
+				exitch := make(chan int, 1)
+				env.OSExit = func(code int) {
+					exitch <- code
+					runtime.Goexit()
+				}
+
+				// Create a Root instance whose command possibly ignores the cancellation
+				readych, releasech := make(chan struct{}), make(chan struct{})
+				root := &RootCommand[*StdlibExecEnv]{
+					Command: &LeafCommand[*StdlibExecEnv]{
+						RunFunc: func(ctx context.Context, args *CommandArgs[*StdlibExecEnv]) error {
+							close(readych)
+							<-ctx.Done()
+							if tc.ignoreCancel {
+								<-releasech
+							}
+							return nil
+						},
+					},
+					AutoCancel:       true,
+					GracePeriod:      tc.gracePeriod,
+					InterruptMessage: tc.interruptMessage,
+					OutputFormat:     tc.format,
+				}
+
+				// Run the Main method in the background and deliver the signals
+				donech := make(chan struct{})
+				go func() {
+					defer close(donech)
+					root.Main(env)
+				}()
+				ch := <-sigch
+				<-readych
+				for _, sig := range tc.signals {
+					ch <- sig
+				}
+
+				// Wait for either the forced exit or the command to terminate
+				status := -1
+				select {
+				case status = <-exitch:
+					close(releasech)
+					<-donech
+				case <-donech:
+				}
+				if status != tc.expectStatus {
+					t.Fatal("expected", tc.expectStatus, "got", status)
+				}
+				if diff := cmp.Diff(tc.expectStderr, stderr.String()); diff != "" {
+					t.Fatal(diff)
+				}
+			})
+		}
+	})
+
+	t.Run("cancellation cause", func(t *testing.T) {
+		type testcase struct {
+			name         string
+			run          func(ctx context.Context) error
+			expectStatus int
+			expectStderr string
+		}
+
+		cases := []testcase{
+			{
+				name:         "we map context.Canceled to the signal exit status",
+				run:          func(ctx context.Context) error { return fmt.Errorf("reading: %w", ctx.Err()) },
+				expectStatus: 143,
+				expectStderr: "test: reading: context canceled\n",
+			},
+
+			{
+				name:         "commands may return the cause",
+				run:          func(ctx context.Context) error { return context.Cause(ctx) },
+				expectStatus: 143,
+				expectStderr: "test: signal: terminated\n",
+			},
+
+			{
+				name:         "we honour the exit status chosen by commands",
+				run:          func(ctx context.Context) error { return &ExitError{Code: 3, Err: ctx.Err()} },
+				expectStatus: 3,
+				expectStderr: "test: context canceled\n",
+			},
+
+			{
+				name:         "we do not map other errors",
+				run:          func(ctx context.Context) error { return errors.New("mocked error") },
+				expectStatus: 1,
+				expectStderr: "test: mocked error\n",
+			},
+		}
+
+		for _, tc := range cases {
+			for _, policy := range []nflag.ErrorHandling{nflag.ContinueOnError, nflag.ExitOnError} {
+				t.Run(fmt.Sprintf("%s with policy %d", tc.name, policy), func(t *testing.T) {
+					// Create a mockable environment delivering SIGTERM
+					var stderr bytes.Buffer
+					env := NewStdlibExecEnv()
+					env.OSArgs = []string{"test", "leaf"}
+					env.OSStderr = &stderr
+					env.SignalNotifyFunc = func(c chan<- Signal, sig ...Signal) {
+						c <- syscall.SIGTERM
+					}
+
+					// Create a Root instance whose command checks the cause
+					leaf := &LeafCommand[*StdlibExecEnv]{
+						RunFunc: func(ctx context.Context, args *CommandArgs[*StdlibExecEnv]) error {
+							<-ctx.Done()
+							var sigerr *SignalError
+							if !errors.As(context.Cause(ctx), &sigerr) || sigerr.Signal != syscall.SIGTERM {
+								t.Error("unexpected cause", context.Cause(ctx))
+							}
+							return tc.run(ctx)
+						},
+					}
+					root := &RootCommand[*StdlibExecEnv]{
+						Command: &DispatcherCommand[*StdlibExecEnv]{
+							Commands:      map[string]Command[*StdlibExecEnv]{"leaf": leaf},
+							ErrorHandling: policy,
+						},
+						AutoCancel: true,
+					}
+
+					// Call the Main method and check the results
+					if status := runMain(root, env); status != tc.expectStatus {
+						t.Fatal("expected", tc.expectStatus, "got", status)
+					}
+					if diff := cmp.Diff(tc.expectStderr, stderr.String()); diff != "" {
+						t.Fatal(diff)
+					}
+				})
+			}
+		}
+	})
+
+	t.Run("with middleware", func(t *testing.T) {
+		// Create a mockable environment
+		env := NewStdlibExecEnv()
+		env.OSArgs = []string{"test", "foo"}
+
+		// Create a Root instance whose middleware records the arguments
+		var seen []string
+		root := &RootCommand[*StdlibExecEnv]{
+			Command: &LeafCommand[*StdlibExecEnv]{
+				RunFunc: func(ctx context.Context, args *CommandArgs[*StdlibExecEnv]) error {
+					seen = append(seen, "run")
+					return nil
+				},
+			},
+			Middleware: []Middleware[*StdlibExecEnv]{
+				PreRun(func(ctx context.Context, args *CommandArgs[*StdlibExecEnv]) error {
+					seen = append(seen, args.CommandName+" "+strings.Join(args.Args, " "))
+					return nil
+				}),
+			},
+		}
+
+		// Call the Main method and check the middleware ran first
+		root.Main(env)
+		if diff := cmp.Diff([]string{"test foo", "run"}, seen); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+	t.Run("error reporting", func(t *testing.T) {
+		type testcase struct {
+			name         string
+			args         []string
+			err          error
+			expectStatus int
+			expectStderr string
+		}
+
+		cases := []testcase{
+			{
+				name:         "we print generic errors and exit with 1",
+				args:         []string{"leaf"},
+				err:          errors.New("mocked error"),
+				expectStatus: 1,
+				expectStderr: "test: mocked error\n",
+			},
+
+			{
+				name:         "we honour ExitCoder",
+				args:         []string{"leaf"},
+				err:          &ExitError{Code: ExitNoInput, Err: errors.New("no such file")},
+				expectStatus: 66,
+				expectStderr: "test: no such file\n",
+			},
+
+			{
+				name:         "we do not print ExitError without an underlying error",
+				args:         []string{"leaf"},
+				err:          &ExitError{Code: 3},
+				expectStatus: 3,
+			},
+
+			{
+				name:         "we exit with 2 on usage errors",
+				args:         []string{"leaf"},
+				err:          nparser.ErrUnknownOption{Name: "x", Prefix: "--"},
+				expectStatus: 2,
+				expectStderr: "test: unknown option: --x\n",
+			},
+
+			{
+				name:         "we do not print again the dispatcher errors",
+				args:         []string{"nonexistent"},
+				expectStatus: 2,
+				expectStderr: "test: no such command: nonexistent\nTry 'test --help' for more information.\n",
+			},
+
+			{
+				name:         "we do not exit on success",
+				args:         []string{"leaf"},
+				expectStatus: -1,
+			},
+		}
+
+		for _, tc := range cases {
+			for _, policy := range []nflag.ErrorHandling{nflag.ContinueOnError, nflag.ExitOnError} {
+				t.Run(fmt.Sprintf("%s with policy %d", tc.name, policy), func(t *testing.T) {
+					var stderr bytes.Buffer
+					env := NewStdlibExecEnv()
+					env.OSArgs = append([]string{"test"}, tc.args...)
+					env.OSStderr = &stderr
+					root := &RootCommand[*StdlibExecEnv]{
+						Command: &DispatcherCommand[*StdlibExecEnv]{
+							BriefDescriptionText: "Test dispatcher.",
+							Commands: map[string]Command[*StdlibExecEnv]{
+								"leaf": &LeafCommand[*StdlibExecEnv]{
+									RunFunc: func(ctx context.Context, args *CommandArgs[*StdlibExecEnv]) error {
+										return tc.err
+									},
+								},
+							},
+							ErrorHandling: policy,
+						},
+					}
+					if status := runMain(root, env); status != tc.expectStatus {
+						t.Fatal("expected", tc.expectStatus, "got", status)
+					}
+					if diff := cmp.Diff(tc.expectStderr, stderr.String()); diff != "" {
+						t.Fatal(diff)
+					}
+				})
+			}
+		}
+	})
+}
+
+// runMain runs root.Main using env, whose exit terminates the calling goroutine,
+// and returns the exit status or -1 when Main returns without exiting.
+func runMain(root *RootCommand[*StdlibExecEnv], env *StdlibExecEnv) int {
+	exitch := make(chan int, 1)
+	env.OSExit = func(code int) {
+		exitch <- code
+		runtime.Goexit()
+	}
+	donech := make(chan struct{})
+	go func() {
+		defer close(donech)
+		root.Main(env)
+	}()
+	<-donech
+	select {
+	case status := <-exitch:
+		return status
+	default:
+		return -1
+	}
 }
@@ -0,0 +1,39 @@
+// signals.go - signals handling.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// SignalError is the cause of the context cancellation when the
+// AutoCancel feature of [*RootCommand] receives a signal. Use
+// [context.Cause] to obtain it inside commands, for example:
+//
+//	var sigerr *clip.SignalError
+//	if errors.As(context.Cause(ctx), &sigerr) && sigerr.Signal == syscall.SIGTERM {
+//		// perform the cleanup required when terminating
+//	}
+type SignalError struct {
+	// Signal is the received signal.
+	Signal Signal
+}
+
+var _ ExitCoder = &SignalError{}
+
+// Error implements error.
+func (err *SignalError) Error() string {
+	return fmt.Sprintf("signal: %s", err.Signal)
+}
+
+// ExitCode returns the conventional exit status indicating that the
+// signal terminated the program (see [ExitSignalBase]) or [ExitFailure]
+// if the Signal is not a [syscall.Signal].
+func (err *SignalError) ExitCode() int {
+	if num, ok := err.Signal.(syscall.Signal); ok {
+		return ExitSignalBase + int(num)
+	}
+	return ExitFailure
+}
@@ -0,0 +1,46 @@
+// signals_test.go - signals handling tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import (
+	"syscall"
+	"testing"
+)
+
+// mockSignal is a [Signal] that is not a [syscall.Signal].
+type mockSignal struct{}
+
+// Signal implements [Signal].
+func (mockSignal) Signal() {}
+
+// String implements [Signal].
+func (mockSignal) String() string {
+	return "mock"
+}
+
+func TestSignalError(t *testing.T) {
+	type testcase struct {
+		signal       Signal
+		expectString string
+		expectStatus int
+	}
+
+	cases := []testcase{
+		{signal: syscall.SIGINT, expectString: "signal: interrupt", expectStatus: 130},
+		{signal: syscall.SIGTERM, expectString: "signal: terminated", expectStatus: 143},
+		{signal: mockSignal{}, expectString: "signal: mock", expectStatus: ExitFailure},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.expectString, func(t *testing.T) {
+			err := &SignalError{Signal: tc.signal}
+			if got := err.Error(); got != tc.expectString {
+				t.Fatal("expected", tc.expectString, "got", got)
+			}
+			if got := err.ExitCode(); got != tc.expectStatus {
+				t.Fatal("expected", tc.expectStatus, "got", got)
+			}
+		})
+	}
+}
@@ -5,15 +5,17 @@ package clip
 
 import (
 	"io"
+	"io/fs"
 	"os"
 	"os/signal"
+	"time"
 )
 
 // Signal is an alias for [os.Signal].
 type Signal = os.Signal
 
-// StdlibExecEnv is a highly-customizable [ExecEnv] whose default
-// implementation uses the standard library.
+// StdlibExecEnv is a highly-customizable [ExtendedExecEnv] and [TerminalExecEnv]
+// whose default implementation uses the standard library.
 //
 // The zero value is not ready to use. Use [NewStdlibExecEnv]
 // to create a new instance. Customize fields as needed.
@@ -21,9 +23,21 @@ type StdlibExecEnv struct {
 	// OSArgs is initialized with [os.Args].
 	OSArgs []string
 
+	// OSEnviron is initialized with [os.Environ].
+	OSEnviron func() []string
+
 	// OSExit is initialized with [os.Exit].
 	OSExit func(exitcode int)
 
+	// OSFileSystem is initialized with [OSFS].
+	OSFileSystem FS
+
+	// OSGetwd is initialized with [os.Getwd].
+	OSGetwd func() (string, error)
+
+	// OSHostname is initialized with [os.Hostname].
+	OSHostname func() (string, error)
+
 	// OSLookupEnv is initialized with [os.LookupEnv].
 	OSLookupEnv func(key string) (string, bool)
 
@@ -38,20 +52,30 @@ type StdlibExecEnv struct {
 
 	// OSStdin is initialized with [os.Stdin].
 	OSStdin io.Reader
+
+	// TimeNowFunc is initialized with [time.Now].
+	TimeNowFunc func() time.Time
 }
 
-var _ ExecEnv = &StdlibExecEnv{}
+var _ ExtendedExecEnv = &StdlibExecEnv{}
+
+var _ TerminalExecEnv = &StdlibExecEnv{}
 
 // NewStdlibExecEnv creates a new [StdlibExecEnv] instance.
 func NewStdlibExecEnv() *StdlibExecEnv {
 	return &StdlibExecEnv{
 		OSArgs:           os.Args,
+		OSEnviron:        os.Environ,
 		OSExit:           os.Exit,
+		OSFileSystem:     OSFS{},
+		OSGetwd:          os.Getwd,
+		OSHostname:       os.Hostname,
 		OSLookupEnv:      os.LookupEnv,
 		SignalNotifyFunc: signal.Notify,
 		OSStderr:         os.Stderr,
 		OSStdout:         os.Stdout,
 		OSStdin:          os.Stdin,
+		TimeNowFunc:      time.Now,
 	}
 }
 
@@ -60,16 +84,41 @@ func (ee *StdlibExecEnv) Args() []string {
 	return ee.OSArgs
 }
 
+// Environ implements [ExtendedExecEnv].
+func (ee *StdlibExecEnv) Environ() []string {
+	return ee.OSEnviron()
+}
+
 // Exit implements [ExecEnv].
 func (ee *StdlibExecEnv) Exit(exitcode int) {
 	ee.OSExit(exitcode)
 }
 
+// FS implements [ExtendedExecEnv].
+func (ee *StdlibExecEnv) FS() FS {
+	return ee.OSFileSystem
+}
+
+// Getwd implements [ExtendedExecEnv].
+func (ee *StdlibExecEnv) Getwd() (string, error) {
+	return ee.OSGetwd()
+}
+
+// Hostname implements [ExtendedExecEnv].
+func (ee *StdlibExecEnv) Hostname() (string, error) {
+	return ee.OSHostname()
+}
+
 // LookupEnv implements [ExecEnv].
 func (ee *StdlibExecEnv) LookupEnv(key string) (string, bool) {
 	return ee.OSLookupEnv(key)
 }
 
+// Now implements [ExtendedExecEnv].
+func (ee *StdlibExecEnv) Now() time.Time {
+	return ee.TimeNowFunc()
+}
+
 // SignalNotify implements [ExecEnv].
 func (ee *StdlibExecEnv) SignalNotify(c chan<- Signal, sig ...Signal) {
 	ee.SignalNotifyFunc(c, sig...)
@@ -89,3 +138,50 @@ func (ee *StdlibExecEnv) Stdin() io.Reader {
 func (ee *StdlibExecEnv) Stdout() io.Writer {
 	return ee.OSStdout
 }
+
+// StdoutTerminal implements [TerminalExecEnv].
+//
+// We ask the operating system when OSStdout is an [*os.File].
+func (ee *StdlibExecEnv) StdoutTerminal() (isatty bool, width int) {
+	return fileTerminal(ee.OSStdout)
+}
+
+// OSFS is the [FS] implemented using the [os] package.
+type OSFS struct{}
+
+var _ FS = OSFS{}
+
+// MkdirAll implements [FS].
+func (OSFS) MkdirAll(name string, perm fs.FileMode) error {
+	return os.MkdirAll(name, perm)
+}
+
+// Open implements [FS].
+func (OSFS) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+// ReadDir implements [FS].
+func (OSFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+// ReadFile implements [FS].
+func (OSFS) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+// Remove implements [FS].
+func (OSFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+// Stat implements [FS].
+func (OSFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+// WriteFile implements [FS].
+func (OSFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
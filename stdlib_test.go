@@ -4,8 +4,13 @@
 package clip
 
 import (
+	"errors"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 )
@@ -19,6 +24,16 @@ func TestStdlibExecEnv(t *testing.T) {
 		}
 	})
 
+	t.Run("Environ", func(t *testing.T) {
+		env := NewStdlibExecEnv()
+		env.OSEnviron = func() []string {
+			return []string{"FOO=BAR"}
+		}
+		if diff := cmp.Diff([]string{"FOO=BAR"}, env.Environ()); diff != "" {
+			t.Error(diff)
+		}
+	})
+
 	t.Run("Exit", func(t *testing.T) {
 		env := NewStdlibExecEnv()
 		var code int
@@ -31,6 +46,35 @@ func TestStdlibExecEnv(t *testing.T) {
 		}
 	})
 
+	t.Run("FS", func(t *testing.T) {
+		env := NewStdlibExecEnv()
+		if _, ok := env.FS().(OSFS); !ok {
+			t.Errorf("FS() = %T, want %T", env.FS(), OSFS{})
+		}
+	})
+
+	t.Run("Getwd", func(t *testing.T) {
+		env := NewStdlibExecEnv()
+		env.OSGetwd = func() (string, error) {
+			return "/home/user", nil
+		}
+		got, _ := env.Getwd()
+		if got != "/home/user" {
+			t.Errorf("Getwd() = %q, want %q", got, "/home/user")
+		}
+	})
+
+	t.Run("Hostname", func(t *testing.T) {
+		env := NewStdlibExecEnv()
+		env.OSHostname = func() (string, error) {
+			return "example.com", nil
+		}
+		got, _ := env.Hostname()
+		if got != "example.com" {
+			t.Errorf("Hostname() = %q, want %q", got, "example.com")
+		}
+	})
+
 	t.Run("LookupEnv", func(t *testing.T) {
 		env := NewStdlibExecEnv()
 		env.OSLookupEnv = func(key string) (string, bool) {
@@ -42,6 +86,17 @@ func TestStdlibExecEnv(t *testing.T) {
 		}
 	})
 
+	t.Run("Now", func(t *testing.T) {
+		env := NewStdlibExecEnv()
+		now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		env.TimeNowFunc = func() time.Time {
+			return now
+		}
+		if got := env.Now(); !got.Equal(now) {
+			t.Errorf("Now() = %v, want %v", got, now)
+		}
+	})
+
 	t.Run("SignalNotify", func(t *testing.T) {
 		env := NewStdlibExecEnv()
 		var got chan<- os.Signal
@@ -75,4 +130,55 @@ func TestStdlibExecEnv(t *testing.T) {
 			t.Errorf("Stdin() = %v, want %v", env.Stdin(), os.Stdin)
 		}
 	})
+
+	t.Run("StdoutTerminal", func(t *testing.T) {
+		env := NewStdlibExecEnv()
+		env.OSStdout = io.Discard
+		if isatty, width := env.StdoutTerminal(); isatty || width != 0 {
+			t.Errorf("StdoutTerminal() = %v, %d, want false, 0", isatty, width)
+		}
+	})
+}
+
+func TestOSFS(t *testing.T) {
+	var (
+		fsys = OSFS{}
+		dir  = filepath.Join(t.TempDir(), "a", "b")
+		name = filepath.Join(dir, "file.txt")
+	)
+	if err := fsys.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.WriteFile(name, []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := fsys.ReadFile(name)
+	if err != nil || string(data) != "hello" {
+		t.Fatal("ReadFile", string(data), err)
+	}
+	finfo, err := fsys.Stat(name)
+	if err != nil || finfo.Size() != 5 {
+		t.Fatal("Stat", finfo, err)
+	}
+	entries, err := fsys.ReadDir(dir)
+	if err != nil || len(entries) != 1 || entries[0].Name() != "file.txt" {
+		t.Fatal("ReadDir", entries, err)
+	}
+	file, err := fsys.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = io.ReadAll(file)
+	file.Close()
+	if err != nil || string(data) != "hello" {
+		t.Fatal("Open", string(data), err)
+	}
+
+	if err := fsys.Remove(name); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fsys.Stat(name); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected", fs.ErrNotExist, "got", err)
+	}
 }
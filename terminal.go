@@ -0,0 +1,121 @@
+// terminal.go - terminal capabilities detection.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import (
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/bassosimone/clip/pkg/nflag"
+)
+
+// Terminal describes the terminal attached to the standard output of an
+// [ExecEnv]. Use [DetectTerminal] to obtain it.
+type Terminal struct {
+	// Colors indicates whether we may style the output using ANSI colors.
+	Colors bool
+
+	// IsTTY indicates whether the standard output is a terminal.
+	IsTTY bool
+
+	// Width is the width in columns or zero when unknown.
+	Width int
+}
+
+// usageWidthMargin is the number of columns we leave empty at the right
+// of the usage, to avoid lines wrapping at the edge of the terminal.
+const usageWidthMargin = 1
+
+// MaxUsageWidth is the maximum number of columns at which we wrap the
+// usage, since longer lines are hard to read on wide terminals.
+const MaxUsageWidth = 100
+
+// UsageWidth returns the number of columns at which to wrap the usage, which
+// is the Width minus a one column margin, capped at [MaxUsageWidth], or, when
+// the Width is unknown or too small, the [nflag.DefaultUsageWidth].
+func (term Terminal) UsageWidth() int {
+	if term.Width <= usageWidthMargin {
+		return nflag.DefaultUsageWidth
+	}
+	return min(term.Width-usageWidthMargin, MaxUsageWidth)
+}
+
+// TerminalExecEnv is an optional extension of [ExecEnv] describing the terminal
+// attached to the standard output, which allows testing commands whose output
+// depends on the terminal. [DetectTerminal] uses it when available.
+type TerminalExecEnv interface {
+	ExecEnv
+
+	// StdoutTerminal returns whether the standard output is a terminal and
+	// its width in columns, which is zero when unknown.
+	StdoutTerminal() (isatty bool, width int)
+}
+
+// DetectTerminal returns the [Terminal] attached to the standard output of env.
+//
+// When env implements [TerminalExecEnv], we use it to know whether the standard
+// output is a terminal and its width. Otherwise, we ask the operating system
+// when the standard output is an [*os.File]. In both cases, the `COLUMNS`
+// environment variable, when containing a positive integer, overrides the width.
+//
+// We allow colors when the standard output is a terminal, unless `NO_COLOR` is
+// not empty, `CLICOLOR` is `0`, or `TERM` is `dumb`. Setting `CLICOLOR_FORCE` to
+// a value other than the empty string and `0` allows colors even when the standard
+// output is not a terminal. `NO_COLOR` takes precedence over `CLICOLOR_FORCE`.
+func DetectTerminal(env ExecEnv) Terminal {
+	var term Terminal
+	if tenv, ok := env.(TerminalExecEnv); ok {
+		term.IsTTY, term.Width = tenv.StdoutTerminal()
+	} else {
+		term.IsTTY, term.Width = fileTerminal(env.Stdout())
+	}
+
+	// honour the COLUMNS environment variable
+	if value, found := env.LookupEnv("COLUMNS"); found {
+		if columns, err := strconv.Atoi(value); err == nil && columns > 0 {
+			term.Width = columns
+		}
+	}
+
+	// honour the environment variables controlling colors
+	getenv := func(key string) string {
+		value, _ := env.LookupEnv(key)
+		return value
+	}
+	switch {
+	case getenv("NO_COLOR") != "":
+		term.Colors = false
+	case getenv("CLICOLOR_FORCE") != "" && getenv("CLICOLOR_FORCE") != "0":
+		term.Colors = true
+	default:
+		term.Colors = term.IsTTY && getenv("CLICOLOR") != "0" && getenv("TERM") != "dumb"
+	}
+	return term
+}
+
+// usageTerminal returns the [Terminal] used to print the usage, which
+// does not allow colors unless args.UsageColors is true.
+func usageTerminal[T ExecEnv](args *CommandArgs[T]) Terminal {
+	term := DetectTerminal(args.Env)
+	term.Colors = term.Colors && args.UsageColors
+	return term
+}
+
+// fileTerminal returns whether w is an [*os.File] referring to a
+// terminal and, if so, the terminal width, when known.
+func fileTerminal(w io.Writer) (isatty bool, width int) {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false, 0
+	}
+	conn, err := file.SyscallConn()
+	if err != nil {
+		return false, 0
+	}
+	_ = conn.Control(func(fd uintptr) {
+		isatty, width = fdTerminal(fd)
+	})
+	return
+}
@@ -0,0 +1,12 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+// terminal_other.go - terminal code for the other systems.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+// fdTerminal always returns that fd is not a terminal, since
+// we do not know how to detect terminals on this system.
+func fdTerminal(fd uintptr) (isatty bool, width int) {
+	return false, 0
+}
@@ -0,0 +1,146 @@
+// terminal_test.go - terminal capabilities detection tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bassosimone/clip/pkg/nflag"
+	"github.com/google/go-cmp/cmp"
+)
+
+// terminalExecEnv is a [TerminalExecEnv] with the given terminal.
+type terminalExecEnv struct {
+	*StdlibExecEnv
+	isatty bool
+	width  int
+}
+
+// StdoutTerminal implements [TerminalExecEnv].
+func (env *terminalExecEnv) StdoutTerminal() (bool, int) {
+	return env.isatty, env.width
+}
+
+func TestDetectTerminal(t *testing.T) {
+	cases := []struct {
+		name   string
+		isatty bool
+		width  int
+		vars   map[string]string
+		expect Terminal
+	}{{
+		name:   "we do not use colors when not using a terminal",
+		expect: Terminal{Colors: false, IsTTY: false, Width: 0},
+	}, {
+		name:   "we use colors and the width when using a terminal",
+		isatty: true,
+		width:  100,
+		expect: Terminal{Colors: true, IsTTY: true, Width: 100},
+	}, {
+		name:   "COLUMNS overrides the width",
+		isatty: true,
+		width:  100,
+		vars:   map[string]string{"COLUMNS": "40"},
+		expect: Terminal{Colors: true, IsTTY: true, Width: 40},
+	}, {
+		name:   "COLUMNS provides the width when not using a terminal",
+		vars:   map[string]string{"COLUMNS": "40"},
+		expect: Terminal{Colors: false, IsTTY: false, Width: 40},
+	}, {
+		name:   "we ignore an invalid COLUMNS",
+		isatty: true,
+		width:  100,
+		vars:   map[string]string{"COLUMNS": "-1"},
+		expect: Terminal{Colors: true, IsTTY: true, Width: 100},
+	}, {
+		name:   "NO_COLOR disables colors",
+		isatty: true,
+		vars:   map[string]string{"NO_COLOR": "1"},
+		expect: Terminal{Colors: false, IsTTY: true, Width: 0},
+	}, {
+		name:   "an empty NO_COLOR does not disable colors",
+		isatty: true,
+		vars:   map[string]string{"NO_COLOR": ""},
+		expect: Terminal{Colors: true, IsTTY: true, Width: 0},
+	}, {
+		name:   "CLICOLOR=0 disables colors",
+		isatty: true,
+		vars:   map[string]string{"CLICOLOR": "0"},
+		expect: Terminal{Colors: false, IsTTY: true, Width: 0},
+	}, {
+		name:   "TERM=dumb disables colors",
+		isatty: true,
+		vars:   map[string]string{"TERM": "dumb"},
+		expect: Terminal{Colors: false, IsTTY: true, Width: 0},
+	}, {
+		name:   "CLICOLOR_FORCE enables colors when not using a terminal",
+		vars:   map[string]string{"CLICOLOR_FORCE": "1"},
+		expect: Terminal{Colors: true, IsTTY: false, Width: 0},
+	}, {
+		name:   "CLICOLOR_FORCE=0 does not enable colors",
+		vars:   map[string]string{"CLICOLOR_FORCE": "0"},
+		expect: Terminal{Colors: false, IsTTY: false, Width: 0},
+	}, {
+		name:   "NO_COLOR takes precedence over CLICOLOR_FORCE",
+		vars:   map[string]string{"CLICOLOR_FORCE": "1", "NO_COLOR": "1"},
+		expect: Terminal{Colors: false, IsTTY: false, Width: 0},
+	}}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := &terminalExecEnv{StdlibExecEnv: NewStdlibExecEnv(), isatty: tc.isatty, width: tc.width}
+			env.OSLookupEnv = func(key string) (string, bool) {
+				value, found := tc.vars[key]
+				return value, found
+			}
+			if diff := cmp.Diff(tc.expect, DetectTerminal(env)); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	t.Run("we ask the operating system otherwise", func(t *testing.T) {
+		stdlib := NewStdlibExecEnv()
+		stdlib.OSLookupEnv = func(key string) (string, bool) {
+			return "", false
+		}
+		env := minimalExecEnv{stdlib}
+
+		stdlib.OSStdout = &bytes.Buffer{}
+		if diff := cmp.Diff(Terminal{}, DetectTerminal(env)); diff != "" {
+			t.Fatal(diff)
+		}
+
+		file, err := os.Create(filepath.Join(t.TempDir(), "stdout.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		stdlib.OSStdout = file
+		if diff := cmp.Diff(Terminal{}, DetectTerminal(env)); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+}
+
+func TestTerminalUsageWidth(t *testing.T) {
+	cases := []struct {
+		width  int
+		expect int
+	}{
+		{width: 0, expect: nflag.DefaultUsageWidth},
+		{width: 1, expect: nflag.DefaultUsageWidth},
+		{width: 2, expect: 1},
+		{width: 100, expect: 99},
+		{width: 101, expect: MaxUsageWidth},
+		{width: 240, expect: MaxUsageWidth},
+	}
+	for _, tc := range cases {
+		if width := (Terminal{Width: tc.width}).UsageWidth(); width != tc.expect {
+			t.Errorf("Width %d: expected %d, got %d", tc.width, tc.expect, width)
+		}
+	}
+}
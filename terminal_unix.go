@@ -0,0 +1,30 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+// terminal_unix.go - Unix-specific terminal code.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// winsize is the window size returned by the TIOCGWINSZ ioctl.
+type winsize struct {
+	Row    uint16
+	Col    uint16
+	Xpixel uint16
+	Ypixel uint16
+}
+
+// fdTerminal returns whether fd refers to a terminal and its width, which
+// we obtain using the TIOCGWINSZ ioctl, which only succeeds for terminals.
+func fdTerminal(fd uintptr) (isatty bool, width int) {
+	var ws winsize
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&ws)))
+	if errno != 0 {
+		return false, 0
+	}
+	return true, int(ws.Col)
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+// terminal_windows.go - Windows-specific terminal code.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import "syscall"
+
+// fdTerminal returns whether fd refers to a console. Because the syscall
+// package does not expose the console screen buffer, the width is unknown.
+func fdTerminal(fd uintptr) (isatty bool, width int) {
+	var mode uint32
+	err := syscall.GetConsoleMode(syscall.Handle(fd), &mode)
+	return err == nil, 0
+}
@@ -31,7 +31,7 @@ type VersionCommand[T ExecEnv] struct {
 	Version string
 }
 
-var _ Command[*StdlibExecEnv] = &VersionCommand[*StdlibExecEnv]{}
+var _ InspectableCommand[*StdlibExecEnv] = &VersionCommand[*StdlibExecEnv]{}
 
 // BriefDescription implements [Command].
 func (c *VersionCommand[T]) BriefDescription() string {
@@ -64,7 +64,7 @@ func (c *VersionCommand[T]) PrintVersion(env T) error {
 // Run implements [Command].
 func (c *VersionCommand[T]) Run(ctx context.Context, args *CommandArgs[T]) error {
 	// Create empty command line parser.
-	clp := nflag.NewFlagSet(args.CommandName, c.ErrorHandling)
+	clp := NewFlagSet(args, c.ErrorHandling)
 	clp.Description = args.Command.BriefDescription()
 	clp.PositionalArgumentsUsage = "" // do not print a name for positional arguments
 
@@ -85,6 +85,11 @@ func (c *VersionCommand[T]) Run(ctx context.Context, args *CommandArgs[T]) error
 	return c.PrintVersion(args.Env)
 }
 
+// SupportsInspection implements [InspectableCommand].
+func (c *VersionCommand[T]) SupportsInspection() bool {
+	return true
+}
+
 // SupportsSubcommands implements [Command].
 func (c *VersionCommand[T]) SupportsSubcommands() bool {
 	return false
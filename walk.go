@@ -0,0 +1,103 @@
+// walk.go - command tree traversal.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import "errors"
+
+// CommandWithSubcommands is the optional interface implemented by
+// a [Command] that knows its subcommands, such as [*DispatcherCommand].
+//
+// Functions traversing the command tree, such as [Walk] and [Find], use
+// this interface to discover the subcommands. Commands not implementing
+// this interface are leaves of the command tree.
+type CommandWithSubcommands[T ExecEnv] interface {
+	Command[T]
+
+	// Subcommands returns the subcommands indexed by name. The caller
+	// may modify the returned map without affecting the command.
+	Subcommands() map[string]Command[T]
+}
+
+// CommandWithHiddenSubcommands is the optional interface implemented
+// by a [CommandWithSubcommands] that does not advertise some of its
+// subcommands, such as a [*DispatcherCommand] with Hidden commands.
+//
+// [Walk] and [Find] reach the hidden subcommands. Code advertising the
+// subcommands, such as generated completions and documentation, uses
+// this interface to skip them.
+type CommandWithHiddenSubcommands[T ExecEnv] interface {
+	CommandWithSubcommands[T]
+
+	// SubcommandHidden returns whether the subcommand
+	// with the given name is hidden.
+	SubcommandHidden(name string) bool
+}
+
+// WalkFunc is the function called by [Walk] for each command. The path
+// contains the names of the subcommands leading to cmd from the root,
+// thus it is empty for the root. The function must not retain the
+// path, since [Walk] reuses its underlying storage.
+//
+// If the function returns [SkipSubcommands], [Walk] does not visit the
+// subcommands of cmd. If it returns any other non-nil error, [Walk]
+// stops and returns such an error.
+type WalkFunc[T ExecEnv] func(path []string, cmd Command[T]) error
+
+// SkipSubcommands is the error that a [WalkFunc] returns to
+// skip visiting the subcommands of the current command.
+var SkipSubcommands = errors.New("skip subcommands")
+
+// Walk visits the command tree rooted at root in depth-first order
+// calling fn for each command, including root. We visit a command
+// before its subcommands and we visit subcommands sorted by name.
+//
+// We use the [CommandWithSubcommands] interface to discover subcommands,
+// thus we also visit the hidden subcommands.
+func Walk[T ExecEnv](root Command[T], fn WalkFunc[T]) error {
+	err := walk(make([]string, 0, 8), root, fn)
+	if errors.Is(err, SkipSubcommands) {
+		err = nil
+	}
+	return err
+}
+
+func walk[T ExecEnv](path []string, cmd Command[T], fn WalkFunc[T]) error {
+	// Visit the current command
+	if err := fn(path, cmd); err != nil {
+		return err
+	}
+
+	// Possibly visit the subcommands
+	cx, ok := cmd.(CommandWithSubcommands[T])
+	if !ok {
+		return nil
+	}
+	commands := cx.Subcommands()
+	for _, name := range sortedSubcommandNames(commands) {
+		err := walk(append(path, name), commands[name], fn)
+		if err != nil && !errors.Is(err, SkipSubcommands) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Find returns the command reached from root following the given subcommand
+// names, using the [CommandWithSubcommands] interface to discover subcommands.
+// Without names, Find returns root. The boolean is false if there is no
+// such command, in which case the returned command is nil.
+func Find[T ExecEnv](root Command[T], names ...string) (Command[T], bool) {
+	cmd := root
+	for _, name := range names {
+		cx, ok := cmd.(CommandWithSubcommands[T])
+		if !ok {
+			return nil, false
+		}
+		cmd = cx.Subcommands()[name]
+		if cmd == nil {
+			return nil, false
+		}
+	}
+	return cmd, true
+}
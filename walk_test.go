@@ -0,0 +1,115 @@
+// walk_test.go - command tree traversal tests.
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package clip
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	errMocked := errors.New("mocked error")
+
+	type testcase struct {
+		name          string
+		fn            func(path []string) error
+		expectErr     error
+		expectVisited string
+	}
+
+	cases := []testcase{
+		{
+			name:          "we visit all the commands in order including the hidden ones",
+			fn:            func(path []string) error { return nil },
+			expectVisited: "/ /completion /curl /git /git/clone /git/init /legacy /secret /version",
+		},
+
+		{
+			name: "SkipSubcommands skips the subcommands",
+			fn: func(path []string) error {
+				if len(path) == 1 && path[0] == "git" {
+					return SkipSubcommands
+				}
+				return nil
+			},
+			expectVisited: "/ /completion /curl /git /legacy /secret /version",
+		},
+
+		{
+			name:          "SkipSubcommands on the root",
+			fn:            func(path []string) error { return SkipSubcommands },
+			expectVisited: "/",
+		},
+
+		{
+			name: "other errors stop the walk",
+			fn: func(path []string) error {
+				if len(path) == 2 && path[1] == "clone" {
+					return errMocked
+				}
+				return nil
+			},
+			expectErr:     errMocked,
+			expectVisited: "/ /completion /curl /git /git/clone",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var visited []string
+			err := Walk(Command[*StdlibExecEnv](newTestTree(nil)), func(path []string, cmd Command[*StdlibExecEnv]) error {
+				visited = append(visited, "/"+strings.Join(path, "/"))
+				return tc.fn(path)
+			})
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v, got %v", tc.expectErr, err)
+			}
+			if got := strings.Join(visited, " "); got != tc.expectVisited {
+				t.Fatalf("expected %q, got %q", tc.expectVisited, got)
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	root := newTestTree(nil)
+
+	type testcase struct {
+		names  []string
+		found  bool
+		expect Command[*StdlibExecEnv]
+	}
+
+	cases := []testcase{
+		{names: nil, found: true, expect: root},
+		{names: []string{"git", "clone"}, found: true, expect: root.Commands["git"].(*DispatcherCommand[*StdlibExecEnv]).Commands["clone"]},
+		{names: []string{"secret"}, found: true, expect: root.Commands["secret"]},
+		{names: []string{"g"}, found: false, expect: nil},
+		{names: []string{"git", "nonexistent"}, found: false, expect: nil},
+		{names: []string{"curl", "nonexistent"}, found: false, expect: nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(strings.Join(tc.names, " "), func(t *testing.T) {
+			cmd, found := Find(Command[*StdlibExecEnv](root), tc.names...)
+			if found != tc.found {
+				t.Fatalf("expected found=%v, got %v", tc.found, found)
+			}
+			if cmd != tc.expect {
+				t.Fatalf("expected %v, got %v", tc.expect, cmd)
+			}
+		})
+	}
+
+	t.Run("synthesized commands", func(t *testing.T) {
+		cmd, found := Find(Command[*StdlibExecEnv](root), "version")
+		if !found {
+			t.Fatal("expected to find the version command")
+		}
+		if _, ok := cmd.(*VersionCommand[*StdlibExecEnv]); !ok {
+			t.Fatalf("expected a *VersionCommand, got %T", cmd)
+		}
+	})
+}